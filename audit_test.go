@@ -0,0 +1,154 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "encoding/json"
+        "os"
+        "strings"
+        "testing"
+)
+
+// writeAuditRecords writes the audit records login, update and logout to
+// a new audit file and returns its path
+func writeAuditRecords(t *testing.T) string {
+        t.Helper()
+        path := tempLogPath(t, "audit.log")
+        if err := SetAuditFile(path); err != nil {
+                t.Fatal(err)
+        }
+        for _, event := range []string{"login", "update", "logout"} {
+                if err := Audit(event, map[string]interface{}{"user": "alice"}); err != nil {
+                        t.Fatal(err)
+                }
+        }
+        if err := SetAuditFile(""); err != nil {
+                t.Fatal(err)
+        }
+        return path
+}
+
+func TestAudit(t *testing.T) {
+        out := captureOutput(t)
+        SetLevel(LevelFatal)
+        AddFilter(func(int, string, map[string]interface{}) bool { return false })
+        path := writeAuditRecords(t)
+
+        if err := VerifyAuditLog(path); err != nil {
+                t.Fatalf("VerifyAuditLog: %v", err)
+        }
+        if got := out.String(); got != "" {
+                t.Errorf("audit records reached the console: %q", got)
+        }
+
+        // Reopening continues the chain
+        if err := SetAuditFile(path); err != nil {
+                t.Fatal(err)
+        }
+        if err := Audit("login", nil); err != nil {
+                t.Fatal(err)
+        }
+        SetAuditFile("")
+        if err := VerifyAuditLog(path); err != nil {
+                t.Fatalf("VerifyAuditLog after reopening: %v", err)
+        }
+
+        lines := strings.Split(strings.TrimSpace(readLog(t, path)), "\n")
+        if len(lines) != 4 {
+                t.Fatalf("got %d audit records, want 4", len(lines))
+        }
+        prev := ""
+        for i, line := range lines {
+                var rec map[string]interface{}
+                if err := json.Unmarshal([]byte(line), &rec); err != nil {
+                        t.Fatalf("record %d: %v", i+1, err)
+                }
+                if rec["prev"] != prev {
+                        t.Errorf("record %d prev %v, want %q", i+1, rec["prev"], prev)
+                }
+                prev, _ = rec["hash"].(string)
+        }
+}
+
+func TestAuditWithoutFile(t *testing.T) {
+        captureOutput(t)
+        if err := Audit("login", nil); err != errNoAuditFile {
+                t.Errorf("Audit without a file = %v, want %v", err, errNoAuditFile)
+        }
+}
+
+func TestAuditTampering(t *testing.T) {
+        tests := []struct {
+                name   string
+                tamper func(lines []string) []string
+                want   string // Expected error, empty for a valid chain
+        }{
+                {
+                        name:   "untouched",
+                        tamper: func(lines []string) []string { return lines },
+                },
+                {
+                        name: "altered field",
+                        tamper: func(lines []string) []string {
+                                lines[1] = strings.Replace(lines[1], `"alice"`, `"mallory"`, 1)
+                                return lines
+                        },
+                        want: "audit record 2: hash mismatch",
+                },
+                {
+                        name: "altered hash",
+                        tamper: func(lines []string) []string {
+                                lines[0] = lines[0][:len(lines[0])-3] + `x"}`
+                                return lines
+                        },
+                        want: "audit record 1: hash mismatch",
+                },
+                {
+                        name:   "removed record",
+                        tamper: func(lines []string) []string { return append(lines[:1], lines[2:]...) },
+                        want:   "audit record 2: chain broken",
+                },
+                {
+                        name:   "reordered records",
+                        tamper: func(lines []string) []string { return []string{lines[0], lines[2], lines[1]} },
+                        want:   "audit record 2: chain broken",
+                },
+                {
+                        name: "malformed record",
+                        tamper: func(lines []string) []string {
+                                lines[2] = "garbage"
+                                return lines
+                        },
+                        want: "audit record 3: malformed",
+                },
+                {
+                        name:   "truncated at the end",
+                        tamper: func(lines []string) []string { return lines[:2] },
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        path := writeAuditRecords(t)
+                        lines := strings.Split(strings.TrimSpace(readLog(t, path)), "\n")
+                        tampered := strings.Join(tt.tamper(lines), "\n") + "\n"
+                        if err := os.WriteFile(path, []byte(tampered), 0644); err != nil {
+                                t.Fatal(err)
+                        }
+
+                        err := VerifyAuditLog(path)
+                        if tt.want == "" {
+                                if err != nil {
+                                        t.Errorf("VerifyAuditLog: %v", err)
+                                }
+                                return
+                        }
+                        if err == nil || !strings.Contains(err.Error(), tt.want) {
+                                t.Errorf("VerifyAuditLog = %v, want %q", err, tt.want)
+                        }
+                        if err := SetAuditFile(path); err == nil {
+                                t.Error("tampered audit file reopened")
+                        }
+                })
+        }
+}
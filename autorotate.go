@@ -0,0 +1,173 @@
+// File: autorotate.go
+// Description:
+// Automatic rotation of the log file. Rotation can be triggered by size
+// (SetMaxFileSize), by time (SetRotateInterval) or by both, in which case
+// whichever limit is reached first rotates the file. The conditions are
+// checked after every record written. SetIdleRotation seals the file once
+// nothing was written to it for a while.
+
+package logger
+
+import (
+        "os"
+        "sync/atomic"
+        "time"
+)
+
+var (
+        // Size in bytes above which the log file is rotated (0 disables)
+        maxFileSize int64
+
+        // Interval at which the log file is rotated (0 disables)
+        rotateInterval time.Duration
+
+        // Current size of the log file and time of the next interval rotation
+        logFileSize  int64
+        nextRotation time.Time
+
+        // Set while an automatic rotation is running (accessed atomically)
+        autoRotating int32
+
+        // Inactivity after which the log file is rotated (0 disables), the
+        // time of the last write and the pending check (guarded by outputsMu)
+        idleRotation  time.Duration
+        lastFileWrite time.Time
+        idleTimer     ClockTimer
+)
+
+// SetMaxFileSize rotates the log file once it grows beyond size bytes.
+// Zero (the default) disables size based rotation.
+func SetMaxFileSize(size int64) {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        maxFileSize = size
+}
+
+// SetRotateInterval rotates the log file every interval. Intervals that
+// divide a day are aligned to local midnight, so 24*time.Hour rotates at
+// midnight and time.Hour on the hour. Zero (the default) disables time
+// based rotation. It can be combined with SetMaxFileSize.
+func SetRotateInterval(interval time.Duration) {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        rotateInterval = interval
+        nextRotation = nextRotationAfter(now())
+}
+
+// resetRotationState records the size of a newly opened log file and
+// schedules its next interval rotation. The file counts as idle until it
+// is written to. outputsMu must be held.
+func resetRotationState(f *os.File, now time.Time) {
+        logFileSize = 0
+        lastFileWrite = time.Time{}
+        if f != nil {
+                if info, err := f.Stat(); err == nil {
+                        logFileSize = info.Size()
+                }
+        }
+        nextRotation = nextRotationAfter(now)
+}
+
+// nextRotationAfter returns the next interval boundary after now, or the
+// zero time when interval rotation is disabled; outputsMu must be held
+func nextRotationAfter(now time.Time) time.Time {
+        if rotateInterval <= 0 {
+                return time.Time{}
+        }
+        if 24*time.Hour%rotateInterval != 0 {
+                return now.Add(rotateInterval)
+        }
+        midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+        elapsed := now.Sub(midnight)
+        return midnight.Add((elapsed/rotateInterval + 1) * rotateInterval)
+}
+
+// rotationDue reports whether the log file has reached its size limit or
+// its rotation time
+func rotationDue(now time.Time) bool {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        if logFile == nil {
+                return false
+        }
+        if maxFileSize > 0 && logFileSize >= maxFileSize {
+                return true
+        }
+        return !nextRotation.IsZero() && !now.Before(nextRotation)
+}
+
+// autoRotate rotates the log file if one of the configured limits was
+// reached. Only one goroutine rotates at a time; the others keep writing.
+func autoRotate(now time.Time) {
+        if !rotationDue(now) {
+                return
+        }
+        if !atomic.CompareAndSwapInt32(&autoRotating, 0, 1) {
+                return
+        }
+        defer atomic.StoreInt32(&autoRotating, 0)
+
+        // Another goroutine may have rotated in the meantime
+        if !rotationDue(now) {
+                return
+        }
+        if err := RotateLogFile(); err != nil {
+                reportError(err)
+        }
+}
+
+// SetIdleRotation rotates the log file once nothing was written to it for
+// d, so downstream processors know the rotated file is complete. Unlike
+// RotateLogFile it doesn't log the rotation, which would leave a record in
+// the fresh file. Zero (the default) disables idle rotation.
+func SetIdleRotation(d time.Duration) {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        idleRotation = d
+        if idleTimer != nil {
+                idleTimer.Stop()
+                idleTimer = nil
+        }
+        if d > 0 && !lastFileWrite.IsZero() {
+                scheduleIdleRotation()
+        }
+}
+
+// noteFileWrite records a write to the log file for idle rotation;
+// outputsMu must be held
+func noteFileWrite() {
+        lastFileWrite = now()
+        scheduleIdleRotation()
+}
+
+// scheduleIdleRotation arms the idle check unless it is already pending;
+// outputsMu must be held
+func scheduleIdleRotation() {
+        if idleRotation <= 0 || idleTimer != nil {
+                return
+        }
+        wait := idleRotation - since(lastFileWrite)
+        idleTimer = afterFunc(wait, checkIdleRotation)
+}
+
+// checkIdleRotation rotates the log file if it has been idle long enough,
+// otherwise waits for the remaining time
+func checkIdleRotation() {
+        outputsMu.Lock()
+        idleTimer = nil
+        if idleRotation <= 0 || logFile == nil || lastFileWrite.IsZero() {
+                outputsMu.Unlock()
+                return
+        }
+        if since(lastFileWrite) < idleRotation {
+                scheduleIdleRotation()
+                outputsMu.Unlock()
+                return
+        }
+        lastFileWrite = time.Time{}
+        outputsMu.Unlock()
+
+        if err := rotateLogFile(false); err != nil {
+                reportError(err)
+        }
+}
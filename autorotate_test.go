@@ -0,0 +1,170 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "fmt"
+        "strings"
+        "sync/atomic"
+        "testing"
+        "time"
+)
+
+func TestSizeAndTimeRotation(t *testing.T) {
+        type step struct {
+                advance time.Duration // Clock advance before the record
+                size    int           // Message size of the record
+                want    int           // Rotations so far
+        }
+        tests := []struct {
+                name     string
+                maxSize  int64
+                interval time.Duration
+                steps    []step
+        }{
+                {
+                        name:    "size only",
+                        maxSize: 1000,
+                        steps: []step{
+                                {size: 10, want: 0},
+                                {advance: 48 * time.Hour, size: 10, want: 0},
+                                {size: 2000, want: 1},
+                                {size: 10, want: 1},
+                        },
+                },
+                {
+                        name:     "time only",
+                        interval: 24 * time.Hour,
+                        steps: []step{
+                                {size: 2000, want: 0},
+                                {advance: 13 * time.Hour, size: 10, want: 0},
+                                {advance: time.Hour, size: 10, want: 1}, // Midnight
+                                {advance: time.Hour, size: 10, want: 1},
+                        },
+                },
+                {
+                        name:     "size first, then time",
+                        maxSize:  1000,
+                        interval: 24 * time.Hour,
+                        steps: []step{
+                                {size: 2000, want: 1},
+                                {advance: 10 * time.Hour, size: 10, want: 1},
+                                {advance: 4 * time.Hour, size: 10, want: 2},
+                        },
+                },
+                {
+                        name:     "time first, then size",
+                        maxSize:  1000,
+                        interval: 24 * time.Hour,
+                        steps: []step{
+                                {advance: 14 * time.Hour, size: 10, want: 1},
+                                {size: 10, want: 1},
+                                {size: 2000, want: 2},
+                        },
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        clock := useFakeClock(t) // 10:00 UTC
+                        if err := InitLogger(LevelInfo, true, tempLogPath(t, "app.log")); err != nil {
+                                t.Fatal(err)
+                        }
+                        var rotations int32
+                        SetOnRotate(func(oldPath, newPath string) { atomic.AddInt32(&rotations, 1) })
+                        SetMaxFileSize(tt.maxSize)
+                        SetRotateInterval(tt.interval)
+                        for i, s := range tt.steps {
+                                clock.Advance(s.advance)
+                                Info(strings.Repeat("x", s.size))
+                                if got := atomic.LoadInt32(&rotations); int(got) != s.want {
+                                        t.Fatalf("step %d: %d rotations, want %d", i, got, s.want)
+                                }
+                        }
+                })
+        }
+}
+
+func TestIdleRotation(t *testing.T) {
+        type step struct {
+                advance time.Duration // Clock advance before the step
+                write   bool          // Log a record after advancing
+                want    int           // Rotations so far
+        }
+        tests := []struct {
+                name  string
+                idle  time.Duration
+                steps []step
+        }{
+                {
+                        name: "idle period",
+                        idle: 5 * time.Minute,
+                        steps: []step{
+                                {write: true, want: 0},
+                                {advance: 4 * time.Minute, want: 0},
+                                {advance: time.Minute, want: 1},
+                                {advance: time.Hour, want: 1}, // The fresh file is empty
+                        },
+                },
+                {
+                        name: "writes postpone the rotation",
+                        idle: 5 * time.Minute,
+                        steps: []step{
+                                {write: true, want: 0},
+                                {advance: 3 * time.Minute, write: true, want: 0},
+                                {advance: 3 * time.Minute, want: 0},
+                                {advance: 2 * time.Minute, want: 1},
+                        },
+                },
+                {
+                        name: "writes after a rotation",
+                        idle: 5 * time.Minute,
+                        steps: []step{
+                                {write: true, want: 0},
+                                {advance: 5 * time.Minute, want: 1},
+                                {advance: time.Minute, write: true, want: 1},
+                                {advance: 5 * time.Minute, want: 2},
+                        },
+                },
+                {
+                        name: "disabled",
+                        steps: []step{
+                                {write: true, want: 0},
+                                {advance: 24 * time.Hour, want: 0},
+                        },
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        clock := useFakeClock(t)
+                        path := tempLogPath(t, "app.log")
+                        if err := InitLogger(LevelInfo, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        var archives []string
+                        SetOnRotate(func(oldPath, newPath string) { archives = append(archives, oldPath) })
+                        SetIdleRotation(tt.idle)
+                        for i, s := range tt.steps {
+                                clock.Advance(s.advance)
+                                if s.write {
+                                        Info(fmt.Sprintf("record %d", i))
+                                }
+                                if len(archives) != s.want {
+                                        t.Fatalf("step %d: %d rotations, want %d", i, len(archives), s.want)
+                                }
+                        }
+                        for _, archive := range archives {
+                                if readLog(t, archive) == "" {
+                                        t.Errorf("empty file %s rotated", archive)
+                                }
+                        }
+                        if len(archives) > 0 {
+                                // Idle rotation doesn't announce itself in the fresh file
+                                if got := readLog(t, path); got != "" {
+                                        t.Errorf("active file %q after idle rotation", got)
+                                }
+                        }
+                })
+        }
+}
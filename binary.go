@@ -0,0 +1,93 @@
+// File: binary.go
+// Description:
+// Rendering of binary payloads. Byte slices passed as log arguments or field
+// values are written as hex (default) or base64 instead of a slice of ints,
+// and long messages/payloads can be truncated.
+
+package logger
+
+import (
+        "encoding/base64"
+        "encoding/hex"
+        "fmt"
+        "sync/atomic"
+)
+
+// Binary formats
+const (
+        BinaryHex = iota
+        BinaryBase64
+)
+
+var (
+        // Encoding used for []byte arguments (accessed atomically)
+        binaryFormat int32 = BinaryHex
+
+        // Maximum message length in bytes, 0 for unlimited (accessed atomically)
+        maxMessageLength int64
+)
+
+// SetBinaryFormat selects how []byte arguments are rendered (BinaryHex or BinaryBase64)
+func SetBinaryFormat(format int) {
+        atomic.StoreInt32(&binaryFormat, int32(format))
+}
+
+// SetMaxMessageLength truncates messages, and binary payloads within them,
+// to at most n bytes. Zero disables truncation.
+func SetMaxMessageLength(n int) {
+        atomic.StoreInt64(&maxMessageLength, int64(n))
+}
+
+// binary wraps a byte slice so %v and %s render it encoded while other
+// verbs (%x, %q, ...) keep their usual meaning
+type binary []byte
+
+// Format implements fmt.Formatter
+func (b binary) Format(f fmt.State, verb rune) {
+        if verb != 'v' && verb != 's' {
+                fmt.Fprintf(f, fmt.FormatString(f, verb), []byte(b))
+                return
+        }
+        f.Write([]byte(encodeBinary(b)))
+}
+
+// encodeBinary renders a payload in the configured format, truncated to the
+// maximum message length
+func encodeBinary(b []byte) string {
+        data, suffix := b, ""
+        if max := int(atomic.LoadInt64(&maxMessageLength)); max > 0 && len(data) > max {
+                data = data[:max]
+                suffix = fmt.Sprintf("...(%d bytes)", len(b))
+        }
+
+        if atomic.LoadInt32(&binaryFormat) == BinaryBase64 {
+                return base64.StdEncoding.EncodeToString(data) + suffix
+        }
+        return hex.EncodeToString(data) + suffix
+}
+
+// wrapBinaryArgs replaces []byte arguments with their binary wrapper
+func wrapBinaryArgs(v []interface{}) []interface{} {
+        var wrapped []interface{}
+        for i, arg := range v {
+                if b, ok := arg.([]byte); ok {
+                        if wrapped == nil {
+                                wrapped = make([]interface{}, len(v))
+                                copy(wrapped, v)
+                        }
+                        wrapped[i] = binary(b)
+                }
+        }
+        if wrapped == nil {
+                return v
+        }
+        return wrapped
+}
+
+// truncateMessage cuts msg to the maximum message length
+func truncateMessage(msg string) string {
+        if max := int(atomic.LoadInt64(&maxMessageLength)); max > 0 && len(msg) > max {
+                return msg[:max] + "...(truncated)"
+        }
+        return msg
+}
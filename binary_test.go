@@ -0,0 +1,36 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "strings"
+        "testing"
+)
+
+func TestBinaryArgs(t *testing.T) {
+        payload := []byte{0xde, 0xad, 0xbe, 0xef}
+        tests := []struct {
+                name   string
+                format int
+                maxLen int
+                log    func()
+                want   string
+        }{
+                {"hex", BinaryHex, 0, func() { Info("payload:", payload) }, "payload:deadbeef"},
+                {"hex with %v", BinaryHex, 0, func() { Infof("payload=%v", payload) }, "payload=deadbeef"},
+                {"base64", BinaryBase64, 0, func() { Info("payload:", payload) }, "payload:3q2+7w=="},
+                {"other verbs unchanged", BinaryBase64, 0, func() { Infof("payload=%X", payload) }, "payload=DEADBEEF"},
+                {"truncated", BinaryHex, 4, func() { Infof("%s", payload) }, "dead...(truncated)"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetBinaryFormat(tt.format)
+                        SetMaxMessageLength(tt.maxLen)
+                        tt.log()
+                        if got := out.String(); !strings.Contains(got, tt.want) {
+                                t.Errorf("output %q lacks %q", got, tt.want)
+                        }
+                })
+        }
+}
@@ -0,0 +1,40 @@
+//go:build !js && !wasip1 && !plan9
+
+// File: brokenpipe.go
+// Description:
+// Handling of a console whose reader went away, e.g. `app | head`. The Go
+// runtime kills a process writing to a broken stdout pipe unless SIGPIPE is
+// ignored, so the logger ignores it and, on EPIPE, stops writing to the
+// console while the other outputs carry on.
+
+package logger
+
+import (
+        "errors"
+        "io"
+        "os"
+        "os/signal"
+        "sync"
+        "syscall"
+)
+
+// Ignores SIGPIPE once stdout is written to
+var ignoreSIGPIPE sync.Once
+
+// guardBrokenPipe makes writes to a broken stdout fail with EPIPE instead
+// of terminating the process
+func guardBrokenPipe() {
+        ignoreSIGPIPE.Do(func() {
+                signal.Ignore(syscall.SIGPIPE)
+        })
+}
+
+// isBrokenPipe reports whether err means the reader of the output is gone
+func isBrokenPipe(err error) bool {
+        return errors.Is(err, syscall.EPIPE) || errors.Is(err, os.ErrClosed)
+}
+
+// dropConsole stops writing to a broken console; outputsMu must be held
+func dropConsole() {
+        consoleWriter = io.Discard
+}
@@ -0,0 +1,27 @@
+//go:build js || wasip1 || plan9
+
+// File: brokenpipe_other.go
+// Description:
+// Broken console handling for platforms without SIGPIPE and EPIPE: only a
+// closed console is detected.
+
+package logger
+
+import (
+        "errors"
+        "io"
+        "os"
+)
+
+// guardBrokenPipe does nothing where there is no SIGPIPE
+func guardBrokenPipe() {}
+
+// isBrokenPipe reports whether err means the reader of the output is gone
+func isBrokenPipe(err error) bool {
+        return errors.Is(err, os.ErrClosed)
+}
+
+// dropConsole stops writing to a broken console; outputsMu must be held
+func dropConsole() {
+        consoleWriter = io.Discard
+}
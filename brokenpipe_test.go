@@ -0,0 +1,80 @@
+//go:build !logger_minimal && !js && !wasip1 && !plan9
+
+package logger
+
+import (
+        "fmt"
+        "io"
+        "os"
+        "strings"
+        "testing"
+)
+
+func TestBrokenConsole(t *testing.T) {
+        tests := []struct {
+                name    string
+                console func(t *testing.T) *os.File
+        }{
+                {
+                        name: "reader gone",
+                        console: func(t *testing.T) *os.File {
+                                r, w, err := os.Pipe()
+                                if err != nil {
+                                        t.Fatal(err)
+                                }
+                                r.Close()
+                                t.Cleanup(func() { w.Close() })
+                                return w
+                        },
+                },
+                {
+                        name: "closed console",
+                        console: func(t *testing.T) *os.File {
+                                _, w, err := os.Pipe()
+                                if err != nil {
+                                        t.Fatal(err)
+                                }
+                                w.Close()
+                                return w
+                        },
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        stderr := captureStderr(t)
+                        path := tempLogPath(t, "app.log")
+                        if err := InitLogger(LevelInfo, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        var hooked []string
+                        SetErrorHook(func(err error) { hooked = append(hooked, err.Error()) })
+                        SetOutput(tt.console(t))
+
+                        for i := 0; i < 3; i++ {
+                                Info(fmt.Sprintf("record %d", i))
+                        }
+                        CloseLogger()
+
+                        got := readLog(t, path)
+                        for i := 0; i < 3; i++ {
+                                if want := fmt.Sprintf("record %d", i); !strings.Contains(got, want) {
+                                        t.Errorf("log file lacks %q: %q", want, got)
+                                }
+                        }
+                        if disabled := strings.Count(strings.Join(hooked, "\n"), "disabled console output"); disabled != 1 {
+                                t.Errorf("console disabled %d times, hook got %q", disabled, hooked)
+                        }
+                        outputsMu.Lock()
+                        dropped := consoleWriter == io.Discard
+                        outputsMu.Unlock()
+                        if !dropped {
+                                t.Error("broken console still in use")
+                        }
+                        // Only the record that hit the broken console falls back to stderr
+                        if n := strings.Count(stderr(), "record "); n != 1 {
+                                t.Errorf("%d records on stderr, want 1", n)
+                        }
+                })
+        }
+}
@@ -0,0 +1,108 @@
+// File: buffered.go
+// Description:
+// Buffered logging. A BufferedContext holds the records logged through it
+// instead of writing them, so a request handler can decide at the end
+// whether they are worth keeping: Flush writes them (e.g. when the request
+// failed) and Discard drops them. Records keep the level, time and caller
+// of the original call.
+
+package logger
+
+import "sync"
+
+// BufferedContext collects records until they are flushed or discarded
+type BufferedContext struct {
+        mu      sync.Mutex
+        records []*Record
+
+        // Hold records below the log level too (request logs)
+        allLevels bool
+}
+
+// BeginBuffered returns a context whose records are held back until Flush
+// or Discard. Levels are checked when logging, as usual.
+func BeginBuffered() *BufferedContext {
+        return &BufferedContext{}
+}
+
+// logBuffered logs a message with the caller info into buf
+func logBuffered(buf *BufferedContext, level int, fields Fields, format string, v ...interface{}) {
+        if level < minEnabledLevel() {
+                return
+        }
+        emit(buf, 0, level, fields, format, v...)
+}
+
+// add holds a record until Flush or Discard
+func (b *BufferedContext) add(rec *Record) {
+        b.mu.Lock()
+        defer b.mu.Unlock()
+        b.records = append(b.records, rec)
+}
+
+// Flush writes the held records, in the order they were logged, and
+// empties the buffer
+func (b *BufferedContext) Flush() {
+        b.mu.Lock()
+        records := b.records
+        b.records = nil
+        b.mu.Unlock()
+
+        for _, rec := range records {
+                writeRecord(rec)
+        }
+}
+
+// Discard drops the held records
+func (b *BufferedContext) Discard() {
+        b.mu.Lock()
+        defer b.mu.Unlock()
+        b.records = nil
+}
+
+// Len returns the number of records held
+func (b *BufferedContext) Len() int {
+        b.mu.Lock()
+        defer b.mu.Unlock()
+        return len(b.records)
+}
+
+// Info buffers an info message
+func (b *BufferedContext) Info(v ...interface{}) {
+        logBuffered(b, LevelInfo, nil, "", v...)
+}
+
+// Infof buffers a formatted info message
+func (b *BufferedContext) Infof(format string, v ...interface{}) {
+        logBuffered(b, LevelInfo, nil, format, v...)
+}
+
+// Warning buffers a warning message
+func (b *BufferedContext) Warning(v ...interface{}) {
+        logBuffered(b, LevelWarning, nil, "", v...)
+}
+
+// Warningf buffers a formatted warning message
+func (b *BufferedContext) Warningf(format string, v ...interface{}) {
+        logBuffered(b, LevelWarning, nil, format, v...)
+}
+
+// Error buffers an error message
+func (b *BufferedContext) Error(v ...interface{}) {
+        logBuffered(b, LevelError, nil, "", v...)
+}
+
+// Errorf buffers a formatted error message
+func (b *BufferedContext) Errorf(format string, v ...interface{}) {
+        logBuffered(b, LevelError, nil, format, v...)
+}
+
+// Infow buffers an info message with alternating key/value pairs
+func (b *BufferedContext) Infow(msg string, keysAndValues ...interface{}) {
+        logBuffered(b, LevelInfo, kvToFields(keysAndValues), "", msg)
+}
+
+// Errorw buffers an error message with alternating key/value pairs
+func (b *BufferedContext) Errorw(msg string, keysAndValues ...interface{}) {
+        logBuffered(b, LevelError, kvToFields(keysAndValues), "", msg)
+}
@@ -0,0 +1,78 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "fmt"
+        "testing"
+        "time"
+)
+
+func TestBufferedContext(t *testing.T) {
+        type want struct {
+                level, time, message string
+                line                 int
+        }
+        tests := []struct {
+                name  string
+                flush bool
+        }{
+                {name: "flush", flush: true},
+                {name: "discard"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        clock := useFakeClock(t)
+                        SetFormat(FormatJSON)
+
+                        buf := BeginBuffered()
+                        var wants []want
+                        buf.Info("starting")
+                        wants = append(wants, want{"info", "2023-03-08T10:00:00Z", "starting", thisLine() - 1})
+                        clock.Advance(2 * time.Second)
+                        buf.Warningf("slow %s", "backend")
+                        wants = append(wants, want{"warning", "2023-03-08T10:00:02Z", "slow backend", thisLine() - 1})
+                        clock.Advance(3 * time.Second)
+                        buf.Errorw("failed", "status", 502)
+                        wants = append(wants, want{"error", "2023-03-08T10:00:05Z", "failed", thisLine() - 1})
+                        clock.Advance(time.Minute)
+
+                        if got := out.String(); got != "" {
+                                t.Fatalf("buffered records written before flush: %q", got)
+                        }
+                        if buf.Len() != len(wants) {
+                                t.Fatalf("Len() = %d, want %d", buf.Len(), len(wants))
+                        }
+                        if !tt.flush {
+                                buf.Discard()
+                                buf.Flush()
+                                if got := out.String(); got != "" {
+                                        t.Errorf("discarded records written: %q", got)
+                                }
+                                return
+                        }
+
+                        buf.Flush()
+                        records := out.Records(t)
+                        if len(records) != len(wants) {
+                                t.Fatalf("got %d records, want %d", len(records), len(wants))
+                        }
+                        for i, w := range wants {
+                                record := records[i]
+                                if record["level"] != w.level || record["time"] != w.time || record["message"] != w.message {
+                                        t.Errorf("record %d = %v, want level %s, time %s, message %q", i, record, w.level, w.time, w.message)
+                                }
+                                if caller := fmt.Sprintf("buffered_test.go:%d", w.line); record["caller"] != caller {
+                                        t.Errorf("record %d caller %v, want %s", i, record["caller"], caller)
+                                }
+                        }
+                        if records[2]["status"] != float64(502) {
+                                t.Errorf("fields lost: %v", records[2])
+                        }
+                        if buf.Len() != 0 {
+                                t.Errorf("Len() = %d after flush", buf.Len())
+                        }
+                })
+        }
+}
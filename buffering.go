@@ -0,0 +1,111 @@
+// File: buffering.go
+// Description:
+// Per-level buffering of the log file. Records of chatty levels can be
+// collected in memory and written in larger chunks, while important ones
+// are written straight away, taking the buffered records with them so the
+// file stays in order. Buffered records are written at the latest after a
+// second, and on rotation and close.
+
+package logger
+
+import (
+        "bytes"
+        "time"
+)
+
+// bufferFlushDelay is how long records may stay in the log file buffer
+const bufferFlushDelay = time.Second
+
+var (
+        // Bytes buffered before writing, per level; 0 writes through
+        // (guarded by outputsMu)
+        levelBuffer [LevelFatal + 1]int
+
+        // Records waiting to be written to the log file (guarded by outputsMu)
+        fileBuffer bytes.Buffer
+
+        // A flush of fileBuffer is scheduled (guarded by outputsMu)
+        bufferFlushPending bool
+)
+
+// SetLevelBuffer buffers log file records of the given level in memory
+// until size bytes are pending, e.g. 64 KiB for debug. Buffered records are
+// written at the latest a second later, or as soon as a record of a level
+// without buffering (size 0, the default for every level) is written, so
+// errors can bypass buffering entirely while debug output is batched.
+func SetLevelBuffer(level int, size int) {
+        if level < LevelDebug || level > LevelFatal {
+                return
+        }
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        levelBuffer[level] = size
+}
+
+// clearLevelBuffers writes any buffered records and disables buffering
+// for every level. A scheduled flush is forgotten: it may never run if the
+// clock was replaced, and would otherwise block later flushes.
+func clearLevelBuffers() {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        flushFileBuffer()
+        bufferFlushPending = false
+        levelBuffer = [LevelFatal + 1]int{}
+}
+
+// bufferedFileWriter writes records of a level to the log file through the
+// buffer; outputsMu must be held while writing
+type bufferedFileWriter struct {
+        level int
+}
+
+// Write implements io.Writer
+func (w bufferedFileWriter) Write(p []byte) (int, error) {
+        size := 0
+        if w.level >= LevelDebug && w.level <= LevelFatal {
+                size = levelBuffer[w.level]
+        }
+        if size <= 0 && fileBuffer.Len() == 0 {
+                return logFileWriter().Write(p)
+        }
+
+        fileBuffer.Write(p)
+        if fileBuffer.Len() < size {
+                scheduleBufferFlush()
+                return len(p), nil
+        }
+        return len(p), flushFileBuffer()
+}
+
+// flushFileBuffer writes the buffered records to the log file; outputsMu
+// must be held
+func flushFileBuffer() error {
+        if fileBuffer.Len() == 0 {
+                return nil
+        }
+        defer fileBuffer.Reset()
+        if logFile == nil {
+                return nil
+        }
+        _, err := logFileWriter().Write(fileBuffer.Bytes())
+        scheduleGzipFlush()
+        return err
+}
+
+// scheduleBufferFlush writes the buffered records shortly after a write;
+// outputsMu must be held
+func scheduleBufferFlush() {
+        if bufferFlushPending {
+                return
+        }
+        bufferFlushPending = true
+        afterFunc(bufferFlushDelay, func() {
+                outputsMu.Lock()
+                bufferFlushPending = false
+                err := flushFileBuffer()
+                outputsMu.Unlock()
+                if err != nil {
+                        reportError(err)
+                }
+        })
+}
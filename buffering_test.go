@@ -0,0 +1,59 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "fmt"
+        "strings"
+        "testing"
+        "time"
+)
+
+func TestLevelBuffer(t *testing.T) {
+        tests := []struct {
+                name     string
+                buffered int    // Buffer size of debug records
+                workload string // d, e: a record of that level; +: a second passes
+                want     string // Records in the log file afterwards, by workload index
+        }{
+                {name: "debug waits", buffered: 64 << 10, workload: "ddd", want: ""},
+                {name: "error immediate", buffered: 64 << 10, workload: "e", want: "e0"},
+                {name: "error takes debug along", buffered: 64 << 10, workload: "dde", want: "d0 d1 e2"},
+                {name: "flushed after a second", buffered: 64 << 10, workload: "dd+", want: "d0 d1"},
+                {name: "flushed when full", buffered: 200, workload: "dddd", want: "d0 d1 d2 d3"},
+                {name: "no buffering", workload: "dd", want: "d0 d1"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        requireDebug(t)
+                        captureOutput(t)
+                        clock := useFakeClock(t)
+                        path := tempLogPath(t, "app.log")
+                        if err := InitLogger(LevelDebug, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        SetLevelBuffer(LevelDebug, tt.buffered)
+                        for i, step := range tt.workload {
+                                msg := fmt.Sprintf("%c%d", step, i)
+                                switch step {
+                                case 'd':
+                                        Debug(msg)
+                                case 'e':
+                                        Error(msg)
+                                case '+':
+                                        clock.Advance(time.Second)
+                                }
+                        }
+
+                        var got []string
+                        for _, line := range strings.Split(strings.TrimSpace(readLog(t, path)), "\n") {
+                                if fields := strings.Fields(line); len(fields) > 0 {
+                                        got = append(got, fields[len(fields)-1])
+                                }
+                        }
+                        if strings.Join(got, " ") != tt.want {
+                                t.Errorf("log file holds %q, want %q", strings.Join(got, " "), tt.want)
+                        }
+                })
+        }
+}
@@ -0,0 +1,140 @@
+// File: clock.go
+// Description:
+// Clock of the package. Record timestamps, rotation (schedule, idle checks
+// and file names), rate limiting, level reverts, timers, retries and
+// delayed flushes all read the time and schedule their work through one
+// Clock, so time-dependent behavior is coherent and can be driven by a
+// fake clock in tests. Stats, which measures the logger itself (latency
+// and throughput), and network I/O (write deadlines and reconnect delays)
+// use the machine clock, as they deal with real elapsed time.
+
+package logger
+
+import (
+        "sync"
+        "sync/atomic"
+        "time"
+)
+
+// Clock is a source of time and timers. The default is the machine clock.
+type Clock interface {
+        // Now returns the current time
+        Now() time.Time
+
+        // AfterFunc calls f in its own goroutine once d has elapsed
+        AfterFunc(d time.Duration, f func()) ClockTimer
+}
+
+// ClockTimer is a timer started by Clock.AfterFunc
+type ClockTimer interface {
+        // Stop prevents the call if it hasn't happened yet and reports
+        // whether it did
+        Stop() bool
+}
+
+// clockHolder wraps the clock for atomic storage
+type clockHolder struct {
+        clock Clock
+}
+
+// Clock of the package, nil for the machine clock
+var packageClock atomic.Pointer[clockHolder]
+
+// SetClock makes c the clock of the package, e.g. a fake clock in tests
+// that fires timers as it is advanced. Passing nil restores the machine
+// clock.
+func SetClock(c Clock) {
+        if c == nil {
+                packageClock.Store(nil)
+                return
+        }
+        packageClock.Store(&clockHolder{c})
+}
+
+// SetTimeSource makes fn the source of the current time of the package,
+// keeping timers on the machine clock. Use SetClock to control timers as
+// well. Passing nil restores the machine clock.
+func SetTimeSource(fn func() time.Time) {
+        if fn == nil {
+                SetClock(nil)
+                return
+        }
+        SetClock(timeSourceClock(fn))
+}
+
+// timeSourceClock is a Clock reading the time from a function
+type timeSourceClock func() time.Time
+
+// Now implements Clock
+func (fn timeSourceClock) Now() time.Time {
+        return fn()
+}
+
+// AfterFunc implements Clock with a machine timer
+func (timeSourceClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+        return time.AfterFunc(d, f)
+}
+
+// now returns the current time of the package clock
+func now() time.Time {
+        if h := packageClock.Load(); h != nil {
+                return h.clock.Now()
+        }
+        return time.Now()
+}
+
+// since returns the time elapsed since t on the package clock
+func since(t time.Time) time.Duration {
+        return now().Sub(t)
+}
+
+// afterFunc calls f once d has elapsed on the package clock
+func afterFunc(d time.Duration, f func()) ClockTimer {
+        if h := packageClock.Load(); h != nil {
+                return h.clock.AfterFunc(d, f)
+        }
+        return time.AfterFunc(d, f)
+}
+
+// clockTicker sends on C at a fixed interval of the package clock,
+// dropping ticks the receiver isn't ready for
+type clockTicker struct {
+        C <-chan struct{}
+
+        c        chan struct{}
+        interval time.Duration
+        mu       sync.Mutex
+        timer    ClockTimer
+        stopped  bool
+}
+
+// newClockTicker starts a ticker with the given interval
+func newClockTicker(interval time.Duration) *clockTicker {
+        c := make(chan struct{}, 1)
+        t := &clockTicker{C: c, c: c, interval: interval}
+        t.mu.Lock()
+        t.timer = afterFunc(interval, t.tick)
+        t.mu.Unlock()
+        return t
+}
+
+// tick sends a tick and schedules the next one
+func (t *clockTicker) tick() {
+        select {
+        case t.c <- struct{}{}:
+        default:
+        }
+        t.mu.Lock()
+        defer t.mu.Unlock()
+        if !t.stopped {
+                t.timer = afterFunc(t.interval, t.tick)
+        }
+}
+
+// Stop stops the ticker
+func (t *clockTicker) Stop() {
+        t.mu.Lock()
+        defer t.mu.Unlock()
+        t.stopped = true
+        t.timer.Stop()
+}
@@ -0,0 +1,67 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "os"
+        "path/filepath"
+        "strings"
+        "testing"
+        "time"
+)
+
+func TestSetTimeSource(t *testing.T) {
+        tests := []struct {
+                name     string
+                at       time.Time
+                format   int
+                wantTime string // Timestamp of the record
+        }{
+                {name: "text", at: time.Date(2024, 2, 29, 23, 59, 58, 0, time.UTC), format: FormatText, wantTime: "2024/02/29 23:59:58"},
+                {name: "JSON", at: time.Date(2024, 2, 29, 23, 59, 58, 0, time.UTC), format: FormatJSON, wantTime: `"time":"2024-02-29T23:59:58Z"`},
+                {name: "other day", at: time.Date(1999, 12, 31, 12, 0, 0, 0, time.UTC), format: FormatJSON, wantTime: `"time":"1999-12-31T12:00:00Z"`},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetTimeSource(func() time.Time { return tt.at })
+                        path := tempLogPath(t, "app.log")
+                        if err := InitLogger(LevelInfo, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        SetFormat(tt.format)
+                        Info("tick")
+                        if got := out.String(); !strings.Contains(got, tt.wantTime) {
+                                t.Errorf("got %q, want the time %s", got, tt.wantTime)
+                        }
+
+                        if err := rotateLogFile(false); err != nil {
+                                t.Fatal(err)
+                        }
+                        archive := filepath.Join(filepath.Dir(path), defaultRotateName("app", ".log", tt.at))
+                        if _, err := os.Stat(archive); err != nil {
+                                t.Errorf("rotated file not named after the injected time: %v", err)
+                        }
+                })
+        }
+}
+
+func TestSetTimeSourceNil(t *testing.T) {
+        out := captureOutput(t)
+        SetFormat(FormatJSON)
+        SetTimeSource(func() time.Time { return time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC) })
+        SetTimeSource(nil)
+        before := time.Now()
+        Info("tick")
+        records := out.Records(t)
+        if len(records) != 1 {
+                t.Fatalf("got %d records, want 1", len(records))
+        }
+        at, err := time.Parse(time.RFC3339Nano, records[0]["time"].(string))
+        if err != nil {
+                t.Fatal(err)
+        }
+        if at.Before(before.Add(-time.Second)) || at.After(time.Now().Add(time.Second)) {
+                t.Errorf("record time %v, want the machine time %v", at, before)
+        }
+}
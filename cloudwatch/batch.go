@@ -0,0 +1,133 @@
+// File: batch.go
+// Description:
+// Batching of log records into CloudWatch Logs PutLogEvents calls. Records
+// are queued and delivered when a batch would exceed the service limits
+// (1MB / 10,000 events) or on a fixed interval, whichever comes first.
+
+package cloudwatch
+
+import (
+        "fmt"
+        "os"
+        "strings"
+        "sync"
+        "time"
+)
+
+// Limits imposed by PutLogEvents
+const (
+        maxBatchEvents = 10000
+        maxBatchBytes  = 1048576
+        eventOverhead  = 26 // Bytes counted per event on top of the message
+)
+
+// flushInterval is how often queued events are delivered
+var flushInterval = 5 * time.Second
+
+// event is a single queued log event
+type event struct {
+        timestamp int64 // Milliseconds since the epoch
+        message   string
+}
+
+// batcher queues events and hands them to put in batches
+type batcher struct {
+        mu     sync.Mutex
+        events []event
+        size   int
+        closed bool
+
+        put  func(events []event) error
+        stop chan struct{}
+        done chan struct{}
+}
+
+// newBatcher starts a batcher delivering through put
+func newBatcher(put func(events []event) error) *batcher {
+        b := &batcher{
+                put:  put,
+                stop: make(chan struct{}),
+                done: make(chan struct{}),
+        }
+        go b.loop()
+        return b
+}
+
+// loop flushes queued events periodically until the batcher is closed
+func (b *batcher) loop() {
+        defer close(b.done)
+        ticker := time.NewTicker(flushInterval)
+        defer ticker.Stop()
+        for {
+                select {
+                case <-ticker.C:
+                        b.Flush()
+                case <-b.stop:
+                        return
+                }
+        }
+}
+
+// Write queues one record; a full batch is delivered first
+func (b *batcher) Write(p []byte) (int, error) {
+        msg := strings.TrimRight(string(p), "\n")
+        if msg == "" {
+                return len(p), nil
+        }
+        if len(msg)+eventOverhead > maxBatchBytes {
+                msg = msg[:maxBatchBytes-eventOverhead]
+        }
+
+        b.mu.Lock()
+        defer b.mu.Unlock()
+        if b.closed {
+                return 0, os.ErrClosed
+        }
+
+        size := len(msg) + eventOverhead
+        if len(b.events)+1 > maxBatchEvents || b.size+size > maxBatchBytes {
+                b.flushLocked()
+        }
+        b.events = append(b.events, event{
+                timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+                message:   msg,
+        })
+        b.size += size
+        return len(p), nil
+}
+
+// Flush delivers every queued event
+func (b *batcher) Flush() {
+        b.mu.Lock()
+        defer b.mu.Unlock()
+        b.flushLocked()
+}
+
+// flushLocked delivers the queued events; b.mu must be held
+func (b *batcher) flushLocked() {
+        if len(b.events) == 0 {
+                return
+        }
+        events := b.events
+        b.events = nil
+        b.size = 0
+        if err := b.put(events); err != nil {
+                fmt.Fprintf(os.Stderr, "cloudwatch: failed to put %d log events: %v\n", len(events), err)
+        }
+}
+
+// Close delivers the remaining events and stops the flush loop
+func (b *batcher) Close() error {
+        b.mu.Lock()
+        if b.closed {
+                b.mu.Unlock()
+                return nil
+        }
+        b.closed = true
+        b.flushLocked()
+        b.mu.Unlock()
+
+        close(b.stop)
+        <-b.done
+        return nil
+}
@@ -0,0 +1,110 @@
+// File: cloudwatch.go
+// Description:
+// Package cloudwatch delivers logger records to an AWS CloudWatch Logs
+// stream. The output talks to the service through the narrow Client
+// interface; the adapter for the AWS SDK is in sdk.go behind the
+// cloudwatch_sdk build tag, so the SDK is only a dependency for
+// applications that build with it.
+
+package cloudwatch
+
+import (
+        "errors"
+        "fmt"
+
+        "github.com/tisoportes/logger"
+)
+
+// Event is a log event sent to CloudWatch Logs
+type Event struct {
+        Timestamp int64 // Milliseconds since the epoch
+        Message   string
+}
+
+// ErrStreamExists is returned by Client.CreateLogStream when the stream
+// already exists
+var ErrStreamExists = errors.New("cloudwatch: log stream already exists")
+
+// InvalidSequenceTokenError is returned by Client.PutLogEvents when the
+// sequence token is stale
+type InvalidSequenceTokenError struct {
+        Expected *string // Sequence token the service expects
+}
+
+// Error implements error
+func (e *InvalidSequenceTokenError) Error() string {
+        expected := "<nil>"
+        if e.Expected != nil {
+                expected = *e.Expected
+        }
+        return fmt.Sprintf("cloudwatch: invalid sequence token, expected %s", expected)
+}
+
+// Client is the part of the CloudWatch Logs API the output uses
+type Client interface {
+        // CreateLogStream creates a log stream, returning ErrStreamExists if
+        // it already exists
+        CreateLogStream(group, stream string) error
+
+        // PutLogEvents sends a batch of events after the given sequence token
+        // and returns the next token; a stale token is reported as an
+        // *InvalidSequenceTokenError
+        PutLogEvents(group, stream string, events []Event, token *string) (*string, error)
+}
+
+// output sends batches of JSON records to one log stream
+type output struct {
+        *batcher
+        client Client
+        group  string
+        stream string
+        token  *string
+}
+
+// AddClientOutput adds an output delivering JSON records to the given
+// CloudWatch Logs group and stream through client, creating the stream if
+// needed. Records are batched and flushed periodically and when the logger
+// is closed.
+func AddClientOutput(client Client, group, stream string) error {
+        o, err := newOutput(client, group, stream)
+        if err != nil {
+                return err
+        }
+        logger.AddOutput(o, logger.FormatJSON)
+        return nil
+}
+
+// newOutput creates the log stream (if missing) and starts batching
+func newOutput(client Client, group, stream string) (*output, error) {
+        if err := client.CreateLogStream(group, stream); err != nil && !errors.Is(err, ErrStreamExists) {
+                return nil, err
+        }
+
+        o := &output{client: client, group: group, stream: stream}
+        o.batcher = newBatcher(o.put)
+        return o, nil
+}
+
+// put sends one batch, retrying once with the sequence token expected by
+// the service if ours is stale
+func (o *output) put(events []event) error {
+        batch := make([]Event, len(events))
+        for i, e := range events {
+                batch[i] = Event{Timestamp: e.timestamp, Message: e.message}
+        }
+
+        for attempt := 0; ; attempt++ {
+                next, err := o.client.PutLogEvents(o.group, o.stream, batch, o.token)
+                if err == nil {
+                        o.token = next
+                        return nil
+                }
+
+                var invalid *InvalidSequenceTokenError
+                if attempt == 0 && errors.As(err, &invalid) {
+                        o.token = invalid.Expected
+                        continue
+                }
+                return err
+        }
+}
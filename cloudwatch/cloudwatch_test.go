@@ -0,0 +1,128 @@
+package cloudwatch
+
+import (
+        "strings"
+        "sync"
+        "testing"
+)
+
+// mockClient records the batches sent to it
+type mockClient struct {
+        mu       sync.Mutex
+        batches  [][]Event
+        tokens   []*string
+        exists   bool
+        staleOne bool // Reject the first put with a stale token
+}
+
+func (c *mockClient) CreateLogStream(group, stream string) error {
+        if c.exists {
+                return ErrStreamExists
+        }
+        return nil
+}
+
+func (c *mockClient) PutLogEvents(group, stream string, events []Event, token *string) (*string, error) {
+        c.mu.Lock()
+        defer c.mu.Unlock()
+        c.tokens = append(c.tokens, token)
+        if c.staleOne {
+                c.staleOne = false
+                expected := "expected"
+                return nil, &InvalidSequenceTokenError{Expected: &expected}
+        }
+        c.batches = append(c.batches, append([]Event(nil), events...))
+        next := strings.Repeat("t", len(c.batches))
+        return &next, nil
+}
+
+func (c *mockClient) sizes() []int {
+        c.mu.Lock()
+        defer c.mu.Unlock()
+        var sizes []int
+        for _, b := range c.batches {
+                sizes = append(sizes, len(b))
+        }
+        return sizes
+}
+
+func TestBatching(t *testing.T) {
+        big := strings.Repeat("x", 400*1024)
+        tests := []struct {
+                name    string
+                records []string
+                want    []int // Events per batch, after Close
+        }{
+                {"single batch", []string{"a", "b", "c"}, []int{3}},
+                {"event limit", repeat("r", maxBatchEvents+5), []int{maxBatchEvents, 5}},
+                {"size limit", []string{big, big, big}, []int{2, 1}},
+                {"empty records skipped", []string{"a", "\n", "b"}, []int{2}},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        client := &mockClient{}
+                        o, err := newOutput(client, "group", "stream")
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        for _, r := range tt.records {
+                                if _, err := o.Write([]byte(r + "\n")); err != nil {
+                                        t.Fatal(err)
+                                }
+                        }
+                        o.Close()
+                        got := client.sizes()
+                        if len(got) != len(tt.want) {
+                                t.Fatalf("batches = %v, want %v", got, tt.want)
+                        }
+                        for i := range got {
+                                if got[i] != tt.want[i] {
+                                        t.Fatalf("batches = %v, want %v", got, tt.want)
+                                }
+                        }
+                })
+        }
+}
+
+func TestSequenceToken(t *testing.T) {
+        client := &mockClient{exists: true, staleOne: true}
+        o, err := newOutput(client, "group", "stream")
+        if err != nil {
+                t.Fatalf("existing stream: %v", err)
+        }
+        o.Write([]byte("first\n"))
+        o.Flush()
+        o.Write([]byte("second\n"))
+        o.Close()
+
+        // Stale token, retry with the expected one, then the returned one
+        want := []string{"<nil>", "expected", "t"}
+        if len(client.tokens) != len(want) {
+                t.Fatalf("%d puts, want %d", len(client.tokens), len(want))
+        }
+        for i, tok := range client.tokens {
+                got := "<nil>"
+                if tok != nil {
+                        got = *tok
+                }
+                if got != want[i] {
+                        t.Errorf("put %d token = %q, want %q", i, got, want[i])
+                }
+        }
+}
+
+func TestWriteAfterClose(t *testing.T) {
+        o, _ := newOutput(&mockClient{}, "group", "stream")
+        o.Close()
+        if _, err := o.Write([]byte("late\n")); err == nil {
+                t.Error("write after close succeeded")
+        }
+}
+
+func repeat(s string, n int) []string {
+        out := make([]string, n)
+        for i := range out {
+                out[i] = s
+        }
+        return out
+}
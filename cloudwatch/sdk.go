@@ -0,0 +1,67 @@
+//go:build cloudwatch_sdk
+
+// File: sdk.go
+// Description:
+// Adapter between the output and the AWS SDK CloudWatch Logs client, built
+// with the cloudwatch_sdk tag.
+
+package cloudwatch
+
+import (
+        "errors"
+
+        "github.com/aws/aws-sdk-go/aws"
+        "github.com/aws/aws-sdk-go/aws/session"
+        "github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// sdkClient implements Client with the AWS SDK
+type sdkClient struct {
+        api *cloudwatchlogs.CloudWatchLogs
+}
+
+// AddCloudWatchOutput adds an output delivering JSON records to the given
+// CloudWatch Logs group and stream, creating the stream if needed. Records
+// are batched and flushed periodically and when the logger is closed.
+func AddCloudWatchOutput(group, stream string, sess *session.Session) error {
+        return AddClientOutput(sdkClient{cloudwatchlogs.New(sess)}, group, stream)
+}
+
+// CreateLogStream implements Client
+func (c sdkClient) CreateLogStream(group, stream string) error {
+        _, err := c.api.CreateLogStream(&cloudwatchlogs.CreateLogStreamInput{
+                LogGroupName:  aws.String(group),
+                LogStreamName: aws.String(stream),
+        })
+        var exists *cloudwatchlogs.ResourceAlreadyExistsException
+        if errors.As(err, &exists) {
+                return ErrStreamExists
+        }
+        return err
+}
+
+// PutLogEvents implements Client
+func (c sdkClient) PutLogEvents(group, stream string, events []Event, token *string) (*string, error) {
+        input := &cloudwatchlogs.PutLogEventsInput{
+                LogGroupName:  aws.String(group),
+                LogStreamName: aws.String(stream),
+                LogEvents:     make([]*cloudwatchlogs.InputLogEvent, len(events)),
+                SequenceToken: token,
+        }
+        for i, e := range events {
+                input.LogEvents[i] = &cloudwatchlogs.InputLogEvent{
+                        Timestamp: aws.Int64(e.Timestamp),
+                        Message:   aws.String(e.Message),
+                }
+        }
+
+        resp, err := c.api.PutLogEvents(input)
+        if err != nil {
+                var invalid *cloudwatchlogs.InvalidSequenceTokenException
+                if errors.As(err, &invalid) {
+                        return nil, &InvalidSequenceTokenError{Expected: invalid.ExpectedSequenceToken}
+                }
+                return nil, err
+        }
+        return resp.NextSequenceToken, nil
+}
@@ -0,0 +1,46 @@
+// File: collapse.go
+// Description:
+// Collapsing of repeated callers on the console. In tight loops every line
+// carries the same file:line; with collapsing enabled, text lines from the
+// same call site as the previous console line show a short continuation
+// marker instead. Files and other outputs keep the full caller.
+
+package logger
+
+// callerContinuation replaces a caller identical to the previous line's
+const callerContinuation = "^"
+
+var (
+        // Collapse repeated callers on the console (guarded by outputsMu)
+        collapseCaller bool
+
+        // Caller of the previous console record (guarded by outputsMu)
+        lastConsoleCaller string
+)
+
+// SetCollapseCaller replaces the caller segment of console text lines with
+// "^" when it is the same as the previous line's, keeping loop output
+// compact. Only the console in FormatText is affected.
+func SetCollapseCaller(enabled bool) {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        collapseCaller = enabled
+        lastConsoleCaller = ""
+}
+
+// collapsedRecord returns a copy of rec with the caller replaced by the
+// continuation marker if it repeats on the console, nil otherwise;
+// outputsMu must be held
+func collapsedRecord(rec *Record) *Record {
+        if !collapseCaller || consoleFormat != FormatText {
+                return nil
+        }
+        previous := lastConsoleCaller
+        lastConsoleCaller = rec.Caller
+        if rec.Caller == "" || rec.Caller != previous {
+                return nil
+        }
+        c := *rec
+        c.Caller = callerContinuation
+        return &c
+}
@@ -0,0 +1,84 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "fmt"
+        "strings"
+        "testing"
+)
+
+// logLoop logs n records from a single call site and returns its line
+func logLoop(n int) int {
+        line := thisLine() + 2
+        for i := 0; i < n; i++ {
+                Infof("iteration %d", i)
+        }
+        return line
+}
+
+func TestCollapseCaller(t *testing.T) {
+        tests := []struct {
+                name    string
+                enabled bool
+                format  int
+                want    []string // Console callers, "A" and "B" for the two call sites
+        }{
+                {name: "collapsed", enabled: true, format: FormatText, want: []string{"A", "^", "^", "B", "A", "^"}},
+                {name: "disabled", format: FormatText, want: []string{"A", "A", "A", "B", "A", "A"}},
+                {name: "JSON console", enabled: true, format: FormatJSON, want: []string{"A", "A", "A", "B", "A", "A"}},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        path := tempLogPath(t, "app.log")
+                        if err := InitLogger(LevelInfo, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        SetOutput(out)
+                        SetFormat(tt.format)
+                        SetFileFormat(FormatText)
+                        SetCollapseCaller(tt.enabled)
+                        lineA := logLoop(3)
+                        lineB := thisLine() + 1
+                        Info("between loops")
+                        logLoop(2)
+
+                        callers := map[string]string{
+                                "A": fmt.Sprintf("collapse_test.go:%d", lineA),
+                                "B": fmt.Sprintf("collapse_test.go:%d", lineB),
+                                "^": callerContinuation,
+                        }
+                        var got []string
+                        if tt.format == FormatJSON {
+                                for _, record := range out.Records(t) {
+                                        got = append(got, fmt.Sprint(record["caller"]))
+                                }
+                        } else {
+                                for _, line := range out.Lines() {
+                                        got = append(got, strings.Fields(line)[3])
+                                }
+                        }
+                        if len(got) != len(tt.want) {
+                                t.Fatalf("got %d console lines, want %d", len(got), len(tt.want))
+                        }
+                        for i, w := range tt.want {
+                                want := callers[w]
+                                if tt.format == FormatText {
+                                        want += ":"
+                                }
+                                if got[i] != want {
+                                        t.Errorf("console line %d caller %q, want %q", i, got[i], want)
+                                }
+                        }
+
+                        file := readLog(t, path)
+                        if strings.Contains(file, " "+callerContinuation+":") {
+                                t.Errorf("log file has collapsed callers:\n%s", file)
+                        }
+                        if n := strings.Count(file, callers["A"]+":"); n != 5 {
+                                t.Errorf("log file has %d full callers of the loop, want 5:\n%s", n, file)
+                        }
+                })
+        }
+}
@@ -0,0 +1,127 @@
+// File: compact.go
+// Description:
+// Compaction of rotated JSON logs. Records that are identical apart from
+// their timestamp are collapsed into their first occurrence with a "count"
+// field, which makes archives of noisy services much smaller at the cost of
+// some CPU at rotation time.
+
+package logger
+
+import (
+        "bufio"
+        "bytes"
+        "encoding/json"
+        "fmt"
+        "os"
+        "sync/atomic"
+)
+
+// CountKey is the field added to compacted records
+const CountKey = "count"
+
+var (
+        // Compact rotated files (accessed atomically)
+        compactOnRotate int32
+
+        // Timestamp key of JSON records (a string)
+        jsonTimeKey atomic.Value
+)
+
+// SetCompactOnRotate enables compaction of rotated files: JSON records that
+// only differ in their "time" field (or its name set with SetFieldKeys) are
+// collapsed into the first one, which
+// gets a "count" field with the number of occurrences. Lines that are not
+// JSON objects are kept as they are. The file is compacted in memory.
+func SetCompactOnRotate(enabled bool) {
+        var v int32
+        if enabled {
+                v = 1
+        }
+        atomic.StoreInt32(&compactOnRotate, v)
+}
+
+// compactRotated compacts a rotated file in place if compaction is enabled
+func compactRotated(path string) error {
+        if atomic.LoadInt32(&compactOnRotate) == 0 {
+                return nil
+        }
+        return compactFile(path)
+}
+
+// compactTimeKey returns the key of the timestamp in JSON records, as set
+// with SetFieldKeys. It doesn't lock outputsMu, which the rotation of
+// additional outputs holds.
+func compactTimeKey() string {
+        if key, ok := jsonTimeKey.Load().(string); ok {
+                return key
+        }
+        return "time"
+}
+
+// updateJSONTimeKey records the timestamp key of the JSON encoder for
+// compaction; outputsMu must be held
+func updateJSONTimeKey() {
+        enc, _ := encoders[FormatJSON].(JSONEncoder)
+        jsonTimeKey.Store(keyOr(enc.Keys.Time, "time"))
+}
+
+// compactFile collapses duplicate JSON records of a file
+func compactFile(path string) error {
+        timeKey := compactTimeKey()
+        f, err := os.Open(path)
+        if err != nil {
+                return err
+        }
+
+        type entry struct {
+                line  []byte
+                count int
+        }
+        var entries []*entry
+        seen := map[string]*entry{}
+
+        scanner := bufio.NewScanner(f)
+        scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+        for scanner.Scan() {
+                line := append([]byte(nil), scanner.Bytes()...)
+
+                // Records are keyed by their canonical form without the timestamp
+                var fields map[string]interface{}
+                if json.Unmarshal(line, &fields) != nil {
+                        entries = append(entries, &entry{line: line, count: 1})
+                        continue
+                }
+                delete(fields, timeKey)
+                key, _ := json.Marshal(fields)
+
+                if e, ok := seen[string(key)]; ok {
+                        e.count++
+                        continue
+                }
+                e := &entry{line: line, count: 1}
+                seen[string(key)] = e
+                entries = append(entries, e)
+        }
+        f.Close()
+        if err := scanner.Err(); err != nil {
+                return err
+        }
+
+        var buf bytes.Buffer
+        for _, e := range entries {
+                line := e.line
+                if e.count > 1 {
+                        // Only JSON objects are counted: drop their closing brace
+                        line = bytes.TrimSpace(line)
+                        line = append(line[:len(line)-1], fmt.Sprintf(",%q:%d}", CountKey, e.count)...)
+                }
+                buf.Write(line)
+                buf.WriteByte('\n')
+        }
+
+        tmp := path + ".tmp"
+        if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+                return err
+        }
+        return os.Rename(tmp, path)
+}
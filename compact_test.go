@@ -0,0 +1,163 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "encoding/json"
+        "os"
+        "path/filepath"
+        "strings"
+        "testing"
+        "time"
+)
+
+func TestCompactFile(t *testing.T) {
+        tests := []struct {
+                name string
+                in   []string
+                want []string
+        }{
+                {
+                        name: "repeats collapsed",
+                        in: []string{
+                                `{"time":"1","level":"info","message":"tick"}`,
+                                `{"time":"2","level":"info","message":"tick"}`,
+                                `{"time":"3","level":"info","message":"tick"}`,
+                        },
+                        want: []string{`{"time":"1","level":"info","message":"tick","count":3}`},
+                },
+                {
+                        name: "distinct records kept",
+                        in: []string{
+                                `{"time":"1","message":"a"}`,
+                                `{"time":"2","message":"b"}`,
+                                `{"time":"3","message":"a"}`,
+                        },
+                        want: []string{
+                                `{"time":"1","message":"a","count":2}`,
+                                `{"time":"2","message":"b"}`,
+                        },
+                },
+                {
+                        name: "nested objects",
+                        in: []string{
+                                `{"time":"1","message":"req","http":{"status":200,"path":"/"}}`,
+                                `{"time":"2","message":"req","http":{"status":200,"path":"/"}}`,
+                                `{"time":"3","message":"req","http":{"status":500,"path":"/"}}`,
+                        },
+                        want: []string{
+                                `{"time":"1","message":"req","http":{"status":200,"path":"/"},"count":2}`,
+                                `{"time":"3","message":"req","http":{"status":500,"path":"/"}}`,
+                        },
+                },
+                {
+                        name: "trailing spaces",
+                        in: []string{
+                                `{"time":"1","message":"a"}  `,
+                                `{"time":"2","message":"a"}`,
+                        },
+                        want: []string{`{"time":"1","message":"a","count":2}`},
+                },
+                {
+                        name: "text lines kept",
+                        in:   []string{"plain line", "plain line", `{"time":"1","message":"a"}`},
+                        want: []string{"plain line", "plain line", `{"time":"1","message":"a"}`},
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        path := tempLogPath(t, "app.log")
+                        if err := os.WriteFile(path, []byte(strings.Join(tt.in, "\n")+"\n"), 0644); err != nil {
+                                t.Fatal(err)
+                        }
+                        if err := compactFile(path); err != nil {
+                                t.Fatal(err)
+                        }
+                        got := strings.Split(strings.TrimSuffix(readLog(t, path), "\n"), "\n")
+                        if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+                                t.Fatalf("compacted to\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+                        }
+                        for _, line := range got {
+                                if strings.HasPrefix(line, "{") && !json.Valid([]byte(line)) {
+                                        t.Errorf("invalid JSON %s", line)
+                                }
+                        }
+                })
+        }
+}
+
+func TestCompactOnRotate(t *testing.T) {
+        captureOutput(t)
+        clock := useFakeClock(t)
+        path := tempLogPath(t, "app.log")
+        if err := InitLogger(LevelInfo, true, path); err != nil {
+                t.Fatal(err)
+        }
+        SetFileFormat(FormatJSON)
+        SetCompactOnRotate(true)
+        rotatedAt := clock.Now().Add(4 * time.Second)
+        for i := 0; i < 3; i++ {
+                clock.Advance(time.Second)
+                WithField("attempt", "retry").Warning("connection refused")
+        }
+        clock.Advance(time.Second)
+        Info("connected")
+        if err := rotateLogFile(false); err != nil {
+                t.Fatal(err)
+        }
+
+        archive := filepath.Join(filepath.Dir(path), defaultRotateName("app", ".log", rotatedAt))
+        var records []map[string]interface{}
+        for _, line := range strings.Split(strings.TrimSpace(readLog(t, archive)), "\n") {
+                var record map[string]interface{}
+                if err := json.Unmarshal([]byte(line), &record); err != nil {
+                        t.Fatalf("decoding %q: %v", line, err)
+                }
+                records = append(records, record)
+        }
+        if len(records) != 2 {
+                t.Fatalf("archive holds %d records, want 2", len(records))
+        }
+        if records[0]["message"] != "connection refused" || records[0][CountKey] != float64(3) {
+                t.Errorf("first record %v, want the repeats with count 3", records[0])
+        }
+        if _, ok := records[1][CountKey]; ok {
+                t.Errorf("single record %v has a count", records[1])
+        }
+}
+
+func TestCompactFieldKeys(t *testing.T) {
+        tests := []struct {
+                name string
+                keys FieldKeys
+                want []string
+        }{
+                {
+                        name: "custom time key",
+                        keys: FieldKeys{Time: "ts"},
+                        want: []string{`{"ts":"1","message":"tick","count":2}`, `{"ts":"3","message":"tock"}`},
+                },
+                {
+                        name: "default time key",
+                        want: []string{`{"ts":"1","message":"tick"}`, `{"ts":"2","message":"tick"}`, `{"ts":"3","message":"tock"}`},
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        SetFieldKeys(tt.keys)
+                        path := tempLogPath(t, "app.log")
+                        in := `{"ts":"1","message":"tick"}` + "\n" + `{"ts":"2","message":"tick"}` + "\n" + `{"ts":"3","message":"tock"}` + "\n"
+                        if err := os.WriteFile(path, []byte(in), 0644); err != nil {
+                                t.Fatal(err)
+                        }
+                        if err := compactFile(path); err != nil {
+                                t.Fatal(err)
+                        }
+                        got := strings.TrimSuffix(readLog(t, path), "\n")
+                        if want := strings.Join(tt.want, "\n"); got != want {
+                                t.Errorf("compacted to\n%s\nwant\n%s", got, want)
+                        }
+                })
+        }
+}
@@ -0,0 +1,341 @@
+// File: config.go
+// Description:
+// Configuration snapshots. Snapshot captures the current settings and
+// Restore brings them back, e.g. around a test or a temporary change of
+// level or outputs, without tracking each setting by hand.
+
+package logger
+
+import (
+        "io"
+        "log/slog"
+        "runtime"
+        "sync/atomic"
+        "time"
+)
+
+// Config is a snapshot of the logger settings taken by Snapshot. Its
+// contents are opaque.
+type Config struct {
+        level          int
+        levelVar       *slog.LevelVar
+        packageLevels  []packageLevel
+        fatalExitCode  int32
+        panicAction    int32
+        consoleWriter  io.Writer
+        consoleFormat  int
+        fileFormat     int
+        encoders       []Encoder
+        outputs        []*output
+        errorsToStderr bool
+        maxWriteFail   int
+        lazyFile       bool
+        failoverPath   string
+        failoverRetry  time.Duration
+        colorMode      int32
+        compressActive bool
+        maxFileSize    int64
+        rotateInterval time.Duration
+        idleRotation   time.Duration
+        alertOnError   bool
+        wrapWidth      int
+        collapseCaller bool
+        levelBuffer    [LevelFatal + 1]int
+        durability     *DurabilityPolicy
+
+        rotateName       *RotateNameFunc
+        maxBackups       int64
+        numberedRotation int32
+        currentSymlink   int32
+        compactOnRotate  int32
+        onRotate         func(oldPath, newPath string)
+
+        redactedKeys       map[string]bool
+        redactedParams     map[string]bool
+        requestIDHeader    string
+        includeSequence    int32
+        includeFingerprint int32
+        includeEventID     int32
+        includeSourceLine  int32
+        eventIDFunc        func() string
+        includeHostname    bool
+        hostname           string
+        globalFields       Fields
+        stackTraceLevel    int32
+        stackFilter        func(frame runtime.Frame) bool
+        binaryFormat       int32
+        maxMessageLength   int64
+        maxFields          int64
+        sanitizeControl    int32
+        deterministic      int32
+        retention          [LevelFatal + 1]int64
+        noTimestamp        [LevelFatal + 1]int32
+
+        filters      []FilterFunc
+        sampleField  string
+        sampleEvery  uint64
+        strictFormat int32
+        errorHook    func(err error)
+        netTimeout   int64
+        maxOpenFiles int64
+        crashDumpDir string
+        crashLevel   int
+
+        errorAlertThreshold int
+        errorAlertWindow    time.Duration
+        errorAlertFn        func(count int)
+
+        rateLimit        float64
+        rateBurst        float64
+        rateExemptErrors bool
+
+        recordSchema *compiledSchema
+
+        wsAllowedOrigins []string
+}
+
+// Snapshot returns the current configuration: levels, formats and
+// encoders, outputs, automatic fields, rotation and the other settings.
+// The log file itself is not part of it.
+func Snapshot() Config {
+        var c Config
+        c.level = GetLevel()
+        c.levelVar = sharedLevelVar.Load()
+        packageLevelsMu.RLock()
+        c.packageLevels = append([]packageLevel(nil), packageLevels...)
+        packageLevelsMu.RUnlock()
+        c.fatalExitCode = atomic.LoadInt32(&fatalExitCode)
+        c.panicAction = atomic.LoadInt32(&panicAction)
+
+        outputsMu.Lock()
+        c.consoleWriter = consoleWriter
+        c.consoleFormat = consoleFormat
+        c.fileFormat = fileFormat
+        c.encoders = append([]Encoder(nil), encoders...)
+        c.outputs = append([]*output(nil), extraOutputs...)
+        c.errorsToStderr = errorsToStderr
+        c.maxWriteFail = maxWriteFailures
+        c.lazyFile = lazyFile
+        c.failoverPath = failoverPath
+        c.failoverRetry = failoverRetryInterval
+        c.compressActive = compressActive
+        c.maxFileSize = maxFileSize
+        c.rotateInterval = rotateInterval
+        c.idleRotation = idleRotation
+        c.alertOnError = alertOnError
+        c.wrapWidth = wrapWidth
+        c.collapseCaller = collapseCaller
+        c.levelBuffer = levelBuffer
+        c.durability = durability
+        outputsMu.Unlock()
+
+        c.colorMode = atomic.LoadInt32(&colorMode)
+        c.rotateName = rotateName.Load()
+        c.maxBackups = atomic.LoadInt64(&maxBackups)
+        c.numberedRotation = atomic.LoadInt32(&numberedRotation)
+        c.currentSymlink = atomic.LoadInt32(&currentSymlink)
+        c.compactOnRotate = atomic.LoadInt32(&compactOnRotate)
+        onRotateMu.RLock()
+        c.onRotate = onRotate
+        onRotateMu.RUnlock()
+
+        redactedKeysMu.RLock()
+        c.redactedKeys = copyBoolMap(redactedKeys)
+        redactedKeysMu.RUnlock()
+        redactedParamsMu.RLock()
+        c.redactedParams = copyBoolMap(redactedParams)
+        redactedParamsMu.RUnlock()
+        c.requestIDHeader = getRequestIDHeader()
+        c.includeSequence = atomic.LoadInt32(&includeSequence)
+        c.includeFingerprint = atomic.LoadInt32(&includeFingerprint)
+        c.includeEventID = atomic.LoadInt32(&includeEventID)
+        c.includeSourceLine = atomic.LoadInt32(&includeSourceLine)
+        eventIDFuncMu.RLock()
+        c.eventIDFunc = eventIDFunc
+        eventIDFuncMu.RUnlock()
+        hostnameMu.RLock()
+        c.includeHostname = includeHostname
+        c.hostname = hostname
+        hostnameMu.RUnlock()
+        globalFieldsMu.RLock()
+        c.globalFields = globalFields
+        globalFieldsMu.RUnlock()
+        c.stackTraceLevel = atomic.LoadInt32(&stackTraceLevel)
+        stackFilterMu.RLock()
+        c.stackFilter = stackFilter
+        stackFilterMu.RUnlock()
+        c.binaryFormat = atomic.LoadInt32(&binaryFormat)
+        c.maxMessageLength = atomic.LoadInt64(&maxMessageLength)
+        c.maxFields = atomic.LoadInt64(&maxFields)
+        c.sanitizeControl = atomic.LoadInt32(&sanitizeControlChars)
+        c.deterministic = atomic.LoadInt32(&deterministic)
+        for i := range retention {
+                c.retention[i] = atomic.LoadInt64(&retention[i])
+        }
+        for i := range noTimestamp {
+                c.noTimestamp[i] = atomic.LoadInt32(&noTimestamp[i])
+        }
+
+        filtersMu.RLock()
+        c.filters = append([]FilterFunc(nil), filters...)
+        filtersMu.RUnlock()
+        sampleMu.RLock()
+        c.sampleField = sampleField
+        c.sampleEvery = sampleEvery
+        sampleMu.RUnlock()
+        c.strictFormat = atomic.LoadInt32(&strictFormat)
+        errorHookMu.RLock()
+        c.errorHook = errorHook
+        errorHookMu.RUnlock()
+        c.netTimeout = atomic.LoadInt64(&networkTimeout)
+        c.maxOpenFiles = atomic.LoadInt64(&maxOpenFiles)
+        crashDumpMu.Lock()
+        c.crashDumpDir = crashDumpDir
+        c.crashLevel = crashDumpLevel
+        crashDumpMu.Unlock()
+        errorAlertMu.Lock()
+        c.errorAlertThreshold = errorAlertThreshold
+        c.errorAlertWindow = errorAlertWindow
+        c.errorAlertFn = errorAlertFn
+        errorAlertMu.Unlock()
+        rateMu.Lock()
+        c.rateLimit = rateLimit
+        c.rateBurst = rateBurst
+        c.rateExemptErrors = rateExemptErrors
+        rateMu.Unlock()
+        recordSchemaMu.RLock()
+        c.recordSchema = recordSchema
+        recordSchemaMu.RUnlock()
+        wsMu.Lock()
+        c.wsAllowedOrigins = wsAllowedOrigins
+        wsMu.Unlock()
+        return c
+}
+
+// Restore applies a configuration taken by Snapshot. Outputs added since
+// the snapshot are closed; outputs closed since the snapshot are not
+// reopened.
+func Restore(c Config) {
+        UseLevelVar(c.levelVar)
+        SetLevel(c.level)
+        packageLevelsMu.Lock()
+        packageLevels = append([]packageLevel(nil), c.packageLevels...)
+        packageLevelsMu.Unlock()
+        atomic.StoreInt32(&fatalExitCode, c.fatalExitCode)
+        atomic.StoreInt32(&panicAction, c.panicAction)
+
+        outputsMu.Lock()
+        kept := map[*output]bool{}
+        for _, o := range c.outputs {
+                kept[o] = true
+        }
+        var dropped []*output
+        for _, o := range extraOutputs {
+                if !kept[o] {
+                        dropped = append(dropped, o)
+                }
+        }
+        consoleWriter = c.consoleWriter
+        consoleFormat = c.consoleFormat
+        fileFormat = c.fileFormat
+        encoders = append([]Encoder(nil), c.encoders...)
+        updateJSONTimeKey()
+        extraOutputs = append([]*output(nil), c.outputs...)
+        errorsToStderr = c.errorsToStderr
+        maxWriteFailures = c.maxWriteFail
+        lazyFile = c.lazyFile
+        failoverPath = c.failoverPath
+        failoverRetryInterval = c.failoverRetry
+        if failoverRetryInterval <= 0 {
+                failoverRetryInterval = defaultFailoverRetry
+        }
+        compressActive = c.compressActive
+        maxFileSize = c.maxFileSize
+        rotateInterval = c.rotateInterval
+        nextRotation = nextRotationAfter(now())
+        idleRotation = c.idleRotation
+        alertOnError = c.alertOnError
+        wrapWidth = c.wrapWidth
+        collapseCaller = c.collapseCaller
+        levelBuffer = c.levelBuffer
+        durability = c.durability
+        outputsMu.Unlock()
+        for _, o := range dropped {
+                if cl, ok := o.w.(io.Closer); ok {
+                        cl.Close()
+                }
+        }
+
+        atomic.StoreInt32(&colorMode, c.colorMode)
+        rotateName.Store(c.rotateName)
+        atomic.StoreInt64(&maxBackups, c.maxBackups)
+        atomic.StoreInt32(&numberedRotation, c.numberedRotation)
+        atomic.StoreInt32(&currentSymlink, c.currentSymlink)
+        atomic.StoreInt32(&compactOnRotate, c.compactOnRotate)
+        SetOnRotate(c.onRotate)
+
+        redactedKeysMu.Lock()
+        redactedKeys = copyBoolMap(c.redactedKeys)
+        redactedKeysMu.Unlock()
+        redactedParamsMu.Lock()
+        redactedParams = copyBoolMap(c.redactedParams)
+        redactedParamsMu.Unlock()
+        SetRequestIDHeader(c.requestIDHeader)
+        atomic.StoreInt32(&includeSequence, c.includeSequence)
+        atomic.StoreInt32(&includeFingerprint, c.includeFingerprint)
+        atomic.StoreInt32(&includeEventID, c.includeEventID)
+        atomic.StoreInt32(&includeSourceLine, c.includeSourceLine)
+        SetEventIDFunc(c.eventIDFunc)
+        hostnameMu.Lock()
+        includeHostname = c.includeHostname
+        hostname = c.hostname
+        hostnameMu.Unlock()
+        globalFieldsMu.Lock()
+        globalFields = c.globalFields
+        globalFieldsMu.Unlock()
+        atomic.StoreInt32(&stackTraceLevel, c.stackTraceLevel)
+        SetStackFilter(c.stackFilter)
+        atomic.StoreInt32(&binaryFormat, c.binaryFormat)
+        atomic.StoreInt64(&maxMessageLength, c.maxMessageLength)
+        atomic.StoreInt64(&maxFields, c.maxFields)
+        atomic.StoreInt32(&sanitizeControlChars, c.sanitizeControl)
+        atomic.StoreInt32(&deterministic, c.deterministic)
+        for i := range retention {
+                atomic.StoreInt64(&retention[i], c.retention[i])
+        }
+        for i := range noTimestamp {
+                atomic.StoreInt32(&noTimestamp[i], c.noTimestamp[i])
+        }
+
+        filtersMu.Lock()
+        filters = append([]FilterFunc(nil), c.filters...)
+        filtersMu.Unlock()
+        sampleMu.Lock()
+        sampleField = c.sampleField
+        sampleEvery = c.sampleEvery
+        sampleMu.Unlock()
+        atomic.StoreInt32(&strictFormat, c.strictFormat)
+        SetErrorHook(c.errorHook)
+        atomic.StoreInt64(&networkTimeout, c.netTimeout)
+        atomic.StoreInt64(&maxOpenFiles, c.maxOpenFiles)
+        crashDumpMu.Lock()
+        crashDumpDir = c.crashDumpDir
+        crashDumpLevel = c.crashLevel
+        crashDumpMu.Unlock()
+        SetErrorRateAlert(c.errorAlertThreshold, c.errorAlertWindow, c.errorAlertFn)
+        SetRateLimit(int(c.rateLimit), int(c.rateBurst))
+        SetRateLimitExemptErrors(c.rateExemptErrors)
+        recordSchemaMu.Lock()
+        recordSchema = c.recordSchema
+        recordSchemaMu.Unlock()
+        SetWSAllowedOrigins(c.wsAllowedOrigins...)
+}
+
+// copyBoolMap returns a copy of m
+func copyBoolMap(m map[string]bool) map[string]bool {
+        c := make(map[string]bool, len(m))
+        for k, v := range m {
+                c[k] = v
+        }
+        return c
+}
@@ -0,0 +1,62 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "fmt"
+        "strings"
+        "sync/atomic"
+        "testing"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+        settings := []struct {
+                name   string
+                change func()
+                probe  func() interface{}
+        }{
+                {"level", func() { SetLevel(LevelError) }, func() interface{} { return GetLevel() }},
+                {"format", func() { SetFormat(FormatJSON) }, func() interface{} {
+                        outputsMu.Lock()
+                        defer outputsMu.Unlock()
+                        return fmt.Sprint(consoleFormat, fileFormat)
+                }},
+                {"color", func() { SetColor(ColorAlways) }, func() interface{} { return atomic.LoadInt32(&colorMode) }},
+                {"outputs", func() { AddOutput(&syncBuffer{}, FormatText) }, func() interface{} { return len(Outputs()) }},
+                {"redaction", func() { RedactFields("password") }, func() interface{} { return isRedacted("password") }},
+                {"hostname", func() { SetIncludeHostname(true); SetHostname("web-1") }, func() interface{} {
+                        hostnameMu.RLock()
+                        defer hostnameMu.RUnlock()
+                        return fmt.Sprint(includeHostname, hostname)
+                }},
+                {"backups", func() { SetMaxBackups(3) }, func() interface{} { return atomic.LoadInt64(&maxBackups) }},
+                {"fatal exit code", func() { SetFatalExitCode(3) }, func() interface{} { return atomic.LoadInt32(&fatalExitCode) }},
+        }
+
+        out := captureOutput(t)
+        saved := Snapshot()
+        before := make([]interface{}, len(settings))
+        for i, s := range settings {
+                before[i] = s.probe()
+                s.change()
+                if s.probe() == before[i] {
+                        t.Fatalf("changing %s had no effect", s.name)
+                }
+        }
+        SetOutput(&syncBuffer{})
+
+        Restore(saved)
+        for i, s := range settings {
+                t.Run(s.name, func(t *testing.T) {
+                        if got := s.probe(); got != before[i] {
+                                t.Errorf("restored %v, want %v", got, before[i])
+                        }
+                })
+        }
+
+        // Records go where they did before, in the old format
+        Info("after restore")
+        if got := out.String(); !strings.HasPrefix(got, "[INFO] ") || !strings.Contains(got, "after restore") {
+                t.Errorf("console %q after restore", got)
+        }
+}
@@ -0,0 +1,126 @@
+// File: crashdump.go
+// Description:
+// Crash dumps for post-mortem debugging. With a dump directory set, the
+// most recent records are kept in memory, and a fatal record (or any record
+// at the configured level) writes a diagnostic file with the stacks of all
+// goroutines, memory statistics and those recent records.
+
+package logger
+
+import (
+        "fmt"
+        "os"
+        "path/filepath"
+        "runtime"
+        "strings"
+        "sync"
+        "time"
+)
+
+// crashDumpRecords is the number of recent records kept for crash dumps
+const crashDumpRecords = 100
+
+var (
+        // Directory of crash dumps, empty to disable them
+        crashDumpDir string
+
+        // Lowest level writing a crash dump
+        crashDumpLevel = LevelFatal
+
+        // Recent records, oldest first once the ring is full
+        recentRecords []*Record
+        recentNext    int
+
+        crashDumpMu sync.Mutex
+)
+
+// SetCrashDumpDir enables crash dumps written to dir: when a fatal record
+// is logged, before the program exits, a crash-<time>-<pid>.txt file is
+// created there with the stacks of all goroutines, memory statistics and
+// the last 100 records. An empty dir disables crash dumps.
+func SetCrashDumpDir(dir string) {
+        crashDumpMu.Lock()
+        defer crashDumpMu.Unlock()
+        crashDumpDir = dir
+        recentRecords = nil
+        recentNext = 0
+}
+
+// SetCrashDumpLevel sets the lowest level writing a crash dump (LevelFatal
+// by default), e.g. LevelError to investigate errors as well
+func SetCrashDumpLevel(level int) {
+        crashDumpMu.Lock()
+        defer crashDumpMu.Unlock()
+        crashDumpLevel = level
+}
+
+// crashDump remembers a written record and writes a crash dump if its level
+// calls for one
+func crashDump(rec *Record) {
+        crashDumpMu.Lock()
+        if crashDumpDir == "" {
+                crashDumpMu.Unlock()
+                return
+        }
+        if len(recentRecords) < crashDumpRecords {
+                recentRecords = append(recentRecords, rec)
+        } else {
+                recentRecords[recentNext] = rec
+                recentNext = (recentNext + 1) % crashDumpRecords
+        }
+        if rec.Level < crashDumpLevel {
+                crashDumpMu.Unlock()
+                return
+        }
+        dir := crashDumpDir
+        recent := append(append([]*Record(nil), recentRecords[recentNext:]...), recentRecords[:recentNext]...)
+        crashDumpMu.Unlock()
+
+        if err := writeCrashDump(dir, rec.Time, recent); err != nil {
+                reportError(fmt.Errorf("failed to write crash dump: %w", err))
+        }
+}
+
+// writeCrashDump writes a crash dump file to dir
+func writeCrashDump(dir string, t time.Time, recent []*Record) error {
+        var b strings.Builder
+        fmt.Fprintf(&b, "crash dump of pid %d at %s\n", os.Getpid(), t.Format(time.RFC3339Nano))
+
+        b.WriteString("\n== goroutines ==\n\n")
+        b.Write(allStacks())
+
+        var m runtime.MemStats
+        runtime.ReadMemStats(&m)
+        b.WriteString("\n== memory ==\n\n")
+        fmt.Fprintf(&b, "goroutines: %d\n", runtime.NumGoroutine())
+        fmt.Fprintf(&b, "heap_alloc: %d\n", m.HeapAlloc)
+        fmt.Fprintf(&b, "heap_sys: %d\n", m.HeapSys)
+        fmt.Fprintf(&b, "heap_objects: %d\n", m.HeapObjects)
+        fmt.Fprintf(&b, "total_alloc: %d\n", m.TotalAlloc)
+        fmt.Fprintf(&b, "sys: %d\n", m.Sys)
+        fmt.Fprintf(&b, "num_gc: %d\n", m.NumGC)
+
+        b.WriteString("\n== recent records ==\n\n")
+        for _, rec := range recent {
+                line, _ := TextEncoder{}.Encode(*rec)
+                b.Write(line)
+        }
+
+        if err := os.MkdirAll(dir, 0755); err != nil {
+                return err
+        }
+        name := fmt.Sprintf("crash-%s-%d.txt", t.Format("20060102-150405"), os.Getpid())
+        return os.WriteFile(filepath.Join(dir, name), []byte(b.String()), 0644)
+}
+
+// allStacks returns the stacks of all goroutines
+func allStacks() []byte {
+        buf := make([]byte, 64<<10)
+        for {
+                n := runtime.Stack(buf, true)
+                if n < len(buf) {
+                        return buf[:n]
+                }
+                buf = make([]byte, 2*len(buf))
+        }
+}
@@ -0,0 +1,72 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "os"
+        "path/filepath"
+        "strings"
+        "testing"
+)
+
+func TestCrashDump(t *testing.T) {
+        tests := []struct {
+                name     string
+                enabled  bool
+                level    int // Crash dump level, 0 for the default
+                log      func()
+                wantDump bool
+        }{
+                {name: "fatal", enabled: true, log: func() { Fatal("out of disk") }, wantDump: true},
+                {name: "error below default", enabled: true, log: func() { Error("out of disk") }},
+                {name: "error level", enabled: true, level: LevelError, log: func() { Error("out of disk") }, wantDump: true},
+                {name: "disabled", log: func() { Fatal("out of disk") }},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        stubExit(t)
+                        dir := filepath.Join(t.TempDir(), "dumps")
+                        if tt.enabled {
+                                SetCrashDumpDir(dir)
+                        }
+                        if tt.level != 0 {
+                                SetCrashDumpLevel(tt.level)
+                        }
+                        Info("loading config")
+                        Warning("disk almost full")
+                        tt.log()
+
+                        files, _ := filepath.Glob(filepath.Join(dir, "crash-*.txt"))
+                        if !tt.wantDump {
+                                if len(files) != 0 {
+                                        t.Errorf("unexpected crash dumps %v", files)
+                                }
+                                return
+                        }
+                        if len(files) != 1 {
+                                t.Fatalf("got crash dumps %v, want one", files)
+                        }
+                        data, err := os.ReadFile(files[0])
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        dump := string(data)
+                        for _, want := range []string{
+                                "== goroutines ==",
+                                "goroutine ",
+                                "TestCrashDump",
+                                "== memory ==",
+                                "heap_alloc: ",
+                                "== recent records ==",
+                                "loading config",
+                                "disk almost full",
+                                "out of disk",
+                        } {
+                                if !strings.Contains(dump, want) {
+                                        t.Errorf("crash dump lacks %q", want)
+                                }
+                        }
+                })
+        }
+}
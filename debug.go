@@ -0,0 +1,57 @@
+//go:build !logger_release
+
+// File: debug.go
+// Description:
+// Debug logging functions, including the debug methods of Entry,
+// BufferedContext and RequestLog. Building with the logger_release tag
+// replaces them with the empty versions in debug_release.go.
+
+package logger
+
+// debugCompiled reports whether debug logging is compiled in
+const debugCompiled = true
+
+// Debug logs a debug message
+func Debug(v ...interface{}) {
+        logWithCallerInfo(LevelDebug, nil, "", v...)
+}
+
+// Debugf logs a formatted debug message
+func Debugf(format string, v ...interface{}) {
+        logWithCallerInfo(LevelDebug, nil, format, v...)
+}
+
+// Debugw logs a debug message with alternating key/value pairs
+func Debugw(msg string, keysAndValues ...interface{}) {
+        logWithCallerInfo(LevelDebug, kvToFields(keysAndValues), "", msg)
+}
+
+// Debug logs a debug message with the entry's fields
+func (e *Entry) Debug(v ...interface{}) {
+        logEntry(e, LevelDebug, "", v...)
+}
+
+// Debugf logs a formatted debug message with the entry's fields
+func (e *Entry) Debugf(format string, v ...interface{}) {
+        logEntry(e, LevelDebug, format, v...)
+}
+
+// Debug buffers a debug message
+func (b *BufferedContext) Debug(v ...interface{}) {
+        logBuffered(b, LevelDebug, nil, "", v...)
+}
+
+// Debugf buffers a formatted debug message
+func (b *BufferedContext) Debugf(format string, v ...interface{}) {
+        logBuffered(b, LevelDebug, nil, format, v...)
+}
+
+// Debug logs a debug message, held until the request errors
+func (l *RequestLog) Debug(v ...interface{}) {
+        logRequest(l, LevelDebug, nil, "", v...)
+}
+
+// Debugf logs a formatted debug message, held until the request errors
+func (l *RequestLog) Debugf(format string, v ...interface{}) {
+        logRequest(l, LevelDebug, nil, format, v...)
+}
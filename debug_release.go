@@ -0,0 +1,41 @@
+//go:build logger_release
+
+// File: debug_release.go
+// Description:
+// Debug logging functions for release builds (go build -tags
+// logger_release). They are empty and inlined, so the compiler removes the
+// calls along with arguments that have no side effects; arguments that call
+// functions are still evaluated, so keep expensive work out of debug calls
+// or guard it with a level check. The rest of the API is unchanged.
+
+package logger
+
+// debugCompiled reports whether debug logging is compiled in
+const debugCompiled = false
+
+// Debug does nothing in release builds
+func Debug(v ...interface{}) {}
+
+// Debugf does nothing in release builds
+func Debugf(format string, v ...interface{}) {}
+
+// Debugw does nothing in release builds
+func Debugw(msg string, keysAndValues ...interface{}) {}
+
+// Debug does nothing in release builds
+func (e *Entry) Debug(v ...interface{}) {}
+
+// Debugf does nothing in release builds
+func (e *Entry) Debugf(format string, v ...interface{}) {}
+
+// Debug does nothing in release builds
+func (b *BufferedContext) Debug(v ...interface{}) {}
+
+// Debugf does nothing in release builds
+func (b *BufferedContext) Debugf(format string, v ...interface{}) {}
+
+// Debug does nothing in release builds
+func (l *RequestLog) Debug(v ...interface{}) {}
+
+// Debugf does nothing in release builds
+func (l *RequestLog) Debugf(format string, v ...interface{}) {}
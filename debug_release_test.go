@@ -0,0 +1,57 @@
+//go:build logger_release
+
+package logger
+
+import (
+        "context"
+        "testing"
+)
+
+// BenchmarkDebug measures debug calls compiled out by logger_release;
+// compare with the same benchmark without the tag (debug_test.go)
+func BenchmarkDebug(b *testing.B) {
+        Reset()
+        defer Reset()
+        SetOutput(&syncBuffer{})
+        SetLevel(LevelDebug)
+        user, n := "alice", 42
+        b.ReportAllocs()
+        for i := 0; i < b.N; i++ {
+                Debugf("user %s has %d items", user, n)
+        }
+}
+
+func TestDebugReleaseFree(t *testing.T) {
+        out := captureOutput(t)
+        SetLevel(LevelDebug)
+        user, n := "alice", 42
+        entry := WithField("k", "v")
+        buf := BeginBuffered()
+        _, reqLog := BeginRequestLogging(context.Background())
+        tests := []struct {
+                name string
+                log  func()
+        }{
+                {"Debug", func() { Debug("user", user, n) }},
+                {"Debugf", func() { Debugf("user %s has %d items", user, n) }},
+                {"Debugw", func() { Debugw("user", "name", user, "items", n) }},
+                {"Entry.Debug", func() { entry.Debug("user", user) }},
+                {"Entry.Debugf", func() { entry.Debugf("user %s", user) }},
+                {"BufferedContext.Debug", func() { buf.Debug("user", user) }},
+                {"BufferedContext.Debugf", func() { buf.Debugf("user %s", user) }},
+                {"RequestLog.Debug", func() { reqLog.Debug("user", user) }},
+                {"RequestLog.Debugf", func() { reqLog.Debugf("user %s", user) }},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        if allocs := testing.AllocsPerRun(100, tt.log); allocs != 0 {
+                                t.Errorf("%v allocations per call, want 0", allocs)
+                        }
+                })
+        }
+        buf.Flush()
+        reqLog.End()
+        if got := out.String(); got != "" {
+                t.Errorf("debug output in a release build: %q", got)
+        }
+}
@@ -0,0 +1,20 @@
+//go:build !logger_release
+
+package logger
+
+import "testing"
+
+// BenchmarkDebug measures enabled debug calls; build with the
+// logger_release tag to compare with compiled out calls
+// (debug_release_test.go)
+func BenchmarkDebug(b *testing.B) {
+        Reset()
+        defer Reset()
+        SetOutput(&syncBuffer{})
+        SetLevel(LevelDebug)
+        user, n := "alice", 42
+        b.ReportAllocs()
+        for i := 0; i < b.N; i++ {
+                Debugf("user %s has %d items", user, n)
+        }
+}
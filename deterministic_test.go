@@ -0,0 +1,65 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "strings"
+        "testing"
+        "time"
+)
+
+func TestDeterministic(t *testing.T) {
+        tests := []struct {
+                name     string
+                enabled  bool
+                format   int
+                wantTime string // Timestamp expected in deterministic mode
+        }{
+                {name: "text", enabled: true, format: FormatText, wantTime: "2000/01/01 00:00:00"},
+                {name: "JSON", enabled: true, format: FormatJSON, wantTime: `"time":"2000-01-01T00:00:00Z"`},
+                {name: "logfmt", enabled: true, format: FormatLogfmt, wantTime: "time=2000-01-01T00:00:00Z"},
+                {name: "disabled", format: FormatText},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        // Each run logs the same records at another time and from
+                        // other lines
+                        runs := []func(){
+                                func() {
+                                        WithField("user", "alice").Info("login")
+                                        Warning("quota low")
+                                },
+                                func() {
+                                        WithField("user", "alice").Info("login")
+                                        Warning("quota low")
+                                },
+                        }
+                        var outputs []string
+                        for _, run := range runs {
+                                out := captureOutput(t)
+                                clock := useFakeClock(t)
+                                clock.Advance(time.Duration(len(outputs)) * time.Hour)
+                                SetFormat(tt.format)
+                                SetDeterministic(tt.enabled)
+                                run()
+                                outputs = append(outputs, out.String())
+                        }
+
+                        if !tt.enabled {
+                                if outputs[0] == outputs[1] {
+                                        t.Errorf("runs without deterministic mode are identical:\n%s", outputs[0])
+                                }
+                                return
+                        }
+                        if outputs[0] != outputs[1] {
+                                t.Errorf("runs differ:\n%s\n%s", outputs[0], outputs[1])
+                        }
+                        if strings.Count(outputs[0], tt.wantTime) != 2 {
+                                t.Errorf("output lacks the fixed time %s:\n%s", tt.wantTime, outputs[0])
+                        }
+                        if strings.Contains(outputs[0], "deterministic_test.go") {
+                                t.Errorf("output has a caller:\n%s", outputs[0])
+                        }
+                })
+        }
+}
@@ -0,0 +1,95 @@
+// File: durability.go
+// Description:
+// fsync policy of the log file. Important records (errors by default) are
+// synced to disk as soon as they are written, while the others are synced
+// in batches, every so many records or after a delay, which keeps the cost
+// of fsync low for chatty debug and info logging.
+
+package logger
+
+import (
+        "sync/atomic"
+        "time"
+)
+
+// DurabilityPolicy decides when the log file is synced to disk
+type DurabilityPolicy struct {
+        SyncLevel     int           // Records at or above this level are synced immediately
+        BatchSize     int           // Sync after this many unsynced records, 0 for no limit
+        BatchInterval time.Duration // Sync unsynced records after this delay, 0 for no limit
+}
+
+// DefaultDurabilityPolicy syncs errors immediately and other records every
+// 100 records or every second
+var DefaultDurabilityPolicy = DurabilityPolicy{
+        SyncLevel:     LevelError,
+        BatchSize:     100,
+        BatchInterval: time.Second,
+}
+
+var (
+        // Active policy, nil to leave syncing to the OS (guarded by outputsMu)
+        durability *DurabilityPolicy
+
+        // Records written to the log file since the last sync (guarded by outputsMu)
+        unsyncedRecords int
+
+        // A delayed sync is scheduled (guarded by outputsMu)
+        syncPending bool
+)
+
+// SetDurabilityPolicy makes the log file be synced to disk according to
+// policy, e.g. &DefaultDurabilityPolicy. Passing nil (the default) leaves
+// flushing to the operating system.
+func SetDurabilityPolicy(policy *DurabilityPolicy) {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        if policy == nil {
+                durability = nil
+                return
+        }
+        p := *policy
+        durability = &p
+}
+
+// syncAfterWrite applies the durability policy after a record of the given
+// level was written to the log file; outputsMu must be held
+func syncAfterWrite(level int) error {
+        if durability == nil || logFile == nil {
+                return nil
+        }
+        unsyncedRecords++
+        if level >= durability.SyncLevel || (durability.BatchSize > 0 && unsyncedRecords >= durability.BatchSize) {
+                return syncLogFile()
+        }
+        if durability.BatchInterval > 0 && !syncPending {
+                syncPending = true
+                afterFunc(durability.BatchInterval, func() {
+                        outputsMu.Lock()
+                        syncPending = false
+                        var err error
+                        if unsyncedRecords > 0 {
+                                err = syncLogFile()
+                        }
+                        outputsMu.Unlock()
+                        if err != nil {
+                                reportError(err)
+                        }
+                })
+        }
+        return nil
+}
+
+// syncLogFile flushes the log file to disk; outputsMu must be held
+func syncLogFile() error {
+        if logFile == nil {
+                return nil
+        }
+        flushFileBuffer()
+        if logGzip != nil {
+                logGzip.Flush()
+        }
+        unsyncedRecords = 0
+        atomic.AddUint64(&statFileSyncs, 1)
+        return logFile.Sync()
+}
@@ -0,0 +1,53 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "testing"
+        "time"
+)
+
+func TestDurabilityPolicy(t *testing.T) {
+        tests := []struct {
+                name     string
+                policy   *DurabilityPolicy
+                workload string // d, i, w, e: a record of that level; +: a second passes
+                want     uint64
+        }{
+                {name: "no policy", workload: "iiiiie+", want: 0},
+                {name: "errors synced", policy: &DurabilityPolicy{SyncLevel: LevelError}, workload: "iiwieiiie+", want: 2},
+                {name: "warnings synced", policy: &DurabilityPolicy{SyncLevel: LevelWarning}, workload: "iiwiei", want: 2},
+                {name: "batch size", policy: &DurabilityPolicy{SyncLevel: LevelError, BatchSize: 4}, workload: "iiiiiiiiii", want: 2},
+                {name: "error resets the batch", policy: &DurabilityPolicy{SyncLevel: LevelError, BatchSize: 4}, workload: "iieiiii", want: 2},
+                {name: "batch interval", policy: &DurabilityPolicy{SyncLevel: LevelError, BatchInterval: time.Second}, workload: "iii+++i+", want: 2},
+                {name: "interval after error", policy: &DurabilityPolicy{SyncLevel: LevelError, BatchInterval: time.Second}, workload: "ie+", want: 1},
+                {name: "default policy", policy: &DefaultDurabilityPolicy, workload: "dddiiwe+ii+", want: 2},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        clock := useFakeClock(t)
+                        if err := InitLogger(LevelDebug, true, tempLogPath(t, "app.log")); err != nil {
+                                t.Fatal(err)
+                        }
+                        SetDurabilityPolicy(tt.policy)
+                        for _, step := range tt.workload {
+                                switch step {
+                                case 'd':
+                                        Debug("record")
+                                case 'i':
+                                        Info("record")
+                                case 'w':
+                                        Warning("record")
+                                case 'e':
+                                        Error("record")
+                                case '+':
+                                        clock.Advance(time.Second)
+                                }
+                        }
+                        if got := GetStats().FileSyncs; got != tt.want {
+                                t.Errorf("%d syncs, want %d", got, tt.want)
+                        }
+                })
+        }
+}
@@ -0,0 +1,81 @@
+// File: erroralert.go
+// Description:
+// Error rate alerting for self-monitoring. Error and fatal records are
+// counted over a sliding window; when their number exceeds a threshold a
+// callback fires (e.g. to page someone), once per burst.
+
+package logger
+
+import (
+        "sync"
+        "time"
+)
+
+var (
+        // Error rate alert settings, fn nil when disabled
+        errorAlertThreshold int
+        errorAlertWindow    time.Duration
+        errorAlertFn        func(count int)
+
+        // Times of the errors within the window, oldest first
+        errorTimes []time.Time
+
+        // The alert fired and is waiting for the rate to drop
+        errorAlertFired bool
+
+        errorAlertMu sync.Mutex
+)
+
+// SetErrorRateAlert calls fn with the number of error and fatal records of
+// the last window when it exceeds threshold. It fires once when the rate
+// crosses the threshold and again only after the rate has dropped back to
+// the threshold or below. fn runs on the logging goroutine and may log.
+// A nil fn disables the alert.
+func SetErrorRateAlert(threshold int, window time.Duration, fn func(count int)) {
+        errorAlertMu.Lock()
+        defer errorAlertMu.Unlock()
+        errorAlertThreshold = threshold
+        errorAlertWindow = window
+        errorAlertFn = fn
+        errorTimes = nil
+        errorAlertFired = false
+}
+
+// countError accounts for a written record in the error rate and fires
+// the alert if the threshold is crossed. The window is measured in write
+// times, not record times, which are fixed in deterministic mode and may be
+// old for buffered records.
+func countError(rec *Record) {
+        if rec.Level < LevelError {
+                return
+        }
+
+        errorAlertMu.Lock()
+        if errorAlertFn == nil {
+                errorAlertMu.Unlock()
+                return
+        }
+        t := now()
+        cutoff := t.Add(-errorAlertWindow)
+        i := 0
+        for i < len(errorTimes) && !errorTimes[i].After(cutoff) {
+                i++
+        }
+        errorTimes = append(errorTimes[i:], t)
+        count := len(errorTimes)
+
+        var fn func(count int)
+        if count > errorAlertThreshold {
+                if !errorAlertFired {
+                        errorAlertFired = true
+                        fn = errorAlertFn
+                }
+        } else {
+                errorAlertFired = false
+        }
+        errorAlertMu.Unlock()
+
+        if fn != nil {
+                fn(count)
+        }
+}
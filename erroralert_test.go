@@ -0,0 +1,66 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "fmt"
+        "strings"
+        "testing"
+        "time"
+)
+
+func TestErrorRateAlert(t *testing.T) {
+        tests := []struct {
+                name          string
+                workload      string // w, e, f: a record of that level; b: a buffered error; F: flush; +: a second passes
+                logs          bool   // Whether the callback logs an error itself
+                deterministic bool   // Fixed record times, see SetDeterministic
+                want          []int  // Counts the callback got
+        }{
+                {name: "burst fires once", workload: "eeeeeeee", want: []int{4}},
+                {name: "below threshold", workload: "eee+"},
+                {name: "warnings ignored", workload: "wwwwwwe"},
+                {name: "fatal counted", workload: "eeef", want: []int{4}},
+                {name: "window slides", workload: "ee" + strings.Repeat("+", 10) + "ee"},
+                {name: "fires again after the rate drops", workload: "eeee" + strings.Repeat("+", 11) + "e" + "eee", want: []int{4, 4}},
+                {name: "callback logging", workload: "eeeeee", logs: true, want: []int{4}},
+                {name: "buffered errors count when written", workload: "bbb" + strings.Repeat("+", 11) + "Fe", want: []int{4}},
+                {name: "deterministic window slides", workload: "ee" + strings.Repeat("+", 10) + "ee", deterministic: true},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        stubExit(t)
+                        clock := useFakeClock(t)
+                        SetDeterministic(tt.deterministic)
+                        defer SetDeterministic(false)
+                        buf := BeginBuffered()
+                        var got []int
+                        SetErrorRateAlert(3, 10*time.Second, func(count int) {
+                                got = append(got, count)
+                                if tt.logs {
+                                        Error("error rate alert")
+                                }
+                        })
+                        for _, step := range tt.workload {
+                                switch step {
+                                case 'w':
+                                        Warning("record")
+                                case 'e':
+                                        Error("record")
+                                case 'f':
+                                        Fatal("record")
+                                case 'b':
+                                        buf.Error("record")
+                                case 'F':
+                                        buf.Flush()
+                                case '+':
+                                        clock.Advance(time.Second)
+                                }
+                        }
+                        if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+                                t.Errorf("alerts %v, want %v", got, tt.want)
+                        }
+                })
+        }
+}
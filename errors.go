@@ -0,0 +1,54 @@
+// File: errors.go
+// Description:
+// Reporting of internal logging errors (failed writes, disabled outputs,
+// failed rotations). Such errors cannot be logged through the failing
+// outputs themselves, so they are handed to an optional error hook and the
+// most recent one is kept for LastError.
+
+package logger
+
+import "sync"
+
+var (
+        // Called with internal logging errors
+        errorHook   func(err error)
+        errorHookMu sync.RWMutex
+
+        // Most recent internal error (guarded by errorHookMu)
+        lastError error
+)
+
+// SetErrorHook registers a function called with internal logging errors,
+// such as failed writes to an output. The hook must not block for long and
+// may log through the package. Passing nil removes the hook.
+func SetErrorHook(fn func(err error)) {
+        errorHookMu.Lock()
+        defer errorHookMu.Unlock()
+        errorHook = fn
+}
+
+// LastError returns the most recent internal logging error, such as a
+// failed write or rotation, or nil if none occurred since start (or Reset)
+func LastError() error {
+        errorHookMu.RLock()
+        defer errorHookMu.RUnlock()
+        return lastError
+}
+
+// clearLastError forgets the last internal error
+func clearLastError() {
+        errorHookMu.Lock()
+        defer errorHookMu.Unlock()
+        lastError = nil
+}
+
+// reportError records an internal error and hands it to the error hook, if any
+func reportError(err error) {
+        errorHookMu.Lock()
+        lastError = err
+        hook := errorHook
+        errorHookMu.Unlock()
+        if hook != nil {
+                hook(err)
+        }
+}
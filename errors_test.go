@@ -0,0 +1,83 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "strings"
+        "testing"
+)
+
+func TestLastError(t *testing.T) {
+        tests := []struct {
+                name  string
+                cause func(t *testing.T)
+                want  string // Expected in LastError, empty for nil
+        }{
+                {
+                        name:  "no failure",
+                        cause: func(t *testing.T) { Info("record") },
+                },
+                {
+                        name: "output write failure",
+                        cause: func(t *testing.T) {
+                                AddOutput(failingWriter{}, FormatText)
+                                Info("record")
+                        },
+                        want: "failed to write to output: no space left on device",
+                },
+                {
+                        name: "log file write failure",
+                        cause: func(t *testing.T) {
+                                if err := InitLogger(LevelInfo, true, tempLogPath(t, "app.log")); err != nil {
+                                        t.Fatal(err)
+                                }
+                                outputsMu.Lock()
+                                logFile.Close() // Closed behind the logger's back
+                                outputsMu.Unlock()
+                                Info("record")
+                        },
+                        want: "failed to write to log file",
+                },
+                {
+                        name: "most recent error",
+                        cause: func(t *testing.T) {
+                                AddOutput(failingWriter{}, FormatText)
+                                Info("record")
+                                SetMaxWriteFailures(1)
+                                Info("record")
+                        },
+                        want: "disabled output",
+                },
+                {
+                        name: "cleared by Reset",
+                        cause: func(t *testing.T) {
+                                AddOutput(failingWriter{}, FormatText)
+                                Info("record")
+                                Reset()
+                        },
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        captureStderr(t)
+                        var hooked error
+                        SetErrorHook(func(err error) { hooked = err })
+                        tt.cause(t)
+
+                        err := LastError()
+                        if tt.want == "" {
+                                if err != nil {
+                                        t.Errorf("LastError() = %v, want nil", err)
+                                }
+                                return
+                        }
+                        if err == nil || !strings.Contains(err.Error(), tt.want) {
+                                t.Fatalf("LastError() = %v, want %q", err, tt.want)
+                        }
+                        if hooked != err {
+                                t.Errorf("error hook last got %v, LastError %v", hooked, err)
+                        }
+                })
+        }
+}
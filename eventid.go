@@ -0,0 +1,114 @@
+// File: eventid.go
+// Description:
+// Event ids. With SetIncludeEventID every record gets a unique "event_id"
+// field, so a specific line can be referenced elsewhere (e.g. shown to a
+// user in an error page). The default ids are ULIDs: 26 characters that
+// sort in the order the records were logged.
+
+package logger
+
+import (
+        "crypto/rand"
+        "sync"
+        "sync/atomic"
+)
+
+// EventIDKey is the field name of the event id
+const EventIDKey = "event_id"
+
+// crockford is the ULID base32 alphabet
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var (
+        // Attach an event id to every record (accessed atomically)
+        includeEventID int32
+
+        // Generates event ids, nil for ULIDs
+        eventIDFunc   func() string
+        eventIDFuncMu sync.RWMutex
+
+        // State of the monotonic ULID generator
+        ulidMu      sync.Mutex
+        ulidLastMs  uint64
+        ulidEntropy [10]byte
+)
+
+// SetIncludeEventID attaches a unique "event_id" field to every record
+func SetIncludeEventID(enabled bool) {
+        var v int32
+        if enabled {
+                v = 1
+        }
+        atomic.StoreInt32(&includeEventID, v)
+}
+
+// SetEventIDFunc replaces the ULID generator used for event ids, e.g. with
+// a UUID generator. Passing nil restores ULIDs.
+func SetEventIDFunc(fn func() string) {
+        eventIDFuncMu.Lock()
+        defer eventIDFuncMu.Unlock()
+        eventIDFunc = fn
+}
+
+// withEventID adds a new event id to fields if enabled
+func withEventID(fields Fields) Fields {
+        if atomic.LoadInt32(&includeEventID) == 0 {
+                return fields
+        }
+        eventIDFuncMu.RLock()
+        fn := eventIDFunc
+        eventIDFuncMu.RUnlock()
+        if fn == nil {
+                fn = newULID
+        }
+        return withField(fields, EventIDKey, fn())
+}
+
+// newULID returns a ULID for the current time. Within the same millisecond
+// the random part is incremented, so ids stay strictly increasing.
+func newULID() string {
+        ms := uint64(now().UnixMilli())
+
+        ulidMu.Lock()
+        if ms <= ulidLastMs {
+                // Same (or earlier) millisecond: increment the entropy
+                ms = ulidLastMs
+                for i := len(ulidEntropy) - 1; i >= 0; i-- {
+                        ulidEntropy[i]++
+                        if ulidEntropy[i] != 0 {
+                                break
+                        }
+                }
+        } else {
+                rand.Read(ulidEntropy[:])
+                ulidLastMs = ms
+        }
+        var id [16]byte
+        for i := 0; i < 6; i++ {
+                id[i] = byte(ms >> (40 - 8*i))
+        }
+        copy(id[6:], ulidEntropy[:])
+        ulidMu.Unlock()
+
+        return encodeULID(id)
+}
+
+// encodeULID renders 128 bits as 26 Crockford base32 characters
+func encodeULID(id [16]byte) string {
+        var out [26]byte
+        // 130 bits of output for 128 bits of input: the first character holds
+        // the top 3 bits
+        var acc uint64
+        bits := 2
+        n := 0
+        for _, b := range id {
+                acc = acc<<8 | uint64(b)
+                bits += 8
+                for bits >= 5 {
+                        bits -= 5
+                        out[n] = crockford[(acc>>uint(bits))&31]
+                        n++
+                }
+        }
+        return string(out[:])
+}
@@ -0,0 +1,78 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "fmt"
+        "strings"
+        "testing"
+        "time"
+)
+
+func TestEventID(t *testing.T) {
+        tests := []struct {
+                name    string
+                enabled bool
+                fn      func() func() string // Makes the custom generator, nil for ULIDs
+                step    time.Duration        // Clock advance between records
+                ulid    bool
+        }{
+                {name: "ULIDs within a millisecond", enabled: true, ulid: true},
+                {name: "ULIDs over time", enabled: true, step: 3 * time.Millisecond, ulid: true},
+                {
+                        name:    "custom generator",
+                        enabled: true,
+                        fn: func() func() string {
+                                n := 0
+                                return func() string { n++; return fmt.Sprintf("evt-%d", n) }
+                        },
+                },
+                {name: "disabled"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        clock := useFakeClock(t)
+                        SetFormat(FormatJSON)
+                        SetIncludeEventID(tt.enabled)
+                        if tt.fn != nil {
+                                SetEventIDFunc(tt.fn())
+                        }
+                        for i := 0; i < 50; i++ {
+                                Info("record")
+                                clock.Advance(tt.step)
+                        }
+
+                        seen := map[string]bool{}
+                        previous := ""
+                        for _, record := range out.Records(t) {
+                                id, _ := record[EventIDKey].(string)
+                                if !tt.enabled {
+                                        if _, ok := record[EventIDKey]; ok {
+                                                t.Fatalf("event id attached while disabled: %v", record)
+                                        }
+                                        continue
+                                }
+                                if id == "" {
+                                        t.Fatalf("record without event id: %v", record)
+                                }
+                                if seen[id] {
+                                        t.Errorf("event id %s repeated", id)
+                                }
+                                seen[id] = true
+                                if tt.ulid {
+                                        if len(id) != 26 || strings.Trim(id, crockford) != "" {
+                                                t.Errorf("event id %q is not a ULID", id)
+                                        }
+                                        if id <= previous {
+                                                t.Errorf("event id %s not after %s", id, previous)
+                                        }
+                                }
+                                previous = id
+                        }
+                        if tt.enabled && len(seen) != 50 {
+                                t.Errorf("got %d distinct ids, want 50", len(seen))
+                        }
+                })
+        }
+}
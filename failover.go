@@ -0,0 +1,154 @@
+// File: failover.go
+// Description:
+// Failover log file. When a write to the log file fails (e.g. its mount
+// became read-only), records switch to the failover file set with
+// SetFailoverFile and a warning is logged. The primary file is retried
+// periodically and records return to it as soon as it accepts writes again.
+
+package logger
+
+import (
+        "fmt"
+        "os"
+        "time"
+)
+
+// defaultFailoverRetry is how often the primary log file is retried by
+// default
+const defaultFailoverRetry = 30 * time.Second
+
+var (
+        // How often the primary log file is retried (guarded by outputsMu)
+        failoverRetryInterval = defaultFailoverRetry
+
+        // Log file to switch to when writing the log file fails (guarded by outputsMu)
+        failoverPath string
+
+        // Records go to the failover file (guarded by outputsMu)
+        failoverActive bool
+
+        // Path of the log file that failed (guarded by outputsMu)
+        failoverPrimary string
+
+        // Retries the primary log file (guarded by outputsMu)
+        failoverTimer ClockTimer
+
+        // Warning to log once the outputs are unlocked (guarded by outputsMu)
+        failoverNotice string
+)
+
+// SetFailoverFile sets a log file to switch to when a write to the log file
+// fails. The switch is announced with a warning, and the primary file is
+// retried periodically (see SetFailoverRetryInterval); records go back to
+// it once a test write and sync succeed. An empty path (the default)
+// disables failover; it doesn't bring records back from a failover file
+// already in use.
+func SetFailoverFile(path string) {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        failoverPath = path
+}
+
+// SetFailoverRetryInterval sets how often the primary log file is retried
+// while records go to the failover file (30 seconds by default). Zero or
+// less restores the default. It applies from the next retry.
+func SetFailoverRetryInterval(d time.Duration) {
+        if d <= 0 {
+                d = defaultFailoverRetry
+        }
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        failoverRetryInterval = d
+}
+
+// startFailover replaces the failing log file with the failover file and
+// reports whether it did; outputsMu must be held
+func startFailover() (bool, error) {
+        if failoverPath == "" || failoverActive || logFile == nil {
+                return false, nil
+        }
+        file, err := openLogFile(failoverPath)
+        if err != nil {
+                return false, fmt.Errorf("failed to switch to failover log file: %v", err)
+        }
+        primary := logFile.Name()
+        detachLogFile().Close()
+        attachLogFile(file)
+        failoverActive = true
+        failoverPrimary = primary
+        failoverNotice = fmt.Sprintf("Writing to failover log file %s, %s failed", failoverPath, primary)
+        failoverTimer = afterFunc(failoverRetryInterval, retryPrimary)
+        return true, nil
+}
+
+// retryPrimary switches back to the primary log file if it accepts writes
+// again, or tries again later
+func retryPrimary() {
+        outputsMu.Lock()
+        if !failoverActive {
+                outputsMu.Unlock()
+                return
+        }
+        file, err := openLogFile(failoverPrimary)
+        if err == nil {
+                if err = probeLogFile(file); err != nil {
+                        file.Close()
+                }
+        }
+        if err != nil {
+                failoverTimer = afterFunc(failoverRetryInterval, retryPrimary)
+                outputsMu.Unlock()
+                return
+        }
+        failover := detachLogFile()
+        attachLogFile(file)
+        primary := failoverPrimary
+        endFailover()
+        outputsMu.Unlock()
+
+        if failover != nil {
+                failover.Close()
+        }
+        updateCurrentSymlink()
+        Infof("Log file %s is writable again, leaving failover log file", primary)
+}
+
+// probeLogFile checks that f accepts writes: opening succeeds on a full
+// disk, writing and syncing don't. The test write is truncated away.
+func probeLogFile(f *os.File) error {
+        info, err := f.Stat()
+        if err != nil {
+                return err
+        }
+        if _, err := f.Write([]byte("\n")); err != nil {
+                return err
+        }
+        if err := f.Sync(); err != nil {
+                return err
+        }
+        return f.Truncate(info.Size())
+}
+
+// endFailover stops retrying the primary log file; outputsMu must be held
+func endFailover() {
+        if failoverTimer != nil {
+                failoverTimer.Stop()
+                failoverTimer = nil
+        }
+        failoverActive = false
+        failoverPrimary = ""
+        failoverNotice = ""
+}
+
+// announceFailover logs the warning of a switch to the failover file, if any
+func announceFailover() {
+        outputsMu.Lock()
+        notice := failoverNotice
+        failoverNotice = ""
+        outputsMu.Unlock()
+        if notice == "" {
+                return
+        }
+        updateCurrentSymlink()
+        Warning(notice)
+}
@@ -0,0 +1,93 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "os"
+        "strings"
+        "testing"
+        "time"
+)
+
+func TestFailoverFile(t *testing.T) {
+        tests := []struct {
+                name         string
+                primary      string // "" for a regular file closed under the logger
+                failover     bool
+                wantRecovery bool // Whether records go back to the primary after a retry
+        }{
+                {name: "full disk", primary: "/dev/full", failover: true},
+                {name: "primary recovers", failover: true, wantRecovery: true},
+                {name: "no failover file"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        clock := useFakeClock(t)
+                        captureStderr(t) // Failed writes are copied there
+                        primary := tt.primary
+                        if primary == "" {
+                                primary = tempLogPath(t, "app.log")
+                        } else if _, err := os.Stat(primary); err != nil {
+                                t.Skipf("%s unavailable: %v", primary, err)
+                        }
+                        failover := tempLogPath(t, "failover.log")
+                        var hookErrors []error
+                        SetErrorHook(func(err error) { hookErrors = append(hookErrors, err) })
+                        if err := InitLogger(LevelInfo, true, primary); err != nil {
+                                t.Fatal(err)
+                        }
+                        SetOutput(out)
+                        if tt.failover {
+                                SetFailoverFile(failover)
+                        }
+                        SetFailoverRetryInterval(10 * time.Second)
+                        if tt.primary == "" {
+                                // Make the primary fail its next write
+                                outputsMu.Lock()
+                                logFile.Close()
+                                outputsMu.Unlock()
+                        }
+
+                        Info("first")
+                        Info("second")
+                        if len(hookErrors) == 0 {
+                                t.Errorf("write failure not reported")
+                        }
+                        if !tt.failover {
+                                if _, err := os.Stat(failover); err == nil {
+                                        t.Errorf("failover file created without SetFailoverFile")
+                                }
+                                return
+                        }
+                        got := readLog(t, failover)
+                        for _, want := range []string{"first", "Writing to failover log file " + failover + ", " + primary + " failed", "second"} {
+                                if !strings.Contains(got, want) {
+                                        t.Errorf("failover file lacks %q:\n%s", want, got)
+                                }
+                        }
+
+                        clock.Advance(9 * time.Second)
+                        Info("before the retry")
+                        clock.Advance(time.Second)
+                        Info("after the retry")
+
+                        got = readLog(t, failover)
+                        if !strings.Contains(got, "before the retry") {
+                                t.Errorf("record logged before the retry missing from the failover file:\n%s", got)
+                        }
+                        if strings.Contains(got, "after the retry") == tt.wantRecovery {
+                                t.Errorf("record logged after the retry in the failover file: %v, want %v:\n%s", !tt.wantRecovery, tt.wantRecovery, got)
+                        }
+                        if !tt.wantRecovery {
+                                return
+                        }
+                        back := readLog(t, primary)
+                        for _, want := range []string{"Log file " + primary + " is writable again", "after the retry"} {
+                                if !strings.Contains(back, want) {
+                                        t.Errorf("primary file lacks %q:\n%s", want, back)
+                                }
+                        }
+                })
+        }
+}
@@ -0,0 +1,544 @@
+// File: fields.go
+// Description:
+// Structured fields support. Fields can be attached to a log message either
+// through an Entry (WithField/WithFields) or as alternating key/value pairs
+// (Debugw, Infow, ...). Fields are rendered after the message as key=value.
+
+package logger
+
+import (
+        "errors"
+        "fmt"
+        "hash/fnv"
+        "os"
+        "sort"
+        "strings"
+        "sync"
+        "sync/atomic"
+)
+
+// Fields is a set of structured key/value pairs attached to a log message
+type Fields map[string]interface{}
+
+// Entry is a log message builder carrying structured fields
+type Entry struct {
+        fields   Fields
+        skip     int    // Extra frames to skip when looking up the caller
+        errStack string // Stack of the error attached with WithError, if any
+}
+
+// redactedValue replaces the value of any redacted field
+const redactedValue = "***"
+
+// SequenceKey is the field name of the per-record sequence number
+const SequenceKey = "seq"
+
+// FingerprintKey is the field name of the call site fingerprint
+const FingerprintKey = "fingerprint"
+
+// HostKey is the field name of the host name
+const HostKey = "host"
+
+// TruncatedKey is the field name of the number of fields dropped by SetMaxFields
+const TruncatedKey = "fields_truncated"
+
+var (
+        // Attach a sequence number to every record (accessed atomically)
+        includeSequence int32
+
+        // Attach a call site fingerprint to every record (accessed atomically)
+        includeFingerprint int32
+
+        // Attach the host name to every record
+        includeHostname bool
+        hostname        string
+        hostnameMu      sync.RWMutex
+
+        // Fields attached to every record, e.g. from the environment
+        globalFields   Fields
+        globalFieldsMu sync.RWMutex
+
+        // Fields computed for every record, by name
+        dynamicFields   = map[string]func() interface{}{}
+        dynamicFieldsMu sync.RWMutex
+
+        // Maximum fields passed with a record, 0 for unlimited (accessed atomically)
+        maxFields int64
+
+        // Last sequence number handed out (accessed atomically)
+        sequence uint64
+
+        // Field names whose values are always redacted (lower-cased)
+        redactedKeys   = map[string]bool{}
+        redactedKeysMu sync.RWMutex
+)
+
+// RedactFields marks field names whose values must never be logged.
+// Matching is case-insensitive and the value is replaced with "***".
+func RedactFields(keys ...string) {
+        redactedKeysMu.Lock()
+        defer redactedKeysMu.Unlock()
+        for _, key := range keys {
+                redactedKeys[strings.ToLower(key)] = true
+        }
+}
+
+// clearRedactedFields forgets all field names registered with RedactFields
+func clearRedactedFields() {
+        redactedKeysMu.Lock()
+        defer redactedKeysMu.Unlock()
+        redactedKeys = map[string]bool{}
+}
+
+// isRedacted reports whether the value of the given field must be hidden
+func isRedacted(key string) bool {
+        redactedKeysMu.RLock()
+        defer redactedKeysMu.RUnlock()
+        return redactedKeys[strings.ToLower(key)]
+}
+
+// SetIncludeSequence attaches a monotonically increasing "seq" field to every
+// record written, so consumers can detect lost or reordered lines. The counter
+// starts at 1 when the process starts (and after Reset).
+func SetIncludeSequence(enabled bool) {
+        var v int32
+        if enabled {
+                v = 1
+        }
+        atomic.StoreInt32(&includeSequence, v)
+}
+
+// withSequence adds the next sequence number to fields if enabled
+func withSequence(fields Fields) Fields {
+        if atomic.LoadInt32(&includeSequence) == 0 {
+                return fields
+        }
+        return withField(fields, SequenceKey, atomic.AddUint64(&sequence, 1))
+}
+
+// SetIncludeFingerprint attaches a "fingerprint" field identifying the log
+// statement rather than the message: a hash of the level and the format
+// string (or of the caller's file:line for unformatted calls). It is stable
+// across runs, so aggregators can group records from the same statement.
+func SetIncludeFingerprint(enabled bool) {
+        var v int32
+        if enabled {
+                v = 1
+        }
+        atomic.StoreInt32(&includeFingerprint, v)
+}
+
+// withFingerprint adds the call site fingerprint to fields if enabled
+func withFingerprint(fields Fields, level int, format, caller string) Fields {
+        if atomic.LoadInt32(&includeFingerprint) == 0 {
+                return fields
+        }
+        template := format
+        if template == "" {
+                template = caller
+        }
+        h := fnv.New64a()
+        fmt.Fprintf(h, "%d\x00%s", level, template)
+        return withField(fields, FingerprintKey, fmt.Sprintf("%016x", h.Sum64()))
+}
+
+// SetIncludeHostname attaches a "host" field to every record. The host name
+// is looked up once with os.Hostname unless set with SetHostname.
+func SetIncludeHostname(enabled bool) {
+        hostnameMu.Lock()
+        defer hostnameMu.Unlock()
+        includeHostname = enabled
+        if enabled && hostname == "" {
+                hostname, _ = os.Hostname()
+        }
+}
+
+// SetHostname overrides the host name reported in the "host" field, e.g.
+// in containers where the kernel host name is not meaningful
+func SetHostname(name string) {
+        hostnameMu.Lock()
+        defer hostnameMu.Unlock()
+        hostname = name
+}
+
+// withHostname adds the cached host name to fields if enabled
+func withHostname(fields Fields) Fields {
+        hostnameMu.RLock()
+        defer hostnameMu.RUnlock()
+        if !includeHostname {
+                return fields
+        }
+        return withField(fields, HostKey, hostname)
+}
+
+// SetGlobalFieldsFromEnv attaches fields read from environment variables to
+// every record. The mapping goes from variable name to field name, e.g.
+// {"APP_ENV": "env", "AWS_REGION": "region"}; the variables are read once,
+// when it is called, and unset or empty ones are left out. Fields passed
+// with the record take precedence. A nil mapping removes the fields.
+func SetGlobalFieldsFromEnv(mapping map[string]string) {
+        fields := Fields{}
+        for env, key := range mapping {
+                if value := os.Getenv(env); value != "" {
+                        fields[key] = value
+                }
+        }
+        globalFieldsMu.Lock()
+        defer globalFieldsMu.Unlock()
+        globalFields = fields
+}
+
+// withGlobalFields adds the global fields not already set in fields
+func withGlobalFields(fields Fields) Fields {
+        globalFieldsMu.RLock()
+        defer globalFieldsMu.RUnlock()
+        if len(globalFields) == 0 {
+                return fields
+        }
+        merged := make(Fields, len(fields)+len(globalFields))
+        for k, v := range globalFields {
+                merged[k] = v
+        }
+        for k, v := range fields {
+                merged[k] = v
+        }
+        return merged
+}
+
+// RegisterDynamicField attaches a field to every record whose value is
+// computed by fn when the record is logged, e.g. the goroutine count or
+// memory in use. fn must be cheap and safe for concurrent use; a panic in
+// fn is reported to the error hook and logged as the field value. Fields
+// passed with the record take precedence. A nil fn removes the field.
+func RegisterDynamicField(name string, fn func() interface{}) {
+        dynamicFieldsMu.Lock()
+        defer dynamicFieldsMu.Unlock()
+        if fn == nil {
+                delete(dynamicFields, name)
+                return
+        }
+        dynamicFields[name] = fn
+}
+
+// clearDynamicFields removes every dynamic field
+func clearDynamicFields() {
+        dynamicFieldsMu.Lock()
+        defer dynamicFieldsMu.Unlock()
+        dynamicFields = map[string]func() interface{}{}
+}
+
+// withDynamicFields adds the values of the dynamic fields not already set
+// in fields
+func withDynamicFields(fields Fields) Fields {
+        dynamicFieldsMu.RLock()
+        defer dynamicFieldsMu.RUnlock()
+        if len(dynamicFields) == 0 {
+                return fields
+        }
+        merged := make(Fields, len(fields)+len(dynamicFields))
+        for name, fn := range dynamicFields {
+                if _, ok := fields[name]; !ok {
+                        merged[name] = dynamicValue(name, fn)
+                }
+        }
+        for k, v := range fields {
+                merged[k] = v
+        }
+        return merged
+}
+
+// dynamicValue calls fn, turning a panic into an error value
+func dynamicValue(name string, fn func() interface{}) (value interface{}) {
+        defer func() {
+                if r := recover(); r != nil {
+                        err := fmt.Errorf("dynamic field %s panicked: %v", name, r)
+                        reportError(err)
+                        value = err.Error()
+                }
+        }()
+        return fn()
+}
+
+// SetMaxFields limits the number of fields passed with a record, guarding
+// against accidentally dumping a huge map. Beyond the limit, the fields
+// sorted last by key are dropped and a "fields_truncated" field tells how
+// many. Automatic fields (sequence, host, ...) are not counted. Zero, the
+// default, means no limit.
+func SetMaxFields(n int) {
+        atomic.StoreInt64(&maxFields, int64(n))
+}
+
+// limitFields drops the fields beyond the SetMaxFields limit
+func limitFields(fields Fields) Fields {
+        max := int(atomic.LoadInt64(&maxFields))
+        if max <= 0 || len(fields) <= max {
+                return fields
+        }
+        keys := make([]string, 0, len(fields))
+        for k := range fields {
+                keys = append(keys, k)
+        }
+        sort.Strings(keys)
+
+        limited := make(Fields, max+1)
+        for _, k := range keys[:max] {
+                limited[k] = fields[k]
+        }
+        limited[TruncatedKey] = len(fields) - max
+        return limited
+}
+
+// withField returns a copy of fields with key set, leaving fields untouched
+func withField(fields Fields, key string, value interface{}) Fields {
+        merged := make(Fields, len(fields)+1)
+        for k, v := range fields {
+                merged[k] = v
+        }
+        merged[key] = value
+        return merged
+}
+
+// WithField returns an entry with a single field attached
+func WithField(key string, value interface{}) *Entry {
+        return WithFields(Fields{key: value})
+}
+
+// WithFields returns an entry with the given fields attached
+func WithFields(fields Fields) *Entry {
+        e := &Entry{}
+        return e.WithFields(fields)
+}
+
+// WithField returns a copy of the entry with an additional field
+func (e *Entry) WithField(key string, value interface{}) *Entry {
+        return e.WithFields(Fields{key: value})
+}
+
+// WithFields returns a copy of the entry with the given fields merged in
+func (e *Entry) WithFields(fields Fields) *Entry {
+        if e == nil {
+                e = &Entry{}
+        }
+        merged := make(Fields, len(e.fields)+len(fields))
+        for k, v := range e.fields {
+                merged[k] = v
+        }
+        for k, v := range fields {
+                merged[k] = v
+        }
+        return &Entry{fields: merged, skip: e.skip, errStack: e.errStack}
+}
+
+// WithCallerSkip returns a copy of the entry that reports the caller n
+// frames further up the stack, for adapters that wrap the logger in their
+// own helpers. Skips add up when called repeatedly.
+func (e *Entry) WithCallerSkip(n int) *Entry {
+        c := e.WithFields(nil)
+        c.skip += n
+        return c
+}
+
+// LoggerKey is the field name of the logger name set with Named
+const LoggerKey = "logger"
+
+// Named returns a logger whose records carry a "logger" field with the
+// given name, to tell subsystems apart (e.g. "db")
+func Named(name string) *Logger {
+        return (&Entry{}).Named(name)
+}
+
+// Named returns a copy of the entry with name appended to its logger name,
+// separated by a dot: Named("db").Named("pool") logs logger=db.pool. An
+// empty name leaves the entry unchanged.
+func (e *Entry) Named(name string) *Logger {
+        if name == "" {
+                return e.WithFields(nil)
+        }
+        if e != nil {
+                if parent, ok := e.fields[LoggerKey].(string); ok && parent != "" {
+                        name = parent + "." + name
+                }
+        }
+        return e.WithField(LoggerKey, name)
+}
+
+// ErrorKey is the field name used for errors attached with WithError
+const ErrorKey = "error"
+
+// WithError returns an entry with the error attached as the "error" field.
+// A nil error leaves the entry unchanged.
+func WithError(err error) *Entry {
+        return (&Entry{}).WithError(err)
+}
+
+// WithError returns a copy of the entry with the error attached as the
+// "error" field. Records logged at a level that carries stack traces (see
+// SetStackTraceLevel) get the error's stack as the "stack" field: the one
+// it carries, if any, or else the stack of the WithError call. A nil error
+// leaves the entry unchanged.
+func (e *Entry) WithError(err error) *Entry {
+        if err == nil {
+                return e.WithFields(nil)
+        }
+        c := e.WithFields(Fields{ErrorKey: err.Error()})
+        if atomic.LoadInt32(&stackTraceLevel) >= 0 {
+                c.errStack = errorStack(err, 1)
+        }
+        return c
+}
+
+// entryFields returns the fields of an entry logged at level, with the
+// stack of its error if records of level carry stack traces
+func entryFields(e *Entry, level int) Fields {
+        if e.errStack == "" || !stackTraceEnabled(level) {
+                return e.fields
+        }
+        if _, ok := e.fields[StackKey]; ok {
+                return e.fields
+        }
+        return withField(e.fields, StackKey, e.errStack)
+}
+
+// ErrorWith logs an error message with the error attached as the "error"
+// field and, if error records carry stack traces, the stack the error
+// carries as the "stack" field
+func ErrorWith(err error, msg string) {
+        var fields Fields
+        if err != nil {
+                fields = Fields{ErrorKey: err.Error()}
+                if stackTraceEnabled(LevelError) {
+                        fields[StackKey] = errorStack(err, 1)
+                }
+        }
+        logWithCallerInfo(LevelError, fields, "", msg)
+}
+
+// LogError logs err at a level chosen from the error itself: errors that
+// carry an HTTP status (a StatusCode() int method anywhere in the chain) are
+// logged at warning for 4xx and error otherwise; other errors at error.
+// A nil error is not logged.
+func LogError(err error) {
+        if err == nil {
+                return
+        }
+
+        level := LevelError
+        var fields Fields
+        var coded interface{ StatusCode() int }
+        if errors.As(err, &coded) {
+                status := coded.StatusCode()
+                if status >= 400 && status < 500 {
+                        level = LevelWarning
+                }
+                fields = Fields{"status": status}
+        }
+        logWithCallerInfo(level, fields, "", err.Error())
+}
+
+// Info logs an info message with the entry's fields
+func (e *Entry) Info(v ...interface{}) {
+        logEntry(e, LevelInfo, "", v...)
+}
+
+// Infof logs a formatted info message with the entry's fields
+func (e *Entry) Infof(format string, v ...interface{}) {
+        logEntry(e, LevelInfo, format, v...)
+}
+
+// Warning logs a warning message with the entry's fields
+func (e *Entry) Warning(v ...interface{}) {
+        logEntry(e, LevelWarning, "", v...)
+}
+
+// Warningf logs a formatted warning message with the entry's fields
+func (e *Entry) Warningf(format string, v ...interface{}) {
+        logEntry(e, LevelWarning, format, v...)
+}
+
+// Error logs an error message with the entry's fields
+func (e *Entry) Error(v ...interface{}) {
+        logEntry(e, LevelError, "", v...)
+}
+
+// Errorf logs a formatted error message with the entry's fields
+func (e *Entry) Errorf(format string, v ...interface{}) {
+        logEntry(e, LevelError, format, v...)
+}
+
+// Fatal logs a fatal message with the entry's fields and exits the program
+func (e *Entry) Fatal(v ...interface{}) {
+        logEntry(e, LevelFatal, "", v...)
+        exitFunc(FatalExitCode())
+}
+
+// Fatalf logs a formatted fatal message with the entry's fields and exits the program
+func (e *Entry) Fatalf(format string, v ...interface{}) {
+        logEntry(e, LevelFatal, format, v...)
+        exitFunc(FatalExitCode())
+}
+
+// Infow logs an info message with alternating key/value pairs
+func Infow(msg string, keysAndValues ...interface{}) {
+        logWithCallerInfo(LevelInfo, kvToFields(keysAndValues), "", msg)
+}
+
+// Warningw logs a warning message with alternating key/value pairs
+func Warningw(msg string, keysAndValues ...interface{}) {
+        logWithCallerInfo(LevelWarning, kvToFields(keysAndValues), "", msg)
+}
+
+// Errorw logs an error message with alternating key/value pairs
+func Errorw(msg string, keysAndValues ...interface{}) {
+        logWithCallerInfo(LevelError, kvToFields(keysAndValues), "", msg)
+}
+
+// kvToFields converts alternating key/value pairs into Fields.
+// A trailing key without a value is kept with a nil value.
+func kvToFields(keysAndValues []interface{}) Fields {
+        if len(keysAndValues) == 0 {
+                return nil
+        }
+        fields := make(Fields, (len(keysAndValues)+1)/2)
+        for i := 0; i < len(keysAndValues); i += 2 {
+                key := fmt.Sprint(keysAndValues[i])
+                if i+1 < len(keysAndValues) {
+                        fields[key] = keysAndValues[i+1]
+                } else {
+                        fields[key] = nil
+                }
+        }
+        return fields
+}
+
+// formatFields renders fields as space separated key=value pairs sorted by key
+func formatFields(fields Fields) string {
+        if len(fields) == 0 {
+                return ""
+        }
+        keys := make([]string, 0, len(fields))
+        for k := range fields {
+                keys = append(keys, k)
+        }
+        sort.Strings(keys)
+
+        var b strings.Builder
+        for i, k := range keys {
+                if i > 0 {
+                        b.WriteByte(' ')
+                }
+                fmt.Fprintf(&b, "%s=%v", k, fieldValue(k, fields[k]))
+        }
+        return b.String()
+}
+
+// fieldValue returns the value to render for a field, applying redaction,
+// binary formatting and marshalers
+func fieldValue(key string, value interface{}) interface{} {
+        if isRedacted(key) {
+                return redactedValue
+        }
+        if b, ok := value.([]byte); ok {
+                return binary(b)
+        }
+        return marshalField(value)
+}
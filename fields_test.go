@@ -0,0 +1,642 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "hash/fnv"
+        "os"
+        "runtime"
+        "strings"
+        "testing"
+)
+
+func TestRedactFields(t *testing.T) {
+        tests := []struct {
+                name    string
+                log     func()
+                hidden  []string
+                visible []string
+        }{
+                {
+                        name: "WithFields",
+                        log: func() {
+                                WithFields(Fields{"password": "hunter2", "user": "alice"}).Info("login")
+                        },
+                        hidden:  []string{"hunter2"},
+                        visible: []string{"password=***", "user=alice"},
+                },
+                {
+                        name: "key/value pairs",
+                        log: func() {
+                                Infow("login", "ssn", "123-45-6789", "user", "bob")
+                        },
+                        hidden:  []string{"123-45-6789"},
+                        visible: []string{"ssn=***", "user=bob"},
+                },
+                {
+                        name: "case-insensitive key",
+                        log: func() {
+                                WithField("Password", "hunter2").Info("login")
+                        },
+                        hidden:  []string{"hunter2"},
+                        visible: []string{"Password=***"},
+                },
+                {
+                        name: "non-string value",
+                        log: func() {
+                                Infow("payment", "ssn", 123456789, "amount", 42)
+                        },
+                        hidden:  []string{"123456789"},
+                        visible: []string{"ssn=***", "amount=42"},
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        RedactFields("password", "ssn")
+                        tt.log()
+                        got := out.String()
+                        for _, s := range tt.hidden {
+                                if strings.Contains(got, s) {
+                                        t.Errorf("output %q leaks %q", got, s)
+                                }
+                        }
+                        for _, s := range tt.visible {
+                                if !strings.Contains(got, s) {
+                                        t.Errorf("output %q lacks %q", got, s)
+                                }
+                        }
+                })
+        }
+}
+
+// stackError is an error carrying the stack it was created with
+type stackError struct {
+        pcs []uintptr
+}
+
+func (e *stackError) Error() string { return "stack error" }
+
+func (e *stackError) StackTrace() []uintptr { return e.pcs }
+
+// newStackError returns a stackError created here
+func newStackError() error {
+        pcs := make([]uintptr, 32)
+        return &stackError{pcs[:runtime.Callers(1, pcs)]}
+}
+
+func TestWithError(t *testing.T) {
+        boom := errors.New("boom")
+        tests := []struct {
+                name      string
+                stacks    bool
+                stackAt   int // Stack trace level when stacks is set, 0 for LevelError
+                log       func()
+                wantError interface{} // nil for no error field
+                wantStack string      // Function expected in the stack, "" for no stack
+        }{
+                {name: "entry", log: func() { WithError(boom).Error("failed") }, wantError: "boom"},
+                {name: "entry with nil error", log: func() { WithError(nil).Error("failed") }},
+                {name: "ErrorWith", log: func() { ErrorWith(boom, "failed") }, wantError: "boom"},
+                {name: "ErrorWith nil error", log: func() { ErrorWith(nil, "failed") }},
+                {name: "wrapped", log: func() { ErrorWith(fmt.Errorf("saving: %w", boom), "failed") }, wantError: "saving: boom"},
+                {
+                        name:      "stack of the call",
+                        stacks:    true,
+                        log:       func() { WithError(boom).Error("failed") },
+                        wantError: "boom",
+                        wantStack: "TestWithError",
+                },
+                {
+                        name:      "info below the stack level",
+                        stacks:    true,
+                        log:       func() { WithError(boom).Info("failed") },
+                        wantError: "boom",
+                },
+                {
+                        name:      "error below the stack level",
+                        stacks:    true,
+                        stackAt:   LevelFatal,
+                        log:       func() { WithError(boom).Error("failed") },
+                        wantError: "boom",
+                },
+                {
+                        name:      "ErrorWith below the stack level",
+                        stacks:    true,
+                        stackAt:   LevelFatal,
+                        log:       func() { ErrorWith(boom, "failed") },
+                        wantError: "boom",
+                },
+                {
+                        name:      "stack carried by the error",
+                        stacks:    true,
+                        log:       func() { ErrorWith(fmt.Errorf("wrapped: %w", newStackError()), "failed") },
+                        wantError: "wrapped: stack error",
+                        wantStack: "newStackError",
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetFormat(FormatJSON)
+                        if tt.stacks {
+                                level := tt.stackAt
+                                if level == 0 {
+                                        level = LevelError
+                                }
+                                SetStackTraceLevel(level)
+                                SetStackFilter(func(runtime.Frame) bool { return true })
+                        }
+                        tt.log()
+                        records := out.Records(t)
+                        if len(records) != 1 {
+                                t.Fatalf("got %d records, want 1", len(records))
+                        }
+                        got, ok := records[0][ErrorKey]
+                        if tt.wantError == nil {
+                                if ok {
+                                        t.Errorf("unexpected error field %v", got)
+                                }
+                        } else if got != tt.wantError {
+                                t.Errorf("error field %v, want %v", got, tt.wantError)
+                        }
+                        stack, _ := records[0][StackKey].(string)
+                        if tt.wantStack == "" {
+                                if stack != "" {
+                                        t.Errorf("unexpected stack %q", stack)
+                                }
+                        } else if !strings.Contains(stack, tt.wantStack) {
+                                t.Errorf("stack %q lacks %s", stack, tt.wantStack)
+                        }
+                })
+        }
+}
+
+func TestIncludeSequence(t *testing.T) {
+        tests := []struct {
+                name    string
+                enabled bool
+                records int
+        }{
+                {name: "enabled", enabled: true, records: 5},
+                {name: "disabled", records: 2},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetFormat(FormatJSON)
+                        SetIncludeSequence(tt.enabled)
+                        for i := 0; i < tt.records; i++ {
+                                Info("record")
+                        }
+                        for i, record := range out.Records(t) {
+                                seq, ok := record[SequenceKey]
+                                if !tt.enabled {
+                                        if ok {
+                                                t.Errorf("record %d has a sequence number", i)
+                                        }
+                                        continue
+                                }
+                                if seq != float64(i+1) {
+                                        t.Errorf("record %d has sequence %v, want %d", i, seq, i+1)
+                                }
+                        }
+                })
+        }
+}
+
+func TestFingerprint(t *testing.T) {
+        tests := []struct {
+                name  string
+                first func()
+                other func()
+                same  bool
+        }{
+                {
+                        name:  "same format, different arguments",
+                        first: func() { Infof("user %s logged in", "alice") },
+                        other: func() { Infof("user %s logged in", "bob") },
+                        same:  true,
+                },
+                {
+                        name:  "different format",
+                        first: func() { Infof("user %s logged in", "alice") },
+                        other: func() { Infof("user %s logged out", "alice") },
+                },
+                {
+                        name:  "different level",
+                        first: func() { Infof("user %s logged in", "alice") },
+                        other: func() { Warningf("user %s logged in", "alice") },
+                },
+                {
+                        name:  "same call site without format",
+                        first: func() { logTwice("a", "b") },
+                        other: func() {},
+                        same:  true,
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetFormat(FormatJSON)
+                        SetIncludeFingerprint(true)
+                        tt.first()
+                        tt.other()
+                        records := out.Records(t)
+                        if len(records) != 2 {
+                                t.Fatalf("got %d records, want 2", len(records))
+                        }
+                        a, b := records[0][FingerprintKey], records[1][FingerprintKey]
+                        if a == nil || b == nil {
+                                t.Fatalf("missing fingerprint: %v, %v", a, b)
+                        }
+                        if (a == b) != tt.same {
+                                t.Errorf("fingerprints %v and %v, want same: %v", a, b, tt.same)
+                        }
+                })
+        }
+}
+
+// logTwice logs each value from the same call site
+func logTwice(values ...string) {
+        for _, v := range values {
+                Info(v)
+        }
+}
+
+func TestFingerprintStable(t *testing.T) {
+        out := captureOutput(t)
+        SetFormat(FormatJSON)
+        SetIncludeFingerprint(true)
+        Infof("user %s logged in", "alice")
+
+        // FNV-1a of the level and the format, independent of the process
+        h := fnv.New64a()
+        h.Write([]byte("1\x00user %s logged in"))
+        want := fmt.Sprintf("%016x", h.Sum64())
+        if got := out.Records(t)[0][FingerprintKey]; got != want {
+                t.Errorf("fingerprint %v, want %s", got, want)
+        }
+}
+
+func TestIncludeHostname(t *testing.T) {
+        machine, _ := os.Hostname()
+        tests := []struct {
+                name     string
+                enabled  bool
+                override string
+                want     interface{} // nil for no host field
+        }{
+                {name: "configured host", enabled: true, override: "web-1", want: "web-1"},
+                {name: "machine host", enabled: true, want: machine},
+                {name: "disabled", override: "web-1"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetFormat(FormatJSON)
+                        SetHostname(tt.override)
+                        SetIncludeHostname(tt.enabled)
+                        Info("first")
+                        Info("second")
+                        for _, record := range out.Records(t) {
+                                if got := record[HostKey]; got != tt.want {
+                                        t.Errorf("host field %v, want %v", got, tt.want)
+                                }
+                        }
+                })
+        }
+}
+
+// statusError is an error carrying an HTTP status
+type statusError struct {
+        status int
+}
+
+func (e statusError) Error() string   { return fmt.Sprintf("status %d", e.status) }
+func (e statusError) StatusCode() int { return e.status }
+
+func TestLogError(t *testing.T) {
+        tests := []struct {
+                name       string
+                err        error
+                wantLevel  interface{} // nil for no record
+                wantStatus interface{}
+        }{
+                {name: "client error", err: statusError{404}, wantLevel: "warning", wantStatus: float64(404)},
+                {name: "server error", err: statusError{503}, wantLevel: "error", wantStatus: float64(503)},
+                {name: "wrapped client error", err: fmt.Errorf("fetching: %w", statusError{429}), wantLevel: "warning", wantStatus: float64(429)},
+                {name: "plain error", err: errors.New("boom"), wantLevel: "error"},
+                {name: "nil error"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetFormat(FormatJSON)
+                        LogError(tt.err)
+                        records := out.Records(t)
+                        if tt.wantLevel == nil {
+                                if len(records) != 0 {
+                                        t.Errorf("nil error logged: %v", records)
+                                }
+                                return
+                        }
+                        if len(records) != 1 {
+                                t.Fatalf("got %d records, want 1", len(records))
+                        }
+                        if got := records[0]["level"]; got != tt.wantLevel {
+                                t.Errorf("level %v, want %v", got, tt.wantLevel)
+                        }
+                        if got := records[0]["status"]; got != tt.wantStatus {
+                                t.Errorf("status %v, want %v", got, tt.wantStatus)
+                        }
+                        if got := records[0]["message"]; got != tt.err.Error() {
+                                t.Errorf("message %v, want %q", got, tt.err.Error())
+                        }
+                })
+        }
+}
+
+func TestGlobalFieldsFromEnv(t *testing.T) {
+        mapping := map[string]string{
+                "LOGGER_TEST_ENV":     "env",
+                "LOGGER_TEST_REGION":  "region",
+                "LOGGER_TEST_SERVICE": "service",
+        }
+        tests := []struct {
+                name   string
+                env    map[string]string
+                fields Fields
+                want   map[string]interface{} // nil for an absent field
+        }{
+                {
+                        name: "all set",
+                        env:  map[string]string{"LOGGER_TEST_ENV": "prod", "LOGGER_TEST_REGION": "eu-west-1", "LOGGER_TEST_SERVICE": "api"},
+                        want: map[string]interface{}{"env": "prod", "region": "eu-west-1", "service": "api"},
+                },
+                {
+                        name: "missing and empty omitted",
+                        env:  map[string]string{"LOGGER_TEST_ENV": "prod", "LOGGER_TEST_REGION": ""},
+                        want: map[string]interface{}{"env": "prod", "region": nil, "service": nil},
+                },
+                {
+                        name:   "record fields take precedence",
+                        env:    map[string]string{"LOGGER_TEST_ENV": "prod", "LOGGER_TEST_SERVICE": "api"},
+                        fields: Fields{"service": "worker"},
+                        want:   map[string]interface{}{"env": "prod", "service": "worker"},
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetFormat(FormatJSON)
+                        for env := range mapping {
+                                t.Setenv(env, "") // restored after the test
+                                os.Unsetenv(env)
+                        }
+                        for env, value := range tt.env {
+                                t.Setenv(env, value)
+                        }
+                        SetGlobalFieldsFromEnv(mapping)
+                        WithFields(tt.fields).Info("first")
+                        WithFields(tt.fields).Warning("second")
+                        records := out.Records(t)
+                        if len(records) != 2 {
+                                t.Fatalf("got %d records, want 2", len(records))
+                        }
+                        for _, record := range records {
+                                for key, want := range tt.want {
+                                        got, ok := record[key]
+                                        if want == nil && ok {
+                                                t.Errorf("field %s = %v, want it omitted", key, got)
+                                        } else if want != nil && got != want {
+                                                t.Errorf("field %s = %v, want %v", key, got, want)
+                                        }
+                                }
+                        }
+                })
+        }
+}
+
+func TestNamed(t *testing.T) {
+        db := Named("db")
+        tests := []struct {
+                name   string
+                logger func() *Logger
+                want   interface{} // nil for no logger field
+        }{
+                {name: "single", logger: func() *Logger { return Named("db") }, want: "db"},
+                {name: "nested", logger: func() *Logger { return Named("db").Named("pool") }, want: "db.pool"},
+                {name: "three levels", logger: func() *Logger { return Named("db").Named("pool").Named("conn") }, want: "db.pool.conn"},
+                {name: "empty name", logger: func() *Logger { return Named("db").Named("") }, want: "db"},
+                {name: "no name", logger: func() *Logger { return Named("") }},
+                {name: "through fields", logger: func() *Logger { return Named("db").WithField("k", "v").Named("pool") }, want: "db.pool"},
+                {name: "sibling", logger: func() *Logger { db.Named("cache"); return db.Named("pool") }, want: "db.pool"},
+                {name: "parent unchanged", logger: func() *Logger { db.Named("pool"); return db }, want: "db"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetFormat(FormatJSON)
+                        tt.logger().Info("m")
+                        for _, record := range out.Records(t) {
+                                if got := record[LoggerKey]; got != tt.want {
+                                        t.Errorf("%s = %v, want %v", LoggerKey, got, tt.want)
+                                }
+                        }
+                })
+        }
+}
+
+func TestMaxFields(t *testing.T) {
+        fields := Fields{"e": 5, "a": 1, "d": 4, "b": 2, "c": 3}
+        tests := []struct {
+                name      string
+                max       int
+                want      []string // Fields kept
+                truncated int      // Expected marker value, 0 for no marker
+        }{
+                {name: "truncated", max: 3, want: []string{"a", "b", "c"}, truncated: 2},
+                {name: "single field", max: 1, want: []string{"a"}, truncated: 4},
+                {name: "at the limit", max: 5, want: []string{"a", "b", "c", "d", "e"}},
+                {name: "no limit", want: []string{"a", "b", "c", "d", "e"}},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetFormat(FormatJSON)
+                        SetIncludeSequence(true)
+                        SetMaxFields(tt.max)
+                        WithFields(fields).Info("dump")
+
+                        records := out.Records(t)
+                        if len(records) != 1 {
+                                t.Fatalf("got %d records, want 1", len(records))
+                        }
+                        record := records[0]
+                        for _, k := range tt.want {
+                                if _, ok := record[k]; !ok {
+                                        t.Errorf("record %v lacks field %s", record, k)
+                                }
+                        }
+                        kept := 0
+                        for k := range fields {
+                                if _, ok := record[k]; ok {
+                                        kept++
+                                }
+                        }
+                        if kept != len(tt.want) {
+                                t.Errorf("record %v keeps %d fields, want %d", record, kept, len(tt.want))
+                        }
+                        marker, ok := record[TruncatedKey]
+                        if tt.truncated == 0 && ok {
+                                t.Errorf("record %v has a %s marker", record, TruncatedKey)
+                        }
+                        if tt.truncated != 0 && marker != float64(tt.truncated) {
+                                t.Errorf("%s = %v, want %d", TruncatedKey, marker, tt.truncated)
+                        }
+                        if _, ok := record[SequenceKey]; !ok {
+                                t.Errorf("automatic field %s dropped", SequenceKey)
+                        }
+                })
+        }
+}
+
+func TestRegisterDynamicField(t *testing.T) {
+        tests := []struct {
+                name      string
+                fn        func(n int) interface{} // Value of the nth call
+                fields    Fields                  // Fields passed with the records
+                remove    bool                    // Whether the field is removed before the second record
+                want      []interface{}           // Values of the two records, nil for none
+                wantError bool
+        }{
+                {name: "changes per record", fn: func(n int) interface{} { return n }, want: []interface{}{1.0, 2.0}},
+                {name: "record field wins", fn: func(n int) interface{} { return n }, fields: Fields{"load": "fixed"}, want: []interface{}{"fixed", "fixed"}},
+                {name: "removed", fn: func(n int) interface{} { return n }, remove: true, want: []interface{}{1.0, nil}},
+                {
+                        name:      "panic",
+                        fn:        func(n int) interface{} { panic("sensor offline") },
+                        want:      []interface{}{"dynamic field load panicked: sensor offline", "dynamic field load panicked: sensor offline"},
+                        wantError: true,
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetFormat(FormatJSON)
+                        var hookErrors []error
+                        SetErrorHook(func(err error) { hookErrors = append(hookErrors, err) })
+                        calls := 0
+                        RegisterDynamicField("load", func() interface{} {
+                                calls++
+                                return tt.fn(calls)
+                        })
+                        WithFields(tt.fields).Info("first")
+                        if tt.remove {
+                                RegisterDynamicField("load", nil)
+                        }
+                        WithFields(tt.fields).Info("second")
+
+                        records := out.Records(t)
+                        if len(records) != 2 {
+                                t.Fatalf("got %d records, want 2", len(records))
+                        }
+                        for i, record := range records {
+                                if got := record["load"]; got != tt.want[i] {
+                                        t.Errorf("record %d load = %v, want %v", i, got, tt.want[i])
+                                }
+                        }
+                        if tt.fields != nil && calls != 0 {
+                                t.Errorf("dynamic field computed %d times for records setting it", calls)
+                        }
+                        if (len(hookErrors) > 0) != tt.wantError {
+                                t.Errorf("error hook got %v", hookErrors)
+                        }
+                })
+        }
+}
+
+func TestSequenceAtWrite(t *testing.T) {
+        tests := []struct {
+                name string
+                log  func()
+                want []string // Messages written, numbered 1, 2, ... in this order
+        }{
+                {
+                        name: "discarded buffer",
+                        log: func() {
+                                buf := BeginBuffered()
+                                buf.Info("discarded")
+                                buf.Discard()
+                                Info("direct")
+                                Info("direct again")
+                        },
+                        want: []string{"direct", "direct again"},
+                },
+                {
+                        name: "flushed later",
+                        log: func() {
+                                buf := BeginBuffered()
+                                buf.Info("buffered")
+                                Info("direct")
+                                buf.Flush()
+                        },
+                        want: []string{"direct", "buffered"},
+                },
+                {
+                        name: "request log without error",
+                        log: func() {
+                                _, l := BeginRequestLogging(context.Background())
+                                l.Debug("dropped debug")
+                                l.Info("held info")
+                                Info("direct")
+                                l.End()
+                        },
+                        want: []string{"direct", "held info"},
+                },
+                {
+                        name: "rate limited",
+                        log: func() {
+                                SetRateLimit(1, 1)
+                                Info("allowed")
+                                Info("limited")
+                                SetRateLimit(0, 0)
+                                Info("after the limit")
+                        },
+                        want: []string{"allowed", "after the limit"},
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        useFakeClock(t)
+                        SetFormat(FormatJSON)
+                        SetIncludeSequence(true)
+                        SetIncludeEventID(true)
+                        tt.log()
+
+                        records := out.Records(t)
+                        if len(records) != len(tt.want) {
+                                t.Fatalf("got %d records, want %d", len(records), len(tt.want))
+                        }
+                        var lastID string
+                        for i, record := range records {
+                                if record["message"] != tt.want[i] || record[SequenceKey] != float64(i+1) {
+                                        t.Errorf("record %d: %v, %v; want %q, %d", i, record["message"], record[SequenceKey], tt.want[i], i+1)
+                                }
+                                id, _ := record[EventIDKey].(string)
+                                if id <= lastID {
+                                        t.Errorf("event id %q not after %q", id, lastID)
+                                }
+                                lastID = id
+                        }
+                        if got := GetStats().TotalRecords; got != uint64(len(tt.want)) {
+                                t.Errorf("TotalRecords = %d, want %d", got, len(tt.want))
+                        }
+                })
+        }
+}
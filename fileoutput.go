@@ -0,0 +1,131 @@
+// File: fileoutput.go
+// Description:
+// Additional file outputs and tracking of the file handles opened for them.
+// The number of handles can be capped with SetMaxOpenFiles, and CloseLogger
+// warns about handles still open afterwards, which points at outputs that
+// were dropped without being closed.
+
+package logger
+
+import (
+        "fmt"
+        "os"
+        "sync"
+        "sync/atomic"
+)
+
+var (
+        // File handles opened for additional outputs (accessed atomically)
+        openHandles int64
+
+        // Maximum number of open log files, 0 for no limit (accessed atomically)
+        maxOpenFiles int64
+)
+
+// SetMaxOpenFiles caps the number of files the logger keeps open: the log
+// file plus the files of AddFileOutput and AddShardedOutput. Adding an
+// output beyond the cap fails. Zero (the default) means no limit.
+func SetMaxOpenFiles(n int) {
+        atomic.StoreInt64(&maxOpenFiles, int64(n))
+}
+
+// OpenFiles returns the number of files the logger currently keeps open
+func OpenFiles() int {
+        outputsMu.Lock()
+        n := atomic.LoadInt64(&openHandles)
+        if logFile != nil {
+                n++
+        }
+        outputsMu.Unlock()
+        return int(n)
+}
+
+// reserveHandles checks that n more files fit under the cap
+func reserveHandles(n int) error {
+        limit := atomic.LoadInt64(&maxOpenFiles)
+        if limit <= 0 {
+                return nil
+        }
+        if open := OpenFiles(); int64(open+n) > limit {
+                return fmt.Errorf("too many open log files: %d open, limit %d", open, limit)
+        }
+        return nil
+}
+
+// checkHandleLeaks warns about file handles still open once every output
+// was closed
+func checkHandleLeaks() {
+        if n := atomic.LoadInt64(&openHandles); n > 0 {
+                Warningf("%d log file handles still open after close", n)
+        }
+}
+
+// fileOutput writes records to a file that is rotated with the log file
+type fileOutput struct {
+        mu     sync.Mutex
+        f      *os.File
+        closed bool
+}
+
+// AddFileOutput adds an output appending records in the given format to
+// the file at path, creating it and its directory if needed. The file is
+// rotated by RotateLogFile and closed by CloseLogger or RemoveOutput. It
+// returns the output id.
+func AddFileOutput(path string, format int) (int, error) {
+        if err := reserveHandles(1); err != nil {
+                return 0, err
+        }
+        f, err := openLogFile(path)
+        if err != nil {
+                return 0, err
+        }
+        atomic.AddInt64(&openHandles, 1)
+        return AddOutput(&fileOutput{f: f}, format), nil
+}
+
+// Write appends one record to the file
+func (o *fileOutput) Write(p []byte) (int, error) {
+        o.mu.Lock()
+        defer o.mu.Unlock()
+        if o.closed {
+                return 0, os.ErrClosed
+        }
+        return o.f.Write(p)
+}
+
+// rotate rotates the file like the main log file
+func (o *fileOutput) rotate() error {
+        o.mu.Lock()
+        defer o.mu.Unlock()
+        if o.closed {
+                return nil
+        }
+        newFile, newPath, err := rotateFile(o.f)
+        if err != nil {
+                if newFile != nil {
+                        o.f = newFile // Keep writing to the same file
+                } else {
+                        o.closed = true
+                        atomic.AddInt64(&openHandles, -1)
+                }
+                return err
+        }
+        o.f = newFile
+
+        if err := compactRotated(newPath); err != nil {
+                return err
+        }
+        return pruneBackupsFor(newFile.Name())
+}
+
+// Close closes the file
+func (o *fileOutput) Close() error {
+        o.mu.Lock()
+        defer o.mu.Unlock()
+        if o.closed {
+                return nil
+        }
+        o.closed = true
+        atomic.AddInt64(&openHandles, -1)
+        return o.f.Close()
+}
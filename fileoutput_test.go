@@ -0,0 +1,158 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "fmt"
+        "path/filepath"
+        "strings"
+        "sync/atomic"
+        "testing"
+)
+
+func TestMaxOpenFiles(t *testing.T) {
+        tests := []struct {
+                name      string
+                max       int
+                logFile   bool
+                adds      int
+                wantAdded int
+        }{
+                {name: "under the cap", max: 3, logFile: true, adds: 2, wantAdded: 2},
+                {name: "over the cap", max: 2, logFile: true, adds: 3, wantAdded: 1},
+                {name: "no log file", max: 2, adds: 3, wantAdded: 2},
+                {name: "no limit", adds: 5, wantAdded: 5},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        dir := t.TempDir()
+                        if tt.logFile {
+                                if err := InitLogger(LevelInfo, true, filepath.Join(dir, "app.log")); err != nil {
+                                        t.Fatal(err)
+                                }
+                        }
+                        SetMaxOpenFiles(tt.max)
+                        added := 0
+                        for i := 0; i < tt.adds; i++ {
+                                _, err := AddFileOutput(filepath.Join(dir, fmt.Sprintf("extra-%d.log", i)), FormatText)
+                                if err != nil {
+                                        if !strings.Contains(err.Error(), "too many open log files") {
+                                                t.Errorf("AddFileOutput error %v", err)
+                                        }
+                                        continue
+                                }
+                                added++
+                        }
+                        if added != tt.wantAdded {
+                                t.Errorf("added %d outputs, want %d", added, tt.wantAdded)
+                        }
+                        wantOpen := tt.wantAdded
+                        if tt.logFile {
+                                wantOpen++
+                        }
+                        if got := OpenFiles(); got != wantOpen {
+                                t.Errorf("OpenFiles() = %d, want %d", got, wantOpen)
+                        }
+                })
+        }
+}
+
+func TestMaxOpenFilesReleased(t *testing.T) {
+        captureOutput(t)
+        dir := t.TempDir()
+        SetMaxOpenFiles(2)
+        if err := AddShardedOutput(dir, "shard", 3); err == nil {
+                t.Error("sharded output over the cap added")
+        }
+        id, err := AddFileOutput(filepath.Join(dir, "a.log"), FormatText)
+        if err != nil {
+                t.Fatal(err)
+        }
+        if _, err := AddFileOutput(filepath.Join(dir, "b.log"), FormatText); err != nil {
+                t.Fatal(err)
+        }
+        if _, err := AddFileOutput(filepath.Join(dir, "c.log"), FormatText); err == nil {
+                t.Fatal("output over the cap added")
+        }
+        if err := RemoveOutput(id); err != nil {
+                t.Fatal(err)
+        }
+        if _, err := AddFileOutput(filepath.Join(dir, "c.log"), FormatText); err != nil {
+                t.Errorf("removed output didn't free its handle: %v", err)
+        }
+}
+
+func TestHandleLeakWarning(t *testing.T) {
+        tests := []struct {
+                name   string
+                leaked int64
+                want   string // empty for no warning
+        }{
+                {name: "all closed"},
+                {name: "leaked handle", leaked: 1, want: "1 log file handles still open after close"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        if _, err := AddFileOutput(filepath.Join(t.TempDir(), "extra.log"), FormatText); err != nil {
+                                t.Fatal(err)
+                        }
+                        // Simulate an output dropped without being closed
+                        atomic.AddInt64(&openHandles, tt.leaked)
+                        defer atomic.AddInt64(&openHandles, -tt.leaked)
+                        CloseLogger()
+                        got := out.String()
+                        if tt.want == "" && strings.Contains(got, "still open") {
+                                t.Errorf("unexpected leak warning: %q", got)
+                        }
+                        if tt.want != "" && !strings.Contains(got, "[WARN]") {
+                                t.Errorf("output %q, want a warning", got)
+                        }
+                        if !strings.Contains(got, tt.want) {
+                                t.Errorf("output %q, want warning %q", got, tt.want)
+                        }
+                })
+        }
+}
+
+func TestTextAndJSONFiles(t *testing.T) {
+        tests := []struct {
+                name       string
+                mainFormat int // Format of the log file
+                extra      int // Format of the added file output
+        }{
+                {name: "text log file, JSON output", mainFormat: FormatText, extra: FormatJSON},
+                {name: "JSON log file, text output", mainFormat: FormatJSON, extra: FormatText},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        useFakeClock(t)
+                        dir := t.TempDir()
+                        mainPath := filepath.Join(dir, "app.log")
+                        extraPath := filepath.Join(dir, "extra.log")
+                        if err := InitLogger(LevelInfo, true, mainPath); err != nil {
+                                t.Fatal(err)
+                        }
+                        SetFileFormat(tt.mainFormat)
+                        if _, err := AddFileOutput(extraPath, tt.extra); err != nil {
+                                t.Fatal(err)
+                        }
+
+                        WithField("user", "alice").Info("logged in")
+                        line := thisLine() - 1
+                        CloseLogger()
+
+                        files := map[int]string{tt.mainFormat: readLog(t, mainPath), tt.extra: readLog(t, extraPath)}
+                        wantText := fmt.Sprintf("[INFO] 2023/03/08 10:00:00 fileoutput_test.go:%d: logged in user=alice\n", line)
+                        if got := files[FormatText]; got != wantText {
+                                t.Errorf("text file %q, want %q", got, wantText)
+                        }
+                        wantJSON := fmt.Sprintf(`{"time":"2023-03-08T10:00:00Z","level":"info","caller":"fileoutput_test.go:%d","message":"logged in","user":"alice"}`+"\n", line)
+                        if got := files[FormatJSON]; got != wantJSON {
+                                t.Errorf("JSON file %q, want %q", got, wantJSON)
+                        }
+                })
+        }
+}
@@ -0,0 +1,45 @@
+// File: filter.go
+// Description:
+// Record filters. Filters see every record that passed the level check and
+// can drop it before it is written, e.g. to silence health-check spam
+// without touching the call sites.
+
+package logger
+
+import "sync"
+
+// FilterFunc decides whether a record is written; returning false drops it
+type FilterFunc func(level int, msg string, fields map[string]interface{}) bool
+
+var (
+        // Registered filters, all of which must accept a record
+        filters   []FilterFunc
+        filtersMu sync.RWMutex
+)
+
+// AddFilter registers a filter. A record is written only if every
+// registered filter returns true for it.
+func AddFilter(fn FilterFunc) {
+        filtersMu.Lock()
+        defer filtersMu.Unlock()
+        filters = append(filters, fn)
+}
+
+// clearFilters removes every registered filter
+func clearFilters() {
+        filtersMu.Lock()
+        defer filtersMu.Unlock()
+        filters = nil
+}
+
+// passesFilters reports whether every filter accepts the record
+func passesFilters(level int, msg string, fields Fields) bool {
+        filtersMu.RLock()
+        defer filtersMu.RUnlock()
+        for _, fn := range filters {
+                if !fn(level, msg, fields) {
+                        return false
+                }
+        }
+        return true
+}
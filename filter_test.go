@@ -0,0 +1,67 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "strings"
+        "testing"
+)
+
+// dropHealthz drops health check records
+func dropHealthz(level int, msg string, fields map[string]interface{}) bool {
+        return !strings.Contains(msg, "healthz")
+}
+
+// dropDebugPath drops records of the /debug path
+func dropDebugPath(level int, msg string, fields map[string]interface{}) bool {
+        return fields["path"] != "/debug"
+}
+
+func TestFilters(t *testing.T) {
+        tests := []struct {
+                name    string
+                filters []FilterFunc
+                want    []string
+                absent  []string
+        }{
+                {
+                        name: "no filter",
+                        want: []string{"GET /healthz", "GET /orders", "GET /debug"},
+                },
+                {
+                        name:    "message filter",
+                        filters: []FilterFunc{dropHealthz},
+                        want:    []string{"GET /orders", "GET /debug"},
+                        absent:  []string{"healthz"},
+                },
+                {
+                        name:    "filters are combined",
+                        filters: []FilterFunc{dropHealthz, dropDebugPath},
+                        want:    []string{"GET /orders"},
+                        absent:  []string{"healthz", "GET /debug"},
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        for _, fn := range tt.filters {
+                                AddFilter(fn)
+                        }
+                        Info("GET /healthz")
+                        WithField("path", "/orders").Info("GET /orders")
+                        WithField("path", "/debug").Info("GET /debug")
+
+                        got := out.String()
+                        for _, s := range tt.want {
+                                if !strings.Contains(got, s) {
+                                        t.Errorf("output %q lacks %q", got, s)
+                                }
+                        }
+                        for _, s := range tt.absent {
+                                if strings.Contains(got, s) {
+                                        t.Errorf("output %q contains %q", got, s)
+                                }
+                        }
+                })
+        }
+}
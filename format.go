@@ -0,0 +1,338 @@
+// File: format.go
+// Description:
+// Record encoding. Every log call produces a Record that is encoded once per
+// output format. Formats are implemented by Encoders: the built-in ones
+// produce human readable text (the classic "[INFO] date time file:line:
+// message" line), one JSON object per line, logfmt key=value pairs or GELF,
+// and custom encoders can be registered for other formats.
+
+package logger
+
+import (
+        "encoding/json"
+        "fmt"
+        "sort"
+        "strings"
+        "sync/atomic"
+        "time"
+)
+
+// Output formats
+const (
+        FormatText = iota
+        FormatJSON
+        FormatLogfmt
+        FormatGELF
+        FormatPretty // Colorized on terminals, JSON elsewhere
+)
+
+// Record is a single log event ready to be encoded
+type Record struct {
+        Time    time.Time
+        Level   int
+        Caller  string // file:line of the call site, empty if unknown
+        Message string
+        Fields  Fields
+}
+
+// Encoder renders a record for an output. The returned bytes should end
+// with a newline.
+type Encoder interface {
+        Encode(record Record) ([]byte, error)
+}
+
+// TextEncoder renders records as "[LEVEL] 2006/01/02 15:04:05 file:line: message k=v",
+// leaving out the timestamp for levels disabled with SetTimestampForLevel
+type TextEncoder struct{}
+
+// JSONEncoder renders records as single line JSON objects
+type JSONEncoder struct {
+        // Keys written first, in this order; the standard keys not listed
+        // follow in their default order, then the remaining fields sorted.
+        // Empty means time, level, caller, message. Standard keys are
+        // listed under their names in Keys.
+        FieldOrder []string
+
+        // Names of the standard keys
+        Keys FieldKeys
+}
+
+// FieldKeys names the standard keys of the JSON and logfmt formats. Empty
+// names keep the defaults.
+type FieldKeys struct {
+        Time    string // Default "time"
+        Level   string // Default "level"
+        Caller  string // Default "caller"
+        Message string // Default "message" in JSON, "msg" in logfmt
+}
+
+// keyOr returns name, or def if name is empty
+func keyOr(name, def string) string {
+        if name == "" {
+                return def
+        }
+        return name
+}
+
+// formatCustom is the format of the encoder set with SetEncoder
+const formatCustom = FormatPretty + 1
+
+// Encoders by format; the index is the format value (guarded by outputsMu)
+var encoders = builtinEncoders()
+
+// builtinEncoders returns the encoders of the built-in formats, followed by
+// the slot of SetEncoder
+func builtinEncoders() []Encoder {
+        return []Encoder{
+                FormatText:   TextEncoder{},
+                FormatJSON:   JSONEncoder{},
+                FormatLogfmt: LogfmtEncoder{},
+                FormatGELF:   GELFEncoder{},
+                FormatPretty: PrettyEncoder{},
+                formatCustom: TextEncoder{},
+        }
+}
+
+// resetEncoders drops the registered encoders and restores the built-in
+// ones
+func resetEncoders() {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        encoders = builtinEncoders()
+        updateJSONTimeKey()
+}
+
+// RegisterEncoder makes a custom encoder available as a format and returns
+// the format value to use with AddOutput, SetFormat or SetFileFormat
+func RegisterEncoder(enc Encoder) int {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        return registerEncoder(enc)
+}
+
+// registerEncoder adds an encoder; outputsMu must be held
+func registerEncoder(enc Encoder) int {
+        encoders = append(encoders, enc)
+        return len(encoders) - 1
+}
+
+// SetEncoder makes enc the format of stdout and the log file. It replaces
+// the encoder of a previous call rather than registering another format.
+func SetEncoder(enc Encoder) {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        encoders[formatCustom] = enc
+        consoleFormat = formatCustom
+        fileFormat = formatCustom
+}
+
+// SetJSONFieldOrder sets the order of keys in FormatJSON output. Standard
+// keys (time, level, caller, message, or their names set with SetFieldKeys)
+// and field names can be listed; keys not listed keep their default
+// position after them. Without arguments the
+// default order is restored.
+func SetJSONFieldOrder(keys ...string) {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        if enc, ok := encoders[FormatJSON].(JSONEncoder); ok {
+                enc.FieldOrder = keys
+                encoders[FormatJSON] = enc
+        }
+}
+
+// SetFieldKeys renames the standard keys of the FormatJSON and FormatLogfmt
+// outputs, e.g. FieldKeys{Time: "ts", Message: "msg"}, to match what
+// downstream systems expect. The zero value restores the defaults.
+func SetFieldKeys(keys FieldKeys) {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        if enc, ok := encoders[FormatJSON].(JSONEncoder); ok {
+                enc.Keys = keys
+                encoders[FormatJSON] = enc
+                updateJSONTimeKey()
+        }
+        if enc, ok := encoders[FormatLogfmt].(LogfmtEncoder); ok {
+                enc.Keys = keys
+                encoders[FormatLogfmt] = enc
+        }
+}
+
+// encoderFor returns the encoder of a format, text for unknown formats;
+// outputsMu must be held
+func encoderFor(format int) Encoder {
+        if format < 0 || format >= len(encoders) {
+                return encoders[FormatText]
+        }
+        return encoders[format]
+}
+
+// Levels whose text output omits the timestamp (accessed atomically)
+var noTimestamp [LevelFatal + 1]int32
+
+// SetTimestampForLevel controls whether text output includes the timestamp
+// for records of the given level, e.g. to keep debug chatter compact while
+// errors stay timestamped. Timestamps are enabled for every level by default.
+func SetTimestampForLevel(level int, enabled bool) {
+        if level < LevelDebug || level > LevelFatal {
+                return
+        }
+        var v int32
+        if !enabled {
+                v = 1
+        }
+        atomic.StoreInt32(&noTimestamp[level], v)
+}
+
+// clearTimestampLevels enables the timestamp for every level
+func clearTimestampLevels() {
+        for i := range noTimestamp {
+                atomic.StoreInt32(&noTimestamp[i], 0)
+        }
+}
+
+// timestampEnabled reports whether text output of level includes the time
+func timestampEnabled(level int) bool {
+        if level < LevelDebug || level > LevelFatal {
+                return true
+        }
+        return atomic.LoadInt32(&noTimestamp[level]) == 0
+}
+
+// levelTag returns the tag used in text output
+func levelTag(level int) string {
+        switch level {
+        case LevelDebug:
+                return "DEBUG"
+        case LevelInfo:
+                return "INFO"
+        case LevelWarning:
+                return "WARN"
+        case LevelError:
+                return "ERROR"
+        case LevelFatal:
+                return "FATAL"
+        default:
+                return "INFO"
+        }
+}
+
+// levelName returns the level name used in structured output
+func levelName(level int) string {
+        switch level {
+        case LevelDebug:
+                return "debug"
+        case LevelInfo:
+                return "info"
+        case LevelWarning:
+                return "warning"
+        case LevelError:
+                return "error"
+        case LevelFatal:
+                return "fatal"
+        default:
+                return "info"
+        }
+}
+
+// Encode implements Encoder
+func (TextEncoder) Encode(rec Record) ([]byte, error) {
+        var b strings.Builder
+        b.WriteString("[")
+        b.WriteString(levelTag(rec.Level))
+        b.WriteString("] ")
+        if timestampEnabled(rec.Level) {
+                b.WriteString(rec.Time.Format("2006/01/02 15:04:05"))
+                b.WriteString(" ")
+        }
+        if rec.Caller != "" {
+                b.WriteString(rec.Caller)
+                b.WriteString(": ")
+        }
+        b.WriteString(rec.Message)
+        if f := formatFields(rec.Fields); f != "" {
+                b.WriteString(" ")
+                b.WriteString(f)
+        }
+        b.WriteString("\n")
+        return []byte(b.String()), nil
+}
+
+// Encode implements Encoder. The keys of FieldOrder come first, then the
+// other standard keys and the fields sorted by key, so the output does not
+// depend on map iteration order.
+func (e JSONEncoder) Encode(rec Record) ([]byte, error) {
+        timeKey := keyOr(e.Keys.Time, "time")
+        levelKey := keyOr(e.Keys.Level, "level")
+        callerKey := keyOr(e.Keys.Caller, "caller")
+        messageKey := keyOr(e.Keys.Message, "message")
+        standard := map[string]interface{}{
+                timeKey:    rec.Time.Format(time.RFC3339Nano),
+                levelKey:   levelName(rec.Level),
+                messageKey: rec.Message,
+        }
+        if rec.Caller != "" {
+                standard[callerKey] = rec.Caller
+        }
+
+        var b strings.Builder
+        b.WriteString("{")
+        written := map[string]bool{}
+        write := func(key string) {
+                if written[key] {
+                        return
+                }
+                if value, ok := standard[key]; ok {
+                        writeJSONPair(&b, key, value, len(written) == 0)
+                } else if value, ok := rec.Fields[key]; ok {
+                        writeJSONPair(&b, key, jsonValue(fieldValue(key, value)), len(written) == 0)
+                } else {
+                        return
+                }
+                written[key] = true
+        }
+
+        for _, k := range e.FieldOrder {
+                write(k)
+        }
+        for _, k := range []string{timeKey, levelKey, callerKey, messageKey} {
+                write(k)
+        }
+        keys := make([]string, 0, len(rec.Fields))
+        for k := range rec.Fields {
+                keys = append(keys, k)
+        }
+        sort.Strings(keys)
+        for _, k := range keys {
+                write(k)
+        }
+        b.WriteString("}\n")
+        return []byte(b.String()), nil
+}
+
+// writeJSONPair appends "key":value to b
+func writeJSONPair(b *strings.Builder, key string, value interface{}, first bool) {
+        if !first {
+                b.WriteString(",")
+        }
+        k, _ := json.Marshal(key)
+        b.Write(k)
+        b.WriteString(":")
+        v, err := json.Marshal(value)
+        if err != nil {
+                v, _ = json.Marshal(fmt.Sprint(value))
+        }
+        b.Write(v)
+}
+
+// jsonValue converts field values that don't marshal usefully on their own
+func jsonValue(value interface{}) interface{} {
+        switch v := value.(type) {
+        case binary:
+                return encodeBinary(v)
+        case error:
+                return v.Error()
+        case fmt.Stringer:
+                return v.String()
+        }
+        return value
+}
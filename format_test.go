@@ -0,0 +1,311 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "encoding/json"
+        "fmt"
+        "sort"
+        "strings"
+        "testing"
+)
+
+// csvEncoder is a custom encoder writing level,message,fields
+type csvEncoder struct{}
+
+func (csvEncoder) Encode(rec Record) ([]byte, error) {
+        keys := make([]string, 0, len(rec.Fields))
+        for k := range rec.Fields {
+                keys = append(keys, k)
+        }
+        sort.Strings(keys)
+        cols := []string{levelName(rec.Level), rec.Message}
+        for _, k := range keys {
+                cols = append(cols, fmt.Sprintf("%s:%v", k, rec.Fields[k]))
+        }
+        return []byte(strings.Join(cols, ",") + "\n"), nil
+}
+
+// prefixEncoder is a custom encoder adding a fixed prefix to text lines
+type prefixEncoder string
+
+func (p prefixEncoder) Encode(rec Record) ([]byte, error) {
+        return []byte(string(p) + rec.Message + "\n"), nil
+}
+
+func TestSetEncoder(t *testing.T) {
+        tests := []struct {
+                name  string
+                setup func()
+                want  string
+        }{
+                {
+                        name:  "custom encoder",
+                        setup: func() { SetEncoder(csvEncoder{}) },
+                        want:  "info,login,user:alice\n",
+                },
+                {
+                        name:  "later encoder replaces the first",
+                        setup: func() { SetEncoder(csvEncoder{}); SetEncoder(prefixEncoder("> ")) },
+                        want:  "> login\n",
+                },
+                {
+                        name: "JSON settings keep the custom encoder",
+                        setup: func() {
+                                SetEncoder(csvEncoder{})
+                                SetJSONFieldOrder("message")
+                                SetFieldKeys(FieldKeys{Message: "msg"})
+                        },
+                        want: "info,login,user:alice\n",
+                },
+                {
+                        name:  "registered format",
+                        setup: func() { SetFormat(RegisterEncoder(prefixEncoder("# "))) },
+                        want:  "# login\n",
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        path := tempLogPath(t, "app.log")
+                        if err := InitLogger(LevelInfo, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        tt.setup()
+                        WithField("user", "alice").Info("login")
+                        if got := out.String(); got != tt.want {
+                                t.Errorf("console %q, want %q", got, tt.want)
+                        }
+                        if got := readLog(t, path); got != tt.want {
+                                t.Errorf("log file %q, want %q", got, tt.want)
+                        }
+                })
+        }
+}
+
+func TestEncoderSlots(t *testing.T) {
+        builtin := len(builtinEncoders())
+        tests := []struct {
+                name     string
+                setup    func()
+                want     int
+                wantText bool // The SetEncoder slot holds the default encoder
+        }{
+                {"SetEncoder reuses its slot", func() {
+                        for i := 0; i < 5; i++ {
+                                SetEncoder(prefixEncoder(fmt.Sprint(i)))
+                        }
+                }, builtin, false},
+                {"RegisterEncoder adds formats", func() {
+                        RegisterEncoder(csvEncoder{})
+                        RegisterEncoder(csvEncoder{})
+                }, builtin + 2, true},
+                {"Reset drops registered encoders", func() {
+                        RegisterEncoder(csvEncoder{})
+                        SetEncoder(csvEncoder{})
+                        Reset()
+                }, builtin, true},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        tt.setup()
+                        outputsMu.Lock()
+                        n := len(encoders)
+                        custom := encoders[formatCustom]
+                        outputsMu.Unlock()
+                        if n != tt.want {
+                                t.Errorf("%d encoders, want %d", n, tt.want)
+                        }
+                        if (custom == (TextEncoder{})) != tt.wantText {
+                                t.Errorf("custom slot holds %T", custom)
+                        }
+                })
+        }
+}
+
+// jsonKeys returns the top-level keys of a JSON object in the order written
+func jsonKeys(t *testing.T, line string) []string {
+        t.Helper()
+        dec := json.NewDecoder(strings.NewReader(line))
+        if _, err := dec.Token(); err != nil {
+                t.Fatal(err)
+        }
+        var keys []string
+        for dec.More() {
+                key, err := dec.Token()
+                if err != nil {
+                        t.Fatal(err)
+                }
+                keys = append(keys, key.(string))
+                var value json.RawMessage
+                if err := dec.Decode(&value); err != nil {
+                        t.Fatal(err)
+                }
+        }
+        return keys
+}
+
+func TestJSONFieldOrder(t *testing.T) {
+        fields := Fields{"user": "alice", "b": 2, "a": 1}
+        tests := []struct {
+                name   string
+                order  []string
+                keys   FieldKeys
+                fields Fields
+                want   []string
+        }{
+                {
+                        name: "default",
+                        want: []string{"time", "level", "caller", "message", "a", "b", "user"},
+                },
+                {
+                        name:  "standard keys first",
+                        order: []string{"message", "level"},
+                        want:  []string{"message", "level", "time", "caller", "a", "b", "user"},
+                },
+                {
+                        name:  "field first",
+                        order: []string{"user"},
+                        want:  []string{"user", "time", "level", "caller", "message", "a", "b"},
+                },
+                {
+                        name:  "unknown key ignored",
+                        order: []string{"missing", "caller"},
+                        want:  []string{"caller", "time", "level", "message", "a", "b", "user"},
+                },
+                {
+                        name:  "renamed key",
+                        order: []string{"msg"},
+                        keys:  FieldKeys{Message: "msg"},
+                        want:  []string{"msg", "time", "level", "caller", "a", "b", "user"},
+                },
+                {
+                        name:   "field repeating a standard key",
+                        fields: Fields{"message": "shadow", "a": 1},
+                        want:   []string{"time", "level", "caller", "message", "a"},
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetFormat(FormatJSON)
+                        SetFieldKeys(tt.keys)
+                        SetJSONFieldOrder(tt.order...)
+                        recordFields := fields
+                        if tt.fields != nil {
+                                recordFields = tt.fields
+                        }
+                        for i := 0; i < 3; i++ {
+                                WithFields(recordFields).Info("login")
+                        }
+                        lines := out.Lines()
+                        if len(lines) != 3 {
+                                t.Fatalf("got %d lines, want 3", len(lines))
+                        }
+                        for _, line := range lines {
+                                if got := jsonKeys(t, line); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+                                        t.Errorf("keys %v, want %v", got, tt.want)
+                                }
+                        }
+                })
+        }
+}
+
+func TestTimestampForLevel(t *testing.T) {
+        const stamp = "2023/03/08 10:00:00 "
+        tests := []struct {
+                name  string
+                debug bool
+                log   func()
+                tag   string
+                want  bool // The line carries the timestamp
+        }{
+                {name: "debug without timestamp", debug: true, log: func() { Debug("chatter") }, tag: "[DEBUG] "},
+                {name: "info without timestamp", log: func() { Info("chatter") }, tag: "[INFO] "},
+                {name: "warning keeps it", log: func() { Warning("slow") }, tag: "[WARN] ", want: true},
+                {name: "error keeps it", log: func() { Error("failed") }, tag: "[ERROR] ", want: true},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        if tt.debug {
+                                requireDebug(t)
+                        }
+                        out := captureOutput(t)
+                        useFakeClock(t)
+                        path := tempLogPath(t, "app.log")
+                        if err := InitLogger(LevelDebug, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        structured := &syncBuffer{}
+                        AddOutput(structured, FormatJSON)
+                        SetTimestampForLevel(LevelDebug, false)
+                        SetTimestampForLevel(LevelInfo, false)
+                        tt.log()
+
+                        for name, got := range map[string]string{"console": out.String(), "log file": readLog(t, path)} {
+                                if !strings.HasPrefix(got, tt.tag) {
+                                        t.Fatalf("%s line %q doesn't start with %s", name, got, tt.tag)
+                                }
+                                if stamped := strings.HasPrefix(got[len(tt.tag):], stamp); stamped != tt.want {
+                                        t.Errorf("%s line %q: timestamp %v, want %v", name, got, stamped, tt.want)
+                                }
+                        }
+                        // Structured output always has the time
+                        for _, record := range structured.Records(t) {
+                                if record["time"] != "2023-03-08T10:00:00Z" {
+                                        t.Errorf("JSON time %v", record["time"])
+                                }
+                        }
+                })
+        }
+}
+
+func TestSetFieldKeys(t *testing.T) {
+        tests := []struct {
+                name   string
+                format int
+                keys   FieldKeys
+                want   string // Logfmt prefix, or the JSON keys joined by spaces
+        }{
+                {
+                        name:   "JSON renamed",
+                        format: FormatJSON,
+                        keys:   FieldKeys{Time: "ts", Level: "severity", Caller: "src", Message: "msg"},
+                        want:   "ts severity src msg user",
+                },
+                {
+                        name:   "JSON partly renamed",
+                        format: FormatJSON,
+                        keys:   FieldKeys{Message: "msg"},
+                        want:   "time level caller msg user",
+                },
+                {name: "JSON defaults", format: FormatJSON, want: "time level caller message user"},
+                {
+                        name:   "logfmt renamed",
+                        format: FormatLogfmt,
+                        keys:   FieldKeys{Time: "ts", Level: "lvl", Caller: "src", Message: "message"},
+                        want:   "ts=2023-03-08T10:00:00Z lvl=info src=",
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        useFakeClock(t)
+                        SetFormat(tt.format)
+                        SetFieldKeys(tt.keys)
+                        WithField("user", "alice").Info("started")
+
+                        line := strings.TrimSuffix(out.String(), "\n")
+                        if tt.format == FormatLogfmt {
+                                if !strings.HasPrefix(line, tt.want) || !strings.Contains(line, " message=started user=alice") {
+                                        t.Errorf("got %s, want the renamed keys %s", line, tt.want)
+                                }
+                                return
+                        }
+                        if got := strings.Join(jsonKeys(t, line), " "); got != tt.want {
+                                t.Errorf("keys %s, want %s", got, tt.want)
+                        }
+                })
+        }
+}
@@ -0,0 +1,173 @@
+// File: gelf.go
+// Description:
+// Graylog Extended Log Format. GELFEncoder renders records as GELF 1.1 JSON
+// and AddGELFOutput sends them to a Graylog input over UDP, chunked when a
+// message exceeds the datagram size, or over TCP, null byte delimited.
+
+package logger
+
+import (
+        "crypto/rand"
+        "fmt"
+        "net"
+        "os"
+        "regexp"
+        "sort"
+        "strings"
+        "time"
+)
+
+// gelfChunkSize is the maximum size of a UDP datagram sent to Graylog,
+// chosen to fit a typical WAN MTU
+const gelfChunkSize = 1420
+
+// gelfMaxChunks is the maximum number of chunks of one GELF message
+const gelfMaxChunks = 128
+
+// gelfInvalidKey matches characters not allowed in GELF field names
+var gelfInvalidKey = regexp.MustCompile(`[^\w.\-]`)
+
+// GELFEncoder renders records as GELF 1.1 JSON objects: version, host,
+// short_message, timestamp, syslog level, and the caller and fields as
+// additional "_" prefixed fields
+type GELFEncoder struct{}
+
+// gelfLevel maps a level to its syslog severity
+func gelfLevel(level int) int {
+        switch level {
+        case LevelDebug:
+                return 7
+        case LevelWarning:
+                return 4
+        case LevelError:
+                return 3
+        case LevelFatal:
+                return 2
+        default:
+                return 6
+        }
+}
+
+// gelfHost returns the host name reported in GELF messages
+func gelfHost() string {
+        hostnameMu.RLock()
+        host := hostname
+        hostnameMu.RUnlock()
+        if host == "" {
+                host, _ = os.Hostname()
+        }
+        return host
+}
+
+// Encode implements Encoder
+func (GELFEncoder) Encode(rec Record) ([]byte, error) {
+        var b strings.Builder
+        b.WriteString("{")
+        writeJSONPair(&b, "version", "1.1", true)
+        writeJSONPair(&b, "host", gelfHost(), false)
+        short, full := rec.Message, ""
+        if i := strings.IndexByte(short, '\n'); i >= 0 {
+                short, full = short[:i], rec.Message
+        }
+        writeJSONPair(&b, "short_message", short, false)
+        if full != "" {
+                writeJSONPair(&b, "full_message", full, false)
+        }
+        writeJSONPair(&b, "timestamp", float64(rec.Time.UnixNano())/float64(time.Second), false)
+        writeJSONPair(&b, "level", gelfLevel(rec.Level), false)
+        if rec.Caller != "" {
+                writeJSONPair(&b, "_caller", rec.Caller, false)
+        }
+
+        keys := make([]string, 0, len(rec.Fields))
+        for k := range rec.Fields {
+                keys = append(keys, k)
+        }
+        sort.Strings(keys)
+        for _, k := range keys {
+                name := "_" + gelfInvalidKey.ReplaceAllString(k, "_")
+                if name == "_id" || name == "_caller" {
+                        // Reserved by GELF or taken by the caller
+                        name = "_" + name
+                }
+                writeJSONPair(&b, name, jsonValue(fieldValue(k, rec.Fields[k])), false)
+        }
+        b.WriteString("}\n")
+        return []byte(b.String()), nil
+}
+
+// gelfWriter frames GELF messages for the transport
+type gelfWriter struct {
+        out *netOutput
+        udp bool
+}
+
+// AddGELFOutput adds an output sending records in GELF to a Graylog input.
+// addr is "host:port" or "udp://host:port" for UDP, "tcp://host:port" for
+// TCP. The connection is re-established after failures.
+func AddGELFOutput(addr string) error {
+        network := "udp"
+        if i := strings.Index(addr, "://"); i >= 0 {
+                network, addr = addr[:i], addr[i+3:]
+        }
+        if network != "udp" && network != "tcp" {
+                return fmt.Errorf("unsupported GELF transport: %s", network)
+        }
+
+        out, err := newNetOutput(func() (net.Conn, error) {
+                return net.DialTimeout(network, addr, NetworkTimeout())
+        })
+        if err != nil {
+                return err
+        }
+        AddOutput(&gelfWriter{out: out, udp: network == "udp"}, FormatGELF)
+        return nil
+}
+
+// Write sends one encoded message
+func (w *gelfWriter) Write(p []byte) (int, error) {
+        msg := []byte(strings.TrimSuffix(string(p), "\n"))
+        if !w.udp {
+                if _, err := w.out.Write(append(msg, 0)); err != nil {
+                        return 0, err
+                }
+                return len(p), nil
+        }
+
+        if len(msg) <= gelfChunkSize {
+                if _, err := w.out.Write(msg); err != nil {
+                        return 0, err
+                }
+                return len(p), nil
+        }
+
+        // Chunked message: magic bytes, message id, sequence number and count
+        const header = 12
+        size := gelfChunkSize - header
+        count := (len(msg) + size - 1) / size
+        if count > gelfMaxChunks {
+                return 0, fmt.Errorf("GELF message too large: %d bytes", len(msg))
+        }
+        var id [8]byte
+        rand.Read(id[:])
+        for i := 0; i < count; i++ {
+                end := (i + 1) * size
+                if end > len(msg) {
+                        end = len(msg)
+                }
+                chunk := make([]byte, 0, header+end-i*size)
+                chunk = append(chunk, 0x1e, 0x0f)
+                chunk = append(chunk, id[:]...)
+                chunk = append(chunk, byte(i), byte(count))
+                chunk = append(chunk, msg[i*size:end]...)
+                if _, err := w.out.Write(chunk); err != nil {
+                        return 0, err
+                }
+        }
+        return len(p), nil
+}
+
+// Close closes the connection
+func (w *gelfWriter) Close() error {
+        return w.out.Close()
+}
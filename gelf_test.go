@@ -0,0 +1,204 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "bufio"
+        "bytes"
+        "encoding/json"
+        "net"
+        "strings"
+        "testing"
+        "time"
+)
+
+func TestGELFEncoder(t *testing.T) {
+        tests := []struct {
+                name   string
+                log    func()
+                debug  bool
+                want   map[string]interface{}
+                absent []string
+        }{
+                {
+                        name:  "debug",
+                        debug: true,
+                        log:   func() { Debug("m") },
+                        want:  map[string]interface{}{"level": 7.0},
+                },
+                {name: "info", log: func() { Info("m") }, want: map[string]interface{}{"level": 6.0}},
+                {name: "warning", log: func() { Warning("m") }, want: map[string]interface{}{"level": 4.0}},
+                {name: "error", log: func() { Error("m") }, want: map[string]interface{}{"level": 3.0}},
+                {name: "fatal", log: func() { Fatal("m") }, want: map[string]interface{}{"level": 2.0}},
+                {
+                        name:   "fields",
+                        log:    func() { WithFields(Fields{"user": "alice", "id": 7, "bad key!": true}).Info("m") },
+                        want:   map[string]interface{}{"_user": "alice", "__id": 7.0, "_bad_key_": true},
+                        absent: []string{"_id", "user"},
+                },
+                {
+                        name: "multi-line message",
+                        log:  func() { Info("first line\nsecond line") },
+                        want: map[string]interface{}{"short_message": "first line", "full_message": "first line\nsecond line"},
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        if tt.debug {
+                                requireDebug(t)
+                        }
+                        captureOutput(t)
+                        stubExit(t)
+                        useFakeClock(t)
+                        SetLevel(LevelDebug)
+                        SetHostname("web-1")
+                        buf := &syncBuffer{}
+                        AddOutput(buf, FormatGELF)
+                        tt.log()
+
+                        records := buf.Records(t)
+                        if len(records) != 1 {
+                                t.Fatalf("got %d records, want 1", len(records))
+                        }
+                        record := records[0]
+                        want := map[string]interface{}{
+                                "version":   "1.1",
+                                "host":      "web-1",
+                                "timestamp": float64(1678269600),
+                        }
+                        for k, v := range tt.want {
+                                want[k] = v
+                        }
+                        if _, ok := want["short_message"]; !ok {
+                                want["short_message"] = "m"
+                        }
+                        for k, v := range want {
+                                if record[k] != v {
+                                        t.Errorf("%s = %v, want %v", k, record[k], v)
+                                }
+                        }
+                        if caller, _ := record["_caller"].(string); !strings.HasPrefix(caller, "gelf_test.go:") {
+                                t.Errorf("_caller %q", caller)
+                        }
+                        for _, k := range tt.absent {
+                                if v, ok := record[k]; ok {
+                                        t.Errorf("%s = %v present", k, v)
+                                }
+                        }
+                })
+        }
+}
+
+// readGELFMessage reads a GELF message from a UDP connection, joining the
+// chunks of a chunked message
+func readGELFMessage(t *testing.T, conn net.PacketConn) (msg []byte, chunks int) {
+        t.Helper()
+        conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+        var parts [][]byte
+        buf := make([]byte, 65536)
+        for {
+                n, _, err := conn.ReadFrom(buf)
+                if err != nil {
+                        t.Fatal(err)
+                }
+                if n > gelfChunkSize {
+                        t.Fatalf("datagram of %d bytes", n)
+                }
+                p := append([]byte(nil), buf[:n]...)
+                if !bytes.HasPrefix(p, []byte{0x1e, 0x0f}) {
+                        return p, 0
+                }
+                seq, count := int(p[10]), int(p[11])
+                if parts == nil {
+                        parts = make([][]byte, count)
+                }
+                parts[seq] = p[12:]
+                chunks++
+                if chunks == count {
+                        return bytes.Join(parts, nil), chunks
+                }
+        }
+}
+
+func TestGELFOutput(t *testing.T) {
+        tests := []struct {
+                name       string
+                size       int
+                wantChunks int
+        }{
+                {name: "single datagram", size: 100},
+                {name: "chunked", size: 5000, wantChunks: 4},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        defer conn.Close()
+                        if err := AddGELFOutput(conn.LocalAddr().String()); err != nil {
+                                t.Fatal(err)
+                        }
+                        message := strings.Repeat("x", tt.size)
+                        Info(message)
+
+                        msg, chunks := readGELFMessage(t, conn)
+                        if chunks != tt.wantChunks {
+                                t.Errorf("%d chunks, want %d", chunks, tt.wantChunks)
+                        }
+                        var record map[string]interface{}
+                        if err := json.Unmarshal(msg, &record); err != nil {
+                                t.Fatalf("invalid GELF JSON %q: %v", msg, err)
+                        }
+                        if record["short_message"] != message || record["level"] != 6.0 {
+                                t.Errorf("record %v", record)
+                        }
+                })
+        }
+}
+
+func TestGELFOutputTCP(t *testing.T) {
+        captureOutput(t)
+        ln, err := net.Listen("tcp", "127.0.0.1:0")
+        if err != nil {
+                t.Fatal(err)
+        }
+        defer ln.Close()
+        if err := AddGELFOutput("tcp://" + ln.Addr().String()); err != nil {
+                t.Fatal(err)
+        }
+        Info("first")
+        Error("second")
+
+        conn, err := ln.Accept()
+        if err != nil {
+                t.Fatal(err)
+        }
+        defer conn.Close()
+        conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+        r := bufio.NewReader(conn)
+        for _, want := range []struct {
+                message string
+                level   float64
+        }{{"first", 6}, {"second", 3}} {
+                msg, err := r.ReadBytes(0)
+                if err != nil {
+                        t.Fatal(err)
+                }
+                var record map[string]interface{}
+                if err := json.Unmarshal(msg[:len(msg)-1], &record); err != nil {
+                        t.Fatalf("invalid GELF JSON %q: %v", msg, err)
+                }
+                if record["short_message"] != want.message || record["level"] != want.level {
+                        t.Errorf("record %v, want %s at level %v", record, want.message, want.level)
+                }
+        }
+}
+
+func TestGELFUnsupportedTransport(t *testing.T) {
+        captureOutput(t)
+        if err := AddGELFOutput("http://localhost:12201"); err == nil {
+                t.Error("HTTP transport accepted")
+        }
+}
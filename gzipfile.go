@@ -0,0 +1,94 @@
+// File: gzipfile.go
+// Description:
+// Compression of the active log file. With SetCompressActive the log file is
+// written through a gzip stream, flushed shortly after each write so the
+// file can be followed with zcat, and closed cleanly on rotation and close
+// so every file is a valid gzip archive. Appending to an existing file adds
+// a new gzip member, which gzip readers handle transparently.
+
+package logger
+
+import (
+        "compress/gzip"
+        "io"
+        "os"
+        "time"
+)
+
+// gzipFlushDelay is how long written data may stay in the gzip buffer
+const gzipFlushDelay = time.Second
+
+var (
+        // Write the log file through gzip (guarded by outputsMu)
+        compressActive bool
+
+        // gzip stream over logFile when compressing (guarded by outputsMu)
+        logGzip *gzip.Writer
+
+        // A flush of logGzip is scheduled (guarded by outputsMu)
+        gzipFlushPending bool
+)
+
+// SetCompressActive writes the log file gzip compressed. Like SetLazyFile
+// it applies to log files opened afterwards: the next InitLogger call and
+// files created by rotation. Rotated compressed files are not compacted.
+func SetCompressActive(enabled bool) {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        compressActive = enabled
+}
+
+// attachLogFile makes f (possibly nil) the log file, wrapping it in a gzip
+// stream if compression is enabled; outputsMu must be held
+func attachLogFile(f *os.File) {
+        logFile = f
+        logGzip = nil
+        if f != nil && compressActive {
+                logGzip = gzip.NewWriter(f)
+        }
+        resetRotationState(f, now())
+}
+
+// detachLogFile writes the buffered records, finishes the gzip stream, if
+// any, and returns the log file (possibly nil) without closing it. Pending
+// flushes and the count of unsynced records start over with the next file.
+// outputsMu must be held.
+func detachLogFile() *os.File {
+        f := logFile
+        flushFileBuffer()
+        if logGzip != nil {
+                logGzip.Close()
+                logGzip = nil
+        }
+        gzipFlushPending = false
+        syncPending = false
+        unsyncedRecords = 0
+        logFile = nil
+        return f
+}
+
+// logFileWriter returns the writer records for the log file go through;
+// outputsMu must be held and logFile set
+func logFileWriter() io.Writer {
+        if logGzip != nil {
+                return logGzip
+        }
+        return logFile
+}
+
+// scheduleGzipFlush flushes the gzip stream shortly after a write;
+// outputsMu must be held
+func scheduleGzipFlush() {
+        if logGzip == nil || gzipFlushPending {
+                return
+        }
+        gzipFlushPending = true
+        afterFunc(gzipFlushDelay, func() {
+                outputsMu.Lock()
+                defer outputsMu.Unlock()
+                gzipFlushPending = false
+                if logGzip != nil {
+                        logGzip.Flush()
+                }
+        })
+}
@@ -0,0 +1,122 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "bytes"
+        "compress/gzip"
+        "io"
+        "os"
+        "path/filepath"
+        "strings"
+        "testing"
+        "time"
+)
+
+// gunzip returns the decompressed content of a gzip file. Unless complete
+// is set, a stream that is still open (no trailer yet) is accepted.
+func gunzip(t *testing.T, path string, complete bool) string {
+        t.Helper()
+        data, err := os.ReadFile(path)
+        if err != nil {
+                t.Fatal(err)
+        }
+        r, err := gzip.NewReader(bytes.NewReader(data))
+        if err != nil {
+                t.Fatalf("%s is not gzip: %v", path, err)
+        }
+        content, err := io.ReadAll(r)
+        if err != nil && (complete || err != io.ErrUnexpectedEOF) {
+                t.Fatalf("reading %s: %v", path, err)
+        }
+        return string(content)
+}
+
+func TestCompressActive(t *testing.T) {
+        tests := []struct {
+                name  string
+                run   func(t *testing.T, path string, clock *fakeClock)
+                check func(t *testing.T, path string)
+        }{
+                {
+                        name: "closed file",
+                        run: func(t *testing.T, path string, clock *fakeClock) {
+                                Info("first record")
+                                Info("second record")
+                                CloseLogger()
+                        },
+                        check: func(t *testing.T, path string) {
+                                got := gunzip(t, path, true)
+                                if !strings.Contains(got, "first record") || !strings.Contains(got, "second record") {
+                                        t.Errorf("content %q", got)
+                                }
+                        },
+                },
+                {
+                        name: "flushed while open",
+                        run: func(t *testing.T, path string, clock *fakeClock) {
+                                Info("first record")
+                                if got := gunzip(t, path, false); strings.Contains(got, "first record") {
+                                        t.Errorf("record readable before the flush delay: %q", got)
+                                }
+                                clock.Advance(gzipFlushDelay)
+                        },
+                        check: func(t *testing.T, path string) {
+                                if got := gunzip(t, path, false); !strings.Contains(got, "first record") {
+                                        t.Errorf("content %q after the flush delay", got)
+                                }
+                        },
+                },
+                {
+                        name: "rotation",
+                        run: func(t *testing.T, path string, clock *fakeClock) {
+                                Info("before rotation")
+                                if err := rotateLogFile(false); err != nil {
+                                        t.Fatal(err)
+                                }
+                                Info("after rotation")
+                                CloseLogger()
+                        },
+                        check: func(t *testing.T, path string) {
+                                archive := filepath.Join(filepath.Dir(path), defaultRotateName("app", ".log", time.Date(2023, 3, 8, 10, 0, 0, 0, time.UTC)))
+                                if got := gunzip(t, archive, true); !strings.Contains(got, "before rotation") || strings.Contains(got, "after rotation") {
+                                        t.Errorf("archive content %q", got)
+                                }
+                                if got := gunzip(t, path, true); !strings.Contains(got, "after rotation") || strings.Contains(got, "before rotation") {
+                                        t.Errorf("active file content %q", got)
+                                }
+                        },
+                },
+                {
+                        name: "appending adds a member",
+                        run: func(t *testing.T, path string, clock *fakeClock) {
+                                Info("first run")
+                                CloseLogger()
+                                if err := InitLogger(LevelInfo, true, path); err != nil {
+                                        t.Fatal(err)
+                                }
+                                Info("second run")
+                                CloseLogger()
+                        },
+                        check: func(t *testing.T, path string) {
+                                got := gunzip(t, path, true)
+                                if !strings.Contains(got, "first run") || !strings.Contains(got, "second run") {
+                                        t.Errorf("content %q", got)
+                                }
+                        },
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        clock := useFakeClock(t)
+                        path := tempLogPath(t, "app.log")
+                        SetCompressActive(true)
+                        if err := InitLogger(LevelInfo, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        tt.run(t, path, clock)
+                        tt.check(t, path)
+                })
+        }
+}
@@ -0,0 +1,249 @@
+// File: http.go
+// Description:
+// HTTP access logging. LogHTTPRequest writes one structured record per
+// request with a level chosen from the status class, so access logs look the
+// same across services; Middleware does it for every request of a handler.
+// Sensitive query parameters can be redacted.
+
+package logger
+
+import (
+        "context"
+        "net/http"
+        "net/url"
+        "sort"
+        "strings"
+        "sync"
+        "time"
+)
+
+var (
+        // Query parameters whose values are redacted (lower-cased, "*" for all)
+        redactedParams   = map[string]bool{}
+        redactedParamsMu sync.RWMutex
+
+        // Header carrying the request id
+        requestIDHeader   = RequestIDHeader
+        requestIDHeaderMu sync.RWMutex
+)
+
+// SetRedactQueryParams sets the query parameters whose values are replaced
+// with "***" in HTTP access records; matching is case-insensitive and "*"
+// redacts every parameter. Fields marked with RedactFields are redacted as
+// well. Calling it without arguments clears the list.
+func SetRedactQueryParams(params ...string) {
+        redacted := make(map[string]bool, len(params))
+        for _, p := range params {
+                redacted[strings.ToLower(p)] = true
+        }
+        redactedParamsMu.Lock()
+        defer redactedParamsMu.Unlock()
+        redactedParams = redacted
+}
+
+// LogHTTPRequest logs a handled request with the method, path, query,
+// status, duration, remote address and user agent as fields. Server errors
+// (5xx) are logged at error, client errors (4xx) at warning and everything
+// else at info.
+func LogHTTPRequest(r *http.Request, status int, dur time.Duration) {
+        logWithCallerInfo(httpStatusLevel(status), httpRequestFields(r, status, dur), "%s %s %d", r.Method, r.URL.Path, status)
+}
+
+// httpStatusLevel returns the level of an access record by status class
+func httpStatusLevel(status int) int {
+        switch {
+        case status >= 500:
+                return LevelError
+        case status >= 400:
+                return LevelWarning
+        }
+        return LevelInfo
+}
+
+// RequestIDKey is the field name of the request id added by Middleware
+const RequestIDKey = "request_id"
+
+// RequestIDHeader is the default header carrying the request id
+const RequestIDHeader = "X-Request-ID"
+
+// SetRequestIDHeader sets the header Middleware and FromRequest take the
+// request id from, e.g. "X-Correlation-ID". An empty name restores
+// X-Request-ID.
+func SetRequestIDHeader(name string) {
+        if name == "" {
+                name = RequestIDHeader
+        }
+        requestIDHeaderMu.Lock()
+        defer requestIDHeaderMu.Unlock()
+        requestIDHeader = name
+}
+
+// getRequestIDHeader returns the header carrying the request id
+func getRequestIDHeader() string {
+        requestIDHeaderMu.RLock()
+        defer requestIDHeaderMu.RUnlock()
+        return requestIDHeader
+}
+
+// requestID returns the id of a request: the one stored by Middleware, else
+// the request id header, else a new one
+func requestID(r *http.Request) string {
+        if id := RequestIDFromContext(r.Context()); id != "" {
+                return id
+        }
+        if id := r.Header.Get(getRequestIDHeader()); id != "" {
+                return id
+        }
+        return newULID()
+}
+
+// FromRequest returns a logger whose records carry the request id as a
+// "request_id" field. Within Middleware it is the id Middleware logs;
+// otherwise it is taken from the request id header or generated.
+func FromRequest(r *http.Request) *Logger {
+        return WithField(RequestIDKey, requestID(r))
+}
+
+// requestIDContextKey is the context key of the request id
+type requestIDContextKey struct{}
+
+// RequestIDFromContext returns the request id stored by Middleware, or an
+// empty string
+func RequestIDFromContext(ctx context.Context) string {
+        id, _ := ctx.Value(requestIDContextKey{}).(string)
+        return id
+}
+
+// Middleware wraps an http.Handler to log every request as LogHTTPRequest
+// does, with a "request_id" field. The id is taken from the X-Request-ID
+// header (see SetRequestIDHeader) or generated, echoed in the response
+// header and stored in the request context (see RequestIDFromContext and
+// FromRequest) for correlation.
+func Middleware(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                start := now()
+
+                id := requestID(r)
+                w.Header().Set(getRequestIDHeader(), id)
+                r = r.WithContext(context.WithValue(r.Context(), requestIDContextKey{}, id))
+
+                rw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+                next.ServeHTTP(rw, r)
+
+                fields := httpRequestFields(r, rw.status, since(start))
+                fields[RequestIDKey] = id
+                logWithCallerInfo(httpStatusLevel(rw.status), fields, "%s %s %d", r.Method, r.URL.Path, rw.status)
+        })
+}
+
+// RecoverMiddleware wraps an http.Handler to recover panics in it. A panic
+// is logged as Recover does, at error level with the panic value and stack,
+// along with the request fields (method, path, ...) and its request id, if
+// any; the client gets a 500 response unless the handler already started
+// one. The panic action (SetPanicAction) applies after logging.
+// http.ErrAbortHandler is passed through untouched.
+func RecoverMiddleware(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                start := now()
+                rw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+                defer func() {
+                        p := recover()
+                        if p == nil {
+                                return
+                        }
+                        if p == http.ErrAbortHandler {
+                                panic(p)
+                        }
+                        if !rw.wroteHeader {
+                                http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+                        }
+                        fields := httpRequestFields(r, http.StatusInternalServerError, since(start))
+                        if id := RequestIDFromContext(r.Context()); id != "" {
+                                fields[RequestIDKey] = id
+                        }
+                        recovered(p, fields, panicSite())
+                }()
+                next.ServeHTTP(rw, r)
+        })
+}
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+        http.ResponseWriter
+        status      int
+        wroteHeader bool
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *statusRecorder) WriteHeader(status int) {
+        if !w.wroteHeader {
+                w.status = status
+                w.wroteHeader = true
+        }
+        w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (w *statusRecorder) Write(p []byte) (int, error) {
+        w.wroteHeader = true
+        return w.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher when the underlying writer does
+func (w *statusRecorder) Flush() {
+        if f, ok := w.ResponseWriter.(http.Flusher); ok {
+                w.wroteHeader = true
+                f.Flush()
+        }
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+        return w.ResponseWriter
+}
+
+// httpRequestFields returns the fields of an access record
+func httpRequestFields(r *http.Request, status int, dur time.Duration) Fields {
+        fields := Fields{
+                "method":      r.Method,
+                "path":        r.URL.Path,
+                "status":      status,
+                DurationKey:   float64(dur) / float64(time.Millisecond),
+                "remote_addr": r.RemoteAddr,
+                "user_agent":  r.UserAgent(),
+        }
+        if r.URL.RawQuery != "" {
+                fields["query"] = redactQuery(r.URL.Query())
+        }
+        return fields
+}
+
+// redactQuery encodes query, sorted by parameter, with the values of
+// redacted parameters shown as "***"
+func redactQuery(query url.Values) string {
+        names := make([]string, 0, len(query))
+        for name := range query {
+                names = append(names, name)
+        }
+        sort.Strings(names)
+
+        redactedParamsMu.RLock()
+        defer redactedParamsMu.RUnlock()
+        var b strings.Builder
+        for _, name := range names {
+                hide := redactedParams["*"] || redactedParams[strings.ToLower(name)] || isRedacted(name)
+                for _, value := range query[name] {
+                        if b.Len() > 0 {
+                                b.WriteByte('&')
+                        }
+                        b.WriteString(url.QueryEscape(name))
+                        b.WriteByte('=')
+                        if hide {
+                                b.WriteString(redactedValue)
+                        } else {
+                                b.WriteString(url.QueryEscape(value))
+                        }
+                }
+        }
+        return b.String()
+}
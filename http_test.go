@@ -0,0 +1,307 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "fmt"
+        "net/http"
+        "net/http/httptest"
+        "runtime"
+        "strings"
+        "testing"
+        "time"
+)
+
+func TestLogHTTPRequest(t *testing.T) {
+        tests := []struct {
+                name      string
+                target    string
+                status    int
+                redact    []string
+                wantLevel string
+                wantQuery interface{} // nil for no query field
+        }{
+                {name: "success", target: "/users/42", status: 200, wantLevel: "info"},
+                {name: "redirect", target: "/old", status: 301, wantLevel: "info"},
+                {name: "client error", target: "/missing", status: 404, wantLevel: "warning"},
+                {name: "server error", target: "/users", status: 503, wantLevel: "error"},
+                {
+                        name:      "query kept",
+                        target:    "/search?q=go&page=2",
+                        status:    200,
+                        wantLevel: "info",
+                        wantQuery: "page=2&q=go",
+                },
+                {
+                        name:      "query redacted",
+                        target:    "/login?user=alice&Token=s3cret",
+                        status:    200,
+                        redact:    []string{"token"},
+                        wantLevel: "info",
+                        wantQuery: "Token=***&user=alice",
+                },
+                {
+                        name:      "every parameter redacted",
+                        target:    "/login?user=alice&token=s3cret",
+                        status:    200,
+                        redact:    []string{"*"},
+                        wantLevel: "info",
+                        wantQuery: "token=***&user=***",
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetFormat(FormatJSON)
+                        SetRedactQueryParams(tt.redact...)
+                        r := httptest.NewRequest("GET", tt.target, nil)
+                        r.RemoteAddr = "192.0.2.1:5123"
+                        r.Header.Set("User-Agent", "test-agent/1.0")
+                        LogHTTPRequest(r, tt.status, 1500*time.Microsecond)
+
+                        records := out.Records(t)
+                        if len(records) != 1 {
+                                t.Fatalf("got %d records, want 1", len(records))
+                        }
+                        record := records[0]
+                        want := map[string]interface{}{
+                                "level":       tt.wantLevel,
+                                "method":      "GET",
+                                "path":        r.URL.Path,
+                                "status":      float64(tt.status),
+                                DurationKey:   1.5,
+                                "remote_addr": "192.0.2.1:5123",
+                                "user_agent":  "test-agent/1.0",
+                                "query":       tt.wantQuery,
+                        }
+                        for key, value := range want {
+                                if got := record[key]; got != value {
+                                        t.Errorf("%s = %v, want %v", key, got, value)
+                                }
+                        }
+                })
+        }
+}
+
+func TestMiddleware(t *testing.T) {
+        tests := []struct {
+                name      string
+                handler   func(w http.ResponseWriter)
+                headerID  string // Incoming request id, empty to generate one
+                status    int
+                wantLevel string
+        }{
+                {
+                        name:      "implicit OK",
+                        handler:   func(w http.ResponseWriter) { w.Write([]byte("hello")) },
+                        status:    200,
+                        wantLevel: "info",
+                },
+                {
+                        name:      "not found",
+                        handler:   func(w http.ResponseWriter) { http.NotFound(w, nil) },
+                        status:    404,
+                        wantLevel: "warning",
+                },
+                {
+                        name:      "server error with request id",
+                        handler:   func(w http.ResponseWriter) { w.WriteHeader(500) },
+                        headerID:  "req-123",
+                        status:    500,
+                        wantLevel: "error",
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        clock := useFakeClock(t)
+                        SetFormat(FormatJSON)
+                        var contextID string
+                        handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                                contextID = RequestIDFromContext(r.Context())
+                                FromRequest(r).Info("handling")
+                                clock.Advance(250 * time.Millisecond)
+                                tt.handler(w)
+                        }))
+                        r := httptest.NewRequest("GET", "/things", nil)
+                        if tt.headerID != "" {
+                                r.Header.Set(RequestIDHeader, tt.headerID)
+                        }
+                        w := httptest.NewRecorder()
+                        handler.ServeHTTP(w, r)
+
+                        if w.Code != tt.status {
+                                t.Errorf("response status %d, want %d", w.Code, tt.status)
+                        }
+                        id := w.Header().Get(RequestIDHeader)
+                        if id == "" || (tt.headerID != "" && id != tt.headerID) {
+                                t.Errorf("response request id %q, want %q", id, tt.headerID)
+                        }
+                        if contextID != id {
+                                t.Errorf("context request id %q, want %q", contextID, id)
+                        }
+                        records := out.Records(t)
+                        if len(records) != 2 {
+                                t.Fatalf("got %d records, want 2", len(records))
+                        }
+                        if got := records[0][RequestIDKey]; got != id {
+                                t.Errorf("handler record request id %v, want %s", got, id)
+                        }
+                        access := records[1]
+                        want := map[string]interface{}{
+                                "level":      tt.wantLevel,
+                                "status":     float64(tt.status),
+                                "method":     "GET",
+                                "path":       "/things",
+                                RequestIDKey: id,
+                                DurationKey:  250.0,
+                        }
+                        for key, value := range want {
+                                if got := access[key]; got != value {
+                                        t.Errorf("access record %s = %v, want %v", key, got, value)
+                                }
+                        }
+                })
+        }
+}
+
+func TestFromRequest(t *testing.T) {
+        tests := []struct {
+                name    string
+                header  string // Name passed to SetRequestIDHeader, "" for the default
+                set     string // Header the request carries the id in
+                id      string // Id in the request, "" for none
+                wantNew bool   // Whether a new id is expected
+        }{
+                {name: "default header", set: RequestIDHeader, id: "req-1"},
+                {name: "custom header", header: "X-Correlation-ID", set: "X-Correlation-ID", id: "corr-7"},
+                {name: "other header ignored", header: "X-Correlation-ID", set: RequestIDHeader, id: "req-1", wantNew: true},
+                {name: "missing header", wantNew: true},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetFormat(FormatJSON)
+                        SetRequestIDHeader(tt.header)
+                        r := httptest.NewRequest("GET", "/things", nil)
+                        if tt.id != "" {
+                                r.Header.Set(tt.set, tt.id)
+                        }
+                        FromRequest(r).Info("first")
+                        FromRequest(r).Info("second")
+
+                        records := out.Records(t)
+                        if len(records) != 2 {
+                                t.Fatalf("got %d records, want 2", len(records))
+                        }
+                        first, _ := records[0][RequestIDKey].(string)
+                        second, _ := records[1][RequestIDKey].(string)
+                        if !tt.wantNew {
+                                if first != tt.id || second != tt.id {
+                                        t.Errorf("request ids %q, %q, want %q", first, second, tt.id)
+                                }
+                                return
+                        }
+                        if len(first) != 26 || len(second) != 26 {
+                                t.Errorf("generated request ids %q, %q, want ULIDs", first, second)
+                        }
+                        if first == second {
+                                t.Errorf("generated request id %q reused", first)
+                        }
+                })
+        }
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+        tests := []struct {
+                name        string
+                write       int // Status the handler writes before panicking, 0 for none
+                panicValue  interface{}
+                withID      bool // Whether Middleware wraps the handler too
+                wantStatus  int
+                wantRecord  bool
+                wantRepanic bool
+        }{
+                {name: "panic", panicValue: "boom", wantStatus: 500, wantRecord: true},
+                {name: "error value", panicValue: errTest, wantStatus: 500, wantRecord: true},
+                {name: "response started", write: http.StatusAccepted, panicValue: "boom", wantStatus: http.StatusAccepted, wantRecord: true},
+                {name: "request id", panicValue: "boom", withID: true, wantStatus: 500, wantRecord: true},
+                {name: "abort handler", panicValue: http.ErrAbortHandler, wantRepanic: true},
+                {name: "no panic", wantStatus: 200},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetFormat(FormatJSON)
+                        SetStackFilter(func(frame runtime.Frame) bool {
+                                return strings.HasSuffix(frame.File, "_test.go") || defaultStackFilter(frame)
+                        })
+                        var line int
+                        var handler http.Handler = RecoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                                if tt.write != 0 {
+                                        w.WriteHeader(tt.write)
+                                }
+                                if tt.panicValue != nil {
+                                        line = thisLine() + 1
+                                        panic(tt.panicValue)
+                                }
+                        }))
+                        if tt.withID {
+                                handler = Middleware(handler)
+                        }
+                        r := httptest.NewRequest("POST", "/orders", nil)
+                        r.Header.Set(RequestIDHeader, "req-9")
+                        w := httptest.NewRecorder()
+                        repanicked := func() (p interface{}) {
+                                defer func() { p = recover() }()
+                                handler.ServeHTTP(w, r)
+                                return nil
+                        }()
+
+                        if (repanicked != nil) != tt.wantRepanic {
+                                t.Fatalf("panic after RecoverMiddleware: %v", repanicked)
+                        }
+                        if !tt.wantRepanic && w.Code != tt.wantStatus {
+                                t.Errorf("response status %d, want %d", w.Code, tt.wantStatus)
+                        }
+                        var panics []map[string]interface{}
+                        for _, record := range out.Records(t) {
+                                if _, ok := record[PanicKey]; ok {
+                                        panics = append(panics, record)
+                                }
+                        }
+                        if !tt.wantRecord {
+                                if len(panics) != 0 {
+                                        t.Errorf("unexpected panic records %v", panics)
+                                }
+                                return
+                        }
+                        if len(panics) != 1 {
+                                t.Fatalf("got %d panic records, want 1", len(panics))
+                        }
+                        record := panics[0]
+                        want := map[string]interface{}{
+                                "level":  "error",
+                                PanicKey: fmt.Sprint(tt.panicValue),
+                                "method": "POST",
+                                "path":   "/orders",
+                                "caller": fmt.Sprintf("http_test.go:%d", line),
+                        }
+                        if tt.withID {
+                                want[RequestIDKey] = "req-9"
+                        }
+                        for key, value := range want {
+                                if got := record[key]; got != value {
+                                        t.Errorf("panic record %s = %v, want %v", key, got, value)
+                                }
+                        }
+                        if _, ok := record[RequestIDKey]; ok && !tt.withID {
+                                t.Errorf("panic record has a request id without Middleware")
+                        }
+                        stack, _ := record[StackKey].(string)
+                        if files := stackFiles(stack); len(files) == 0 || files[0] != fmt.Sprintf("%s:%d", thisFile(), line) {
+                                t.Errorf("stack doesn't start at the panic:\n%s", stack)
+                        }
+                })
+        }
+}
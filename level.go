@@ -0,0 +1,230 @@
+// File: level.go
+// Description:
+// Runtime control of the log level, including temporarily raising the
+// verbosity for a limited time and sharing the level with log/slog.
+
+package logger
+
+import (
+        "log/slog"
+        "runtime"
+        "sort"
+        "strings"
+        "sync"
+        "sync/atomic"
+        "time"
+)
+
+// Level is a log level (LevelDebug .. LevelFatal). It implements slog.Leveler.
+type Level int
+
+var (
+        // Level source shared with log/slog, if any
+        sharedLevelVar atomic.Pointer[slog.LevelVar]
+
+        // Level overrides by package, longest prefix first
+        packageLevels   []packageLevel
+        packageLevelsMu sync.RWMutex
+
+        // Pending revert scheduled by SetLevelFor
+        levelTimer    ClockTimer
+        levelRevertTo int
+        levelTimerGen int
+        levelTimerMu  sync.Mutex
+)
+
+// SetLevel changes the current log level. Any revert pending from
+// SetLevelFor is cancelled.
+func SetLevel(level int) {
+        levelTimerMu.Lock()
+        defer levelTimerMu.Unlock()
+        cancelLevelRevert()
+        storeLevel(level)
+}
+
+// GetLevel returns the current log level
+func GetLevel() int {
+        if v := sharedLevelVar.Load(); v != nil {
+                return levelFromSlog(v.Level())
+        }
+        return int(atomic.LoadInt32(&currentLevel))
+}
+
+// Enabled reports whether records of the given level can be written, taking
+// package level overrides into account, so that expensive arguments can be
+// computed only when needed. Variadic log calls allocate their argument
+// slice even when the level is disabled; in hot paths guard them with
+//
+//	if logger.Enabled(logger.LevelDebug) {
+//		logger.Debugf("state: %v", dump())
+//	}
+//
+// Debug is never enabled in logger_release builds.
+func Enabled(level int) bool {
+        if level <= LevelDebug && !debugCompiled {
+                return false
+        }
+        return level >= minEnabledLevel()
+}
+
+// storeLevel sets the current level and the shared slog level, if any
+func storeLevel(level int) {
+        atomic.StoreInt32(&currentLevel, int32(level))
+        if v := sharedLevelVar.Load(); v != nil {
+                v.Set(Level(level).Level())
+        }
+}
+
+// Level implements slog.Leveler
+func (l Level) Level() slog.Level {
+        switch int(l) {
+        case LevelDebug:
+                return slog.LevelDebug
+        case LevelInfo:
+                return slog.LevelInfo
+        case LevelWarning:
+                return slog.LevelWarn
+        case LevelError:
+                return slog.LevelError
+        case LevelFatal:
+                return slog.LevelError + 4
+        default:
+                return slog.LevelInfo
+        }
+}
+
+// String returns the level name
+func (l Level) String() string {
+        return levelName(int(l))
+}
+
+// levelFromSlog maps a slog level to the closest package level
+func levelFromSlog(l slog.Level) int {
+        switch {
+        case l < slog.LevelInfo:
+                return LevelDebug
+        case l < slog.LevelWarn:
+                return LevelInfo
+        case l < slog.LevelError:
+                return LevelWarning
+        case l < slog.LevelError+4:
+                return LevelError
+        default:
+                return LevelFatal
+        }
+}
+
+// UseLevelVar makes v the source of the log level, so that the level can be
+// shared with slog handlers: changes made with SetLevel are visible through
+// v and changes made with v.Set apply here. v starts at the current level.
+// Passing nil stops sharing and keeps the current level.
+func UseLevelVar(v *slog.LevelVar) {
+        level := GetLevel()
+        if v != nil {
+                v.Set(Level(level).Level())
+        }
+        atomic.StoreInt32(&currentLevel, int32(level))
+        sharedLevelVar.Store(v)
+}
+
+// packageLevel is a level override for callers matching a prefix
+type packageLevel struct {
+        prefix string
+        level  int
+}
+
+// SetPackageLevel overrides the log level for callers whose fully qualified
+// function name (e.g. "github.com/acme/app/db.Open") or source file path
+// starts with pkgPrefix. When several prefixes match, the longest one wins.
+func SetPackageLevel(pkgPrefix string, level int) {
+        packageLevelsMu.Lock()
+        defer packageLevelsMu.Unlock()
+
+        for i := range packageLevels {
+                if packageLevels[i].prefix == pkgPrefix {
+                        packageLevels[i].level = level
+                        return
+                }
+        }
+        packageLevels = append(packageLevels, packageLevel{pkgPrefix, level})
+        sort.Slice(packageLevels, func(i, j int) bool {
+                return len(packageLevels[i].prefix) > len(packageLevels[j].prefix)
+        })
+}
+
+// clearPackageLevels removes every package level override
+func clearPackageLevels() {
+        packageLevelsMu.Lock()
+        defer packageLevelsMu.Unlock()
+        packageLevels = nil
+}
+
+// minEnabledLevel returns the lowest level any caller may log at, so
+// disabled records can be skipped before looking up the caller
+func minEnabledLevel() int {
+        level := GetLevel()
+        packageLevelsMu.RLock()
+        defer packageLevelsMu.RUnlock()
+        for _, p := range packageLevels {
+                if p.level < level {
+                        level = p.level
+                }
+        }
+        return level
+}
+
+// levelForCaller returns the level threshold for the given call site
+func levelForCaller(pc uintptr, file string) int {
+        packageLevelsMu.RLock()
+        defer packageLevelsMu.RUnlock()
+        if len(packageLevels) == 0 {
+                return GetLevel()
+        }
+
+        var function string
+        if fn := runtime.FuncForPC(pc); fn != nil {
+                function = fn.Name()
+        }
+        for _, p := range packageLevels {
+                if strings.HasPrefix(function, p.prefix) || strings.HasPrefix(file, p.prefix) {
+                        return p.level
+                }
+        }
+        return GetLevel()
+}
+
+// SetLevelFor changes the log level for the given duration and then reverts
+// to the previous level. Overlapping calls restart the timer and still revert
+// to the level that was active before the first call.
+func SetLevelFor(level int, d time.Duration) {
+        levelTimerMu.Lock()
+        defer levelTimerMu.Unlock()
+
+        if levelTimer == nil {
+                levelRevertTo = GetLevel()
+        }
+        cancelLevelRevert()
+        storeLevel(level)
+
+        gen := levelTimerGen
+        levelTimer = afterFunc(d, func() {
+                levelTimerMu.Lock()
+                defer levelTimerMu.Unlock()
+
+                // Ignore timers superseded while waiting for the lock
+                if gen != levelTimerGen {
+                        return
+                }
+                storeLevel(levelRevertTo)
+                levelTimer = nil
+        })
+}
+
+// cancelLevelRevert stops a pending revert; levelTimerMu must be held
+func cancelLevelRevert() {
+        levelTimerGen++
+        if levelTimer != nil {
+                levelTimer.Stop()
+                levelTimer = nil
+        }
+}
@@ -0,0 +1,243 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "log/slog"
+        "path/filepath"
+        "runtime"
+        "strings"
+        "testing"
+        "time"
+)
+
+func TestSetLevelFor(t *testing.T) {
+        type step struct {
+                advance time.Duration
+                set     int           // Level passed to SetLevelFor, -1 for none
+                d       time.Duration // Duration passed to SetLevelFor
+                want    int           // Level expected afterwards
+        }
+        tests := []struct {
+                name  string
+                steps []step
+        }{
+                {
+                        name: "reverts after the duration",
+                        steps: []step{
+                                {set: LevelDebug, d: time.Minute, want: LevelDebug},
+                                {advance: 59 * time.Second, set: -1, want: LevelDebug},
+                                {advance: time.Second, set: -1, want: LevelInfo},
+                        },
+                },
+                {
+                        name: "overlapping calls restart the timer",
+                        steps: []step{
+                                {set: LevelDebug, d: time.Minute, want: LevelDebug},
+                                {advance: 30 * time.Second, set: LevelWarning, d: time.Minute, want: LevelWarning},
+                                {advance: 40 * time.Second, set: -1, want: LevelWarning},
+                                {advance: 20 * time.Second, set: -1, want: LevelInfo},
+                                {advance: time.Hour, set: -1, want: LevelInfo},
+                        },
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        clock := useFakeClock(t)
+                        for i, s := range tt.steps {
+                                clock.Advance(s.advance)
+                                if s.set >= 0 {
+                                        SetLevelFor(s.set, s.d)
+                                }
+                                if got := GetLevel(); got != s.want {
+                                        t.Fatalf("step %d: level %d, want %d", i, got, s.want)
+                                }
+                        }
+                })
+        }
+}
+
+func TestLevelLeveler(t *testing.T) {
+        tests := []struct {
+                level int
+                want  slog.Level
+        }{
+                {LevelDebug, slog.LevelDebug},
+                {LevelInfo, slog.LevelInfo},
+                {LevelWarning, slog.LevelWarn},
+                {LevelError, slog.LevelError},
+                {LevelFatal, slog.LevelError + 4},
+        }
+        for _, tt := range tests {
+                t.Run(Level(tt.level).String(), func(t *testing.T) {
+                        var leveler slog.Leveler = Level(tt.level)
+                        if got := leveler.Level(); got != tt.want {
+                                t.Errorf("Level() = %v, want %v", got, tt.want)
+                        }
+                        if got := levelFromSlog(tt.want); got != tt.level {
+                                t.Errorf("levelFromSlog(%v) = %d, want %d", tt.want, got, tt.level)
+                        }
+                })
+        }
+}
+
+func TestUseLevelVar(t *testing.T) {
+        tests := []struct {
+                name    string
+                change  func(v *slog.LevelVar)
+                want    int
+                wantVar slog.Level
+        }{
+                {"SetLevel seen by slog", func(*slog.LevelVar) { SetLevel(LevelError) }, LevelError, slog.LevelError},
+                {"slog seen by GetLevel", func(v *slog.LevelVar) { v.Set(slog.LevelWarn) }, LevelWarning, slog.LevelWarn},
+                {"slog level between levels", func(v *slog.LevelVar) { v.Set(slog.LevelInfo + 2) }, LevelInfo, slog.LevelInfo + 2},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        SetLevel(LevelDebug)
+                        v := new(slog.LevelVar)
+                        UseLevelVar(v)
+                        if v.Level() != slog.LevelDebug {
+                                t.Fatalf("shared variable starts at %v, want the current level", v.Level())
+                        }
+                        tt.change(v)
+                        if got := GetLevel(); got != tt.want {
+                                t.Errorf("GetLevel() = %d, want %d", got, tt.want)
+                        }
+                        if got := v.Level(); got != tt.wantVar {
+                                t.Errorf("LevelVar = %v, want %v", got, tt.wantVar)
+                        }
+                })
+        }
+}
+
+// debugFromStore and debugFromHandler log a debug record from two different
+// functions
+func debugFromStore()   { Debug("store debug") }
+func debugFromHandler() { Debug("handler debug") }
+
+func TestPackageLevel(t *testing.T) {
+        _, file, _, _ := runtime.Caller(0)
+        tests := []struct {
+                name   string
+                prefix string
+                level  int
+                want   []string
+                absent []string
+        }{
+                {
+                        name:   "function prefix",
+                        prefix: packagePrefix + ".debugFromStore",
+                        level:  LevelDebug,
+                        want:   []string{"store debug"},
+                        absent: []string{"handler debug"},
+                },
+                {
+                        name:   "file prefix",
+                        prefix: filepath.Dir(file),
+                        level:  LevelDebug,
+                        want:   []string{"store debug", "handler debug"},
+                },
+                {
+                        name:   "no match",
+                        prefix: "github.com/acme/other",
+                        level:  LevelDebug,
+                        absent: []string{"store debug", "handler debug"},
+                },
+                {
+                        name:   "quieter package",
+                        prefix: packagePrefix + ".debugFromStore",
+                        level:  LevelError,
+                        absent: []string{"store debug", "handler debug"},
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        requireDebug(t)
+                        out := captureOutput(t)
+                        SetPackageLevel(tt.prefix, tt.level)
+                        debugFromStore()
+                        debugFromHandler()
+
+                        got := out.String()
+                        for _, s := range tt.want {
+                                if !strings.Contains(got, s) {
+                                        t.Errorf("output %q lacks %q", got, s)
+                                }
+                        }
+                        for _, s := range tt.absent {
+                                if strings.Contains(got, s) {
+                                        t.Errorf("output %q contains %q", got, s)
+                                }
+                        }
+                })
+        }
+}
+
+func TestEnabled(t *testing.T) {
+        tests := []struct {
+                name     string
+                level    int
+                pkgLevel int // Package override level, -1 for none
+                check    int
+                want     bool
+        }{
+                {name: "below threshold", level: LevelInfo, pkgLevel: -1, check: LevelDebug, want: false},
+                {name: "at threshold", level: LevelInfo, pkgLevel: -1, check: LevelInfo, want: true},
+                {name: "above threshold", level: LevelWarning, pkgLevel: -1, check: LevelError, want: true},
+                {name: "debug level", level: LevelDebug, pkgLevel: -1, check: LevelDebug, want: debugCompiled},
+                {name: "package override", level: LevelError, pkgLevel: LevelInfo, check: LevelInfo, want: true},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        SetLevel(tt.level)
+                        if tt.pkgLevel >= 0 {
+                                SetPackageLevel(packagePrefix, tt.pkgLevel)
+                        }
+                        if got := Enabled(tt.check); got != tt.want {
+                                t.Errorf("Enabled(%d) = %v, want %v", tt.check, got, tt.want)
+                        }
+                })
+        }
+}
+
+func TestDisabledDebugAllocs(t *testing.T) {
+        captureOutput(t)
+        SetLevel(LevelInfo)
+        user, n := "alice", 42
+        allocs := testing.AllocsPerRun(100, func() {
+                if Enabled(LevelDebug) {
+                        Debugf("user %s has %d items", user, n)
+                }
+        })
+        if allocs != 0 {
+                t.Errorf("guarded disabled debug call allocates %v times, want 0", allocs)
+        }
+}
+
+// BenchmarkDisabledDebug compares disabled debug calls with and without an
+// Enabled guard
+func BenchmarkDisabledDebug(b *testing.B) {
+        Reset()
+        defer Reset()
+        SetOutput(&syncBuffer{})
+        SetLevel(LevelInfo)
+        user, n := "alice", 42
+        b.Run("unguarded", func(b *testing.B) {
+                b.ReportAllocs()
+                for i := 0; i < b.N; i++ {
+                        Debugf("user %s has %d items", user, n)
+                }
+        })
+        b.Run("guarded", func(b *testing.B) {
+                b.ReportAllocs()
+                for i := 0; i < b.N; i++ {
+                        if Enabled(LevelDebug) {
+                                Debugf("user %s has %d items", user, n)
+                        }
+                }
+        })
+}
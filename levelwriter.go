@@ -0,0 +1,105 @@
+// File: levelwriter.go
+// Description:
+// An io.Writer that logs every line written to it, inferring the level from
+// a prefix such as "ERROR:". It lets the output of a child process be
+// ingested with its own leveling, e.g. cmd.Stderr = logger.NewLevelWriter().
+
+package logger
+
+import (
+        "bytes"
+        "strings"
+        "sync"
+        "time"
+)
+
+// DefaultLevelPrefixes are the line prefixes recognized by NewLevelWriter
+var DefaultLevelPrefixes = map[string]int{
+        "DEBUG:":   LevelDebug,
+        "INFO:":    LevelInfo,
+        "WARN:":    LevelWarning,
+        "WARNING:": LevelWarning,
+        "ERROR:":   LevelError,
+        "FATAL:":   LevelFatal,
+}
+
+// LevelWriter logs each line written to it at the level inferred from its
+// prefix. Prefixes are matched case-insensitively after leading spaces and
+// removed from the message; lines without a known prefix are logged at
+// Default. A fatal prefix logs at fatal level without exiting. The lines
+// come from whatever writes to the LevelWriter, typically a child process,
+// so the records carry no caller.
+type LevelWriter struct {
+        Prefixes map[string]int // Line prefix to level
+        Default  int            // Level of lines without a known prefix
+        Fields   Fields         // Fields attached to every line, e.g. the process name
+
+        mu      sync.Mutex
+        partial []byte
+}
+
+// NewLevelWriter returns a LevelWriter recognizing DefaultLevelPrefixes and
+// logging other lines at info
+func NewLevelWriter() *LevelWriter {
+        prefixes := make(map[string]int, len(DefaultLevelPrefixes))
+        for p, level := range DefaultLevelPrefixes {
+                prefixes[p] = level
+        }
+        return &LevelWriter{Prefixes: prefixes, Default: LevelInfo}
+}
+
+// Write logs the complete lines in p; an incomplete last line is kept
+// until the rest of it is written or the writer is closed
+func (w *LevelWriter) Write(p []byte) (int, error) {
+        w.mu.Lock()
+        defer w.mu.Unlock()
+
+        w.partial = append(w.partial, p...)
+        for {
+                i := bytes.IndexByte(w.partial, '\n')
+                if i < 0 {
+                        break
+                }
+                w.logLine(string(bytes.TrimRight(w.partial[:i], "\r")))
+                w.partial = w.partial[i+1:]
+        }
+        return len(p), nil
+}
+
+// Close logs the pending incomplete line, if any
+func (w *LevelWriter) Close() error {
+        w.mu.Lock()
+        defer w.mu.Unlock()
+        if len(w.partial) > 0 {
+                w.logLine(string(w.partial))
+                w.partial = nil
+        }
+        return nil
+}
+
+// logLine logs one line at its inferred level
+func (w *LevelWriter) logLine(line string) {
+        level, msg := w.levelOf(line)
+        if level < minEnabledLevel() {
+                return
+        }
+        emitAt(nil, 2, time.Time{}, 0, "", 0, level, w.Fields, "", msg)
+}
+
+// levelOf infers the level of a line and strips the prefix. The longest
+// matching prefix wins.
+func (w *LevelWriter) levelOf(line string) (int, string) {
+        trimmed := strings.TrimLeft(line, " \t")
+        upper := strings.ToUpper(trimmed)
+        best := ""
+        level := w.Default
+        for p, l := range w.Prefixes {
+                if len(p) > len(best) && strings.HasPrefix(upper, strings.ToUpper(p)) {
+                        best, level = p, l
+                }
+        }
+        if best == "" {
+                return level, line
+        }
+        return level, strings.TrimLeft(trimmed[len(best):], " \t")
+}
@@ -0,0 +1,98 @@
+//go:build !logger_minimal
+
+package logger
+
+import "testing"
+
+func TestLevelWriter(t *testing.T) {
+        type line struct {
+                level, message string
+        }
+        tests := []struct {
+                name   string
+                writer func() *LevelWriter
+                writes []string
+                want   []line
+        }{
+                {
+                        name:   "default prefixes",
+                        writer: NewLevelWriter,
+                        writes: []string{"INFO: started\nerror: disk full\n  WARNING: slow\nWARN:retrying\nFATAL: giving up\n"},
+                        want: []line{
+                                {"info", "started"},
+                                {"error", "disk full"},
+                                {"warning", "slow"},
+                                {"warning", "retrying"},
+                                {"fatal", "giving up"},
+                        },
+                },
+                {
+                        name:   "no prefix",
+                        writer: NewLevelWriter,
+                        writes: []string{"plain line\nERRORS are not a prefix\n"},
+                        want:   []line{{"info", "plain line"}, {"info", "ERRORS are not a prefix"}},
+                },
+                {
+                        name:   "lines split across writes",
+                        writer: NewLevelWriter,
+                        writes: []string{"ERR", "OR: broken", " pipe\r\nINFO: ", "done\nWARN: unterminated"},
+                        want:   []line{{"error", "broken pipe"}, {"info", "done"}, {"warning", "unterminated"}},
+                },
+                {
+                        name: "custom prefixes and default",
+                        writer: func() *LevelWriter {
+                                return &LevelWriter{
+                                        Prefixes: map[string]int{"[E]": LevelError, "[W]": LevelWarning},
+                                        Default:  LevelWarning,
+                                }
+                        },
+                        writes: []string{"[E] failed\n[W] odd\nINFO: not recognized\n"},
+                        want:   []line{{"error", "failed"}, {"warning", "odd"}, {"warning", "INFO: not recognized"}},
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        exits := stubExit(t)
+                        SetFormat(FormatJSON)
+                        w := tt.writer()
+                        for _, p := range tt.writes {
+                                if n, err := w.Write([]byte(p)); n != len(p) || err != nil {
+                                        t.Fatalf("Write = %d, %v", n, err)
+                                }
+                        }
+                        w.Close()
+
+                        records := out.Records(t)
+                        if len(records) != len(tt.want) {
+                                t.Fatalf("got %d records, want %d: %v", len(records), len(tt.want), records)
+                        }
+                        for i, want := range tt.want {
+                                if records[i]["level"] != want.level || records[i]["message"] != want.message {
+                                        t.Errorf("record %d = %v, want %s %q", i, records[i], want.level, want.message)
+                                }
+                                if caller, ok := records[i]["caller"]; ok {
+                                        t.Errorf("record %d has caller %v", i, caller)
+                                }
+                        }
+                        if codes := exits(); len(codes) != 0 {
+                                t.Errorf("fatal line exited with %v", codes)
+                        }
+                })
+        }
+}
+
+func TestLevelWriterDebug(t *testing.T) {
+        requireDebug(t)
+        out := captureOutput(t)
+        SetFormat(FormatJSON)
+        w := NewLevelWriter()
+        w.Write([]byte("DEBUG: hidden at info\n"))
+        SetLevel(LevelDebug)
+        w.Write([]byte("debug: shown\n"))
+
+        records := out.Records(t)
+        if len(records) != 1 || records[0]["level"] != "debug" || records[0]["message"] != "shown" {
+                t.Errorf("records %v, want one debug record", records)
+        }
+}
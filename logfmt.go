@@ -0,0 +1,58 @@
+// File: logfmt.go
+// Description:
+// logfmt encoding: one line of space separated key=value pairs per record,
+// as understood by Loki, Grafana and most log processors. Values that
+// contain spaces, quotes or '=' are quoted.
+
+package logger
+
+import (
+        "fmt"
+        "sort"
+        "strconv"
+        "strings"
+        "time"
+)
+
+// LogfmtEncoder renders records as `time=... level=info caller=app.go:12 msg="..." key=value`
+type LogfmtEncoder struct {
+        // Names of the standard keys
+        Keys FieldKeys
+}
+
+// Encode implements Encoder. The standard keys come first and the fields
+// after them, sorted by key.
+func (e LogfmtEncoder) Encode(rec Record) ([]byte, error) {
+        var b strings.Builder
+        writeLogfmtPair(&b, keyOr(e.Keys.Time, "time"), rec.Time.Format(time.RFC3339Nano), true)
+        writeLogfmtPair(&b, keyOr(e.Keys.Level, "level"), levelName(rec.Level), false)
+        if rec.Caller != "" {
+                writeLogfmtPair(&b, keyOr(e.Keys.Caller, "caller"), rec.Caller, false)
+        }
+        writeLogfmtPair(&b, keyOr(e.Keys.Message, "msg"), rec.Message, false)
+
+        keys := make([]string, 0, len(rec.Fields))
+        for k := range rec.Fields {
+                keys = append(keys, k)
+        }
+        sort.Strings(keys)
+        for _, k := range keys {
+                writeLogfmtPair(&b, k, fmt.Sprint(fieldValue(k, rec.Fields[k])), false)
+        }
+        b.WriteString("\n")
+        return []byte(b.String()), nil
+}
+
+// writeLogfmtPair appends key=value to b, quoting the value if needed
+func writeLogfmtPair(b *strings.Builder, key, value string, first bool) {
+        if !first {
+                b.WriteByte(' ')
+        }
+        b.WriteString(key)
+        b.WriteByte('=')
+        if value == "" || strings.ContainsAny(value, " =\"\t\r\n") {
+                b.WriteString(strconv.Quote(value))
+                return
+        }
+        b.WriteString(value)
+}
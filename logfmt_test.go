@@ -0,0 +1,71 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "strings"
+        "testing"
+        "time"
+)
+
+func TestLogfmtEncoder(t *testing.T) {
+        at := time.Date(2023, 3, 8, 10, 0, 0, 0, time.UTC)
+        tests := []struct {
+                name string
+                enc  LogfmtEncoder
+                rec  Record
+                want string
+        }{
+                {
+                        name: "standard keys first",
+                        rec:  Record{Time: at, Level: LevelInfo, Caller: "app.go:12", Message: "started", Fields: Fields{"port": 8080}},
+                        want: "time=2023-03-08T10:00:00Z level=info caller=app.go:12 msg=started port=8080\n",
+                },
+                {
+                        name: "fields sorted",
+                        rec:  Record{Time: at, Level: LevelWarning, Message: "slow", Fields: Fields{"zone": "b", "attempt": 2, "method": "GET"}},
+                        want: "time=2023-03-08T10:00:00Z level=warning msg=slow attempt=2 method=GET zone=b\n",
+                },
+                {
+                        name: "quoting",
+                        rec: Record{Time: at, Level: LevelError, Message: "request failed", Fields: Fields{
+                                "query": "a=b", "quote": `say "hi"`, "empty": "", "tab": "a\tb", "plain": "ok",
+                        }},
+                        want: `time=2023-03-08T10:00:00Z level=error msg="request failed" empty="" plain=ok query="a=b" quote="say \"hi\"" tab="a\tb"` + "\n",
+                },
+                {
+                        name: "multi-line message",
+                        rec:  Record{Time: at, Level: LevelInfo, Message: "line 1\nline 2"},
+                        want: `time=2023-03-08T10:00:00Z level=info msg="line 1\nline 2"` + "\n",
+                },
+                {
+                        name: "renamed keys",
+                        enc:  LogfmtEncoder{Keys: FieldKeys{Time: "ts", Level: "lvl", Message: "message"}},
+                        rec:  Record{Time: at, Level: LevelInfo, Caller: "app.go:12", Message: "started"},
+                        want: "ts=2023-03-08T10:00:00Z lvl=info caller=app.go:12 message=started\n",
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        got, err := tt.enc.Encode(tt.rec)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        if string(got) != tt.want {
+                                t.Errorf("got  %s\nwant %s", got, tt.want)
+                        }
+                })
+        }
+}
+
+func TestLogfmtRedaction(t *testing.T) {
+        out := captureOutput(t)
+        SetFormat(FormatLogfmt)
+        RedactFields("token")
+        Infow("authenticated", "token", "s3cr3t", "user", "alice")
+        got := out.String()
+        if want := ` msg=authenticated token=*** user=alice` + "\n"; !strings.HasSuffix(got, want) {
+                t.Errorf("output %q, want it to end with %q", got, want)
+        }
+}
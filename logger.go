@@ -13,12 +13,9 @@ package logger
 
 import (
         "fmt"
-        "io"
-        "log"
         "os"
         "path/filepath"
-        "runtime"
-        "strings"
+        "sync/atomic"
         "time"
 )
 
@@ -32,220 +29,343 @@ const (
 )
 
 var (
-        // Loggers for different levels
-        debugLogger   *log.Logger
-        infoLogger    *log.Logger
-        warningLogger *log.Logger
-        errorLogger   *log.Logger
-        fatalLogger   *log.Logger
-
-        // Current log level
-        currentLevel = LevelInfo
+        // Current log level (accessed atomically)
+        currentLevel int32 = LevelInfo
 
         // Log file
         logFile *os.File
-)
 
-// InitLogger initializes the logging system
-func InitLogger(level int, logToFile bool, logFileName string) error {
-        currentLevel = level
+        // Terminates the process after a fatal message (replaceable in tests)
+        exitFunc = os.Exit
 
-        // Set up log format: timestamp, file:line, message
-        flags := log.Ldate | log.Ltime | log.Lshortfile
+        // Exit status used by Fatal and Fatalf (accessed atomically)
+        fatalExitCode int32 = 1
+)
 
-        // Set up output writer(s)
-        var writers []io.Writer
-        writers = append(writers, os.Stdout) // Always log to stdout
+// InitLogger initializes the logging system. Calling it is optional: the
+// package starts with usable defaults (info level, text to stdout), so
+// scripts and small tools can log right away and only call InitLogger to
+// change the level or log to a file.
+func InitLogger(level int, logToFile bool, logFileName string) error {
+        SetLevel(level)
 
         // If logging to file is enabled, set up the file writer
+        var file *os.File
+        var pending string
         if logToFile && logFileName != "" {
-                // Create logs directory if it doesn't exist
-                logsDir := filepath.Dir(logFileName)
-                if _, err := os.Stat(logsDir); os.IsNotExist(err) {
-                        if err := os.MkdirAll(logsDir, 0755); err != nil {
-                                return fmt.Errorf("failed to create logs directory: %v", err)
+                if lazyFile {
+                        // Created by the first write
+                        pending = logFileName
+                } else {
+                        var err error
+                        file, err = openLogFile(logFileName)
+                        if err != nil {
+                                return err
                         }
                 }
-
-                // Open log file with append mode, create if doesn't exist
-                var err error
-                logFile, err = os.OpenFile(logFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-                if err != nil {
-                        return fmt.Errorf("failed to open log file: %v", err)
-                }
-
-                writers = append(writers, logFile)
         }
 
-        // Create a multiwriter if we have multiple outputs
-        var output io.Writer
-        if len(writers) == 1 {
-                output = writers[0]
-        } else {
-                output = io.MultiWriter(writers...)
+        // Swap in the new file, closing one left open by a previous initialization
+        outputsMu.Lock()
+        previous := detachLogFile()
+        attachLogFile(file)
+        endFailover()
+        pendingLogFile = pending
+        outputsMu.Unlock()
+        if previous != nil {
+                previous.Close()
         }
 
-        // Initialize loggers with appropriate prefixes
-        debugLogger = log.New(output, "[DEBUG] ", flags)
-        infoLogger = log.New(output, "[INFO] ", flags)
-        warningLogger = log.New(output, "[WARN] ", flags)
-        errorLogger = log.New(output, "[ERROR] ", flags)
-        fatalLogger = log.New(output, "[FATAL] ", flags)
-
-        // Use the default logger for general messages
-        log.SetOutput(output)
-        log.SetFlags(flags)
-        log.SetPrefix("[LOG] ")
+        updateCurrentSymlink()
 
         return nil
 }
 
-// CloseLogger closes any open resources (like log files)
-func CloseLogger() {
-        if logFile != nil {
-                logFile.Close()
+// openLogFile opens a log file for appending, creating it and its
+// directory if they don't exist
+func openLogFile(logFileName string) (*os.File, error) {
+        // Create logs directory if it doesn't exist
+        logsDir := filepath.Dir(logFileName)
+        if _, err := os.Stat(logsDir); os.IsNotExist(err) {
+                if err := os.MkdirAll(logsDir, 0755); err != nil {
+                        return nil, fmt.Errorf("failed to create logs directory: %v", err)
+                }
         }
-}
 
-// GetLogger returns the appropriate logger based on the level
-func getLogger(level int) *log.Logger {
-        switch level {
-        case LevelDebug:
-                return debugLogger
-        case LevelInfo:
-                return infoLogger
-        case LevelWarning:
-                return warningLogger
-        case LevelError:
-                return errorLogger
-        case LevelFatal:
-                return fatalLogger
-        default:
-                return infoLogger
+        // Open log file with append mode, create if doesn't exist
+        file, err := os.OpenFile(logFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+        if err != nil {
+                return nil, fmt.Errorf("failed to open log file: %v", err)
         }
+        return file, nil
 }
 
-// logWithCallerInfo logs a message with the caller info (file, line, function)
-func logWithCallerInfo(level int, format string, v ...interface{}) {
-        if level < currentLevel {
-                return
-        }
-
-        logger := getLogger(level)
+// CloseLogger closes any open resources (like log files)
+func CloseLogger() {
+        CloseAll()
+}
 
-        // Get caller information
-        _, file, line, ok := runtime.Caller(2)
-        if ok {
-                if format == "" {
-                        msg := fmt.Sprint(v...)
-                        logger.Printf("%s:%d: %s", filepath.Base(file), line, msg)
-                } else {
-                        msg := fmt.Sprintf(format, v...)
-                        logger.Printf("%s:%d: %s", filepath.Base(file), line, msg)
+// CloseAll flushes and closes the log file, every additional output and
+// the audit file. A failure doesn't stop the others from being closed; the
+// errors are returned, nil if there were none.
+func CloseAll() []error {
+        var errs []error
+        outputsMu.Lock()
+        if err := flushFileBuffer(); err != nil {
+                errs = append(errs, fmt.Errorf("failed to flush log file: %v", err))
+        }
+        if logGzip != nil {
+                if err := logGzip.Close(); err != nil {
+                        errs = append(errs, fmt.Errorf("failed to finish compressed log file: %v", err))
                 }
-        } else {
-                if format == "" {
-                        logger.Print(v...)
-                } else {
-                        logger.Printf(format, v...)
+                logGzip = nil
+        }
+        if f := detachLogFile(); f != nil {
+                if err := f.Close(); err != nil {
+                        errs = append(errs, fmt.Errorf("failed to close log file: %v", err))
                 }
         }
+        pendingLogFile = ""
+        endFailover()
+        outputsMu.Unlock()
+        errs = append(errs, closeExtraOutputs()...)
+        if err := SetAuditFile(""); err != nil {
+                errs = append(errs, fmt.Errorf("failed to close audit file: %v", err))
+        }
+        checkHandleLeaks()
+        return errs
 }
 
-// Debug logs a debug message
-func Debug(v ...interface{}) {
-        logWithCallerInfo(LevelDebug, "", v...)
+// LogFilePath returns the path of the active log file, or an empty string
+// when not logging to a file. In lazy mode it is the path of the file that
+// will be created on the first write.
+func LogFilePath() string {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        if logFile != nil {
+                return logFile.Name()
+        }
+        return pendingLogFile
 }
 
-// Debugf logs a formatted debug message
-func Debugf(format string, v ...interface{}) {
-        logWithCallerInfo(LevelDebug, format, v...)
+// Reset closes any open log file, clears redacted fields and restores the
+// defaults (info level, stdout only). It is mainly intended for tests.
+func Reset() {
+        CloseLogger()
+        clearRedactedFields()
+        SetCurrentSymlink(false)
+        SetRotateNameFunc(nil)
+        SetMaxBackups(0)
+        SetNumberedRotation(false)
+        SetBinaryFormat(BinaryHex)
+        SetMaxMessageLength(0)
+        SetIncludeSequence(false)
+        SetIncludeFingerprint(false)
+        atomic.StoreUint64(&sequence, 0)
+        shutdownFuncsMu.Lock()
+        shutdownFuncs = nil
+        shutdownFuncsMu.Unlock()
+        SetFormat(FormatText)
+        resetStats()
+        clearFilters()
+        UseLevelVar(nil)
+        clearStrictFormat()
+        SetCompactOnRotate(false)
+        SetIncludeHostname(false)
+        SetHostname("")
+        SetErrorsToStderr(false)
+        SetFatalExitCode(1)
+        clearPackageLevels()
+        SetErrorHook(nil)
+        clearLastError()
+        SetMaxWriteFailures(0)
+        SetKeyedSampling("", 0)
+        SetOutput(nil)
+        SetLazyFile(false)
+        SetFailoverFile("")
+        SetFailoverRetryInterval(0)
+        SetColor(ColorAuto)
+        SetWSAllowedOrigins()
+        SetMaxFileSize(0)
+        SetRotateInterval(0)
+        SetNetworkTimeout(10 * time.Second)
+        SetCompressActive(false)
+        SetGlobalFieldsFromEnv(nil)
+        SetMaxOpenFiles(0)
+        stopSignalHandlers()
+        SetStackTraceLevel(-1)
+        SetStackFilter(nil)
+        resetEncoders()
+        SetIncludeEventID(false)
+        SetEventIDFunc(nil)
+        SetAlertOnError(false)
+        SetRedactQueryParams()
+        SetRequestIDHeader("")
+        SetDurabilityPolicy(nil)
+        SetIncludeSourceLine(false)
+        SetIdleRotation(0)
+        clearRetention()
+        SetOnRotate(nil)
+        SetPanicAction(PanicContinue)
+        clearPause()
+        SetWrapWidth(-1)
+        clearTimestampLevels()
+        SetSanitizeControlChars(false)
+        SetCrashDumpDir("")
+        SetCrashDumpLevel(LevelFatal)
+        SetMaxFields(0)
+        SetCollapseCaller(false)
+        clearLevelBuffers()
+        SetDeterministic(false)
+        SetErrorRateAlert(0, 0, nil)
+        clearFieldMarshalers()
+        clearSubscribers()
+        clearDynamicFields()
+        ReleaseStandardLog()
+        SetClock(nil)
+        SetRateLimit(0, 0)
+        SetRateLimitExemptErrors(false)
+        SetSchemaValidation(nil)
+        InitLogger(LevelInfo, false, "")
+}
+
+// callerDepth is the number of frames between runtime.Caller in emit and
+// the user's call site: emit, logWithCallerInfo (or logEntry, logBuffered)
+// and the exported function that was called. Every exported logging function
+// (Debug .. Fatalf, the Entry methods, Debugw .. Errorw, FatalCtx, ...)
+// must therefore call logWithCallerInfo directly, never through another
+// helper.
+const callerDepth = 3
+
+// logWithCallerInfo logs a message with the caller info (file, line, function)
+func logWithCallerInfo(level int, fields Fields, format string, v ...interface{}) {
+        if level < minEnabledLevel() {
+                return
+        }
+        emit(nil, 0, level, fields, format, v...)
+}
+
+// logEntry logs a message with the fields and caller skip of an entry. A
+// nil entry (e.g. the result of a failed New) logs without fields rather
+// than panicking.
+func logEntry(e *Entry, level int, format string, v ...interface{}) {
+        if level < minEnabledLevel() {
+                return
+        }
+        if e == nil {
+                emit(nil, 0, level, nil, format, v...)
+                return
+        }
+        emit(nil, e.skip, level, entryFields(e, level), format, v...)
 }
 
 // Info logs an info message
 func Info(v ...interface{}) {
-        logWithCallerInfo(LevelInfo, "", v...)
+        logWithCallerInfo(LevelInfo, nil, "", v...)
 }
 
 // Infof logs a formatted info message
 func Infof(format string, v ...interface{}) {
-        logWithCallerInfo(LevelInfo, format, v...)
+        logWithCallerInfo(LevelInfo, nil, format, v...)
 }
 
 // Warning logs a warning message
 func Warning(v ...interface{}) {
-        logWithCallerInfo(LevelWarning, "", v...)
+        logWithCallerInfo(LevelWarning, nil, "", v...)
 }
 
 // Warningf logs a formatted warning message
 func Warningf(format string, v ...interface{}) {
-        logWithCallerInfo(LevelWarning, format, v...)
+        logWithCallerInfo(LevelWarning, nil, format, v...)
 }
 
 // Error logs an error message
 func Error(v ...interface{}) {
-        logWithCallerInfo(LevelError, "", v...)
+        logWithCallerInfo(LevelError, nil, "", v...)
 }
 
 // Errorf logs a formatted error message
 func Errorf(format string, v ...interface{}) {
-        logWithCallerInfo(LevelError, format, v...)
+        logWithCallerInfo(LevelError, nil, format, v...)
 }
 
 // Fatal logs a fatal message and exits the program
 func Fatal(v ...interface{}) {
-        logWithCallerInfo(LevelFatal, "", v...)
-        os.Exit(1)
+        logWithCallerInfo(LevelFatal, nil, "", v...)
+        exitFunc(FatalExitCode())
 }
 
 // Fatalf logs a formatted fatal message and exits the program
 func Fatalf(format string, v ...interface{}) {
-        logWithCallerInfo(LevelFatal, format, v...)
-        os.Exit(1)
+        logWithCallerInfo(LevelFatal, nil, format, v...)
+        exitFunc(FatalExitCode())
+}
+
+// FatalCode logs a fatal message and exits the program with the given code
+func FatalCode(code int, v ...interface{}) {
+        logWithCallerInfo(LevelFatal, nil, "", v...)
+        exitFunc(code)
+}
+
+// SetFatalExitCode sets the exit status used by Fatal and Fatalf (default 1)
+func SetFatalExitCode(code int) {
+        atomic.StoreInt32(&fatalExitCode, int32(code))
+}
+
+// FatalExitCode returns the exit status used by Fatal and Fatalf
+func FatalExitCode() int {
+        return int(atomic.LoadInt32(&fatalExitCode))
 }
 
 // RotateLogFile rotates the log file (creates a new one with timestamp)
 func RotateLogFile() error {
+        return rotateLogFile(true)
+}
+
+// rotateLogFile rotates the log file and the additional outputs, logging
+// the new location if announce is set
+func rotateLogFile(announce bool) error {
+        outputsMu.Lock()
         if logFile == nil {
-                return nil // No log file to rotate
+                outputsMu.Unlock()
+                return rotateExtraOutputs() // No log file to rotate
         }
 
-        // Close current log file
-        logFile.Close()
-
-        // Get the path and base filename
-        dir, filename := filepath.Split(logFile.Name())
-        ext := filepath.Ext(filename)
-        baseFilename := strings.TrimSuffix(filename, ext)
+        compressed := logGzip != nil
+        newFile, newPath, err := rotateFile(detachLogFile())
+        if err != nil {
+                if newFile != nil {
+                        attachLogFile(newFile) // Keep logging to the same file
+                }
+                outputsMu.Unlock()
+                reportError(err)
+                return err
+        }
+        attachLogFile(newFile)
+        outputsMu.Unlock()
 
-        // Create a new filename with timestamp
-        timestamp := time.Now().Format("20060102-150405")
-        newFilename := fmt.Sprintf("%s-%s%s", baseFilename, timestamp, ext)
-        newPath := filepath.Join(dir, newFilename)
+        updateCurrentSymlink()
 
-        // Rename the old file
-        err := os.Rename(logFile.Name(), newPath)
-        if err != nil {
-                return fmt.Errorf("failed to rename log file: %v", err)
+        if !compressed {
+                if err := compactRotated(newPath); err != nil {
+                        Warningf("failed to compact rotated log file: %v", err)
+                }
         }
 
-        // Open a new log file
-        logFile, err = os.OpenFile(logFile.Name(), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-        if err != nil {
-                return fmt.Errorf("failed to open new log file: %v", err)
+        // Remove backups beyond the configured limit
+        if err := pruneBackupsFor(newFile.Name()); err != nil {
+                Warningf("failed to prune old log files: %v", err)
         }
 
-        // Update the writers for all loggers
-        writers := []io.Writer{os.Stdout, logFile}
-        output := io.MultiWriter(writers...)
+        // Rotate additional file outputs as well
+        if err := rotateExtraOutputs(); err != nil {
+                Warningf("failed to rotate additional outputs: %v", err)
+        }
 
-        debugLogger.SetOutput(output)
-        infoLogger.SetOutput(output)
-        warningLogger.SetOutput(output)
-        errorLogger.SetOutput(output)
-        fatalLogger.SetOutput(output)
-        log.SetOutput(output)
+        runOnRotate(newPath, newFile.Name())
 
-        Info("Log file rotated to", newPath)
+        if announce {
+                Info("Log file rotated to", newPath)
+        }
         return nil
 }
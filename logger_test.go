@@ -0,0 +1,400 @@
+// File: logger_test.go
+// Description:
+// Helpers shared by the package tests. The logger keeps its configuration in
+// package state, so every test starts from Reset, captures the console into a
+// buffer and never runs in parallel with another test.
+
+package logger
+
+import (
+        "bytes"
+        "encoding/json"
+        "io"
+        "os"
+        "path/filepath"
+        "strings"
+        "sync"
+        "testing"
+        "time"
+)
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of timers and
+// background outputs
+type syncBuffer struct {
+        mu  sync.Mutex
+        buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+        b.mu.Lock()
+        defer b.mu.Unlock()
+        return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+        b.mu.Lock()
+        defer b.mu.Unlock()
+        return b.buf.String()
+}
+
+// Lines returns the non-empty lines written so far
+func (b *syncBuffer) Lines() []string {
+        var lines []string
+        for _, line := range strings.Split(b.String(), "\n") {
+                if line != "" {
+                        lines = append(lines, line)
+                }
+        }
+        return lines
+}
+
+// Records decodes the JSON records written so far
+func (b *syncBuffer) Records(t *testing.T) []map[string]interface{} {
+        t.Helper()
+        var records []map[string]interface{}
+        for _, line := range b.Lines() {
+                var record map[string]interface{}
+                if err := json.Unmarshal([]byte(line), &record); err != nil {
+                        t.Fatalf("decoding %q: %v", line, err)
+                }
+                records = append(records, record)
+        }
+        return records
+}
+
+// captureOutput resets the package and sends the console to a buffer; the
+// package is reset again when the test ends
+func captureOutput(t *testing.T) *syncBuffer {
+        t.Helper()
+        Reset()
+        t.Cleanup(Reset)
+        out := &syncBuffer{}
+        SetOutput(out)
+        return out
+}
+
+// requireDebug skips tests of debug records in logger_release builds, where
+// they are compiled out
+func requireDebug(t *testing.T) {
+        t.Helper()
+        if !debugCompiled {
+                t.Skip("debug records are compiled out")
+        }
+}
+
+// stubExit replaces the process exit for the duration of the test; the
+// returned function reports the exit codes requested so far
+func stubExit(t *testing.T) func() []int {
+        t.Helper()
+        var mu sync.Mutex
+        var codes []int
+        exitFunc = func(code int) {
+                mu.Lock()
+                defer mu.Unlock()
+                codes = append(codes, code)
+        }
+        t.Cleanup(func() { exitFunc = os.Exit })
+        return func() []int {
+                mu.Lock()
+                defer mu.Unlock()
+                return append([]int(nil), codes...)
+        }
+}
+
+// fakeClock is a Clock that only moves when advanced, firing the timers
+// that fall due on the way
+type fakeClock struct {
+        mu     sync.Mutex
+        now    time.Time
+        timers []*fakeTimer
+}
+
+// fakeTimer is a timer of a fakeClock
+type fakeTimer struct {
+        clock *fakeClock
+        at    time.Time
+        f     func()
+        done  bool
+}
+
+// useFakeClock makes a fake clock, starting at a fixed time, the clock of
+// the package
+func useFakeClock(t *testing.T) *fakeClock {
+        t.Helper()
+        c := &fakeClock{now: time.Date(2023, 3, 8, 10, 0, 0, 0, time.UTC)}
+        SetClock(c)
+        t.Cleanup(func() { SetClock(nil) })
+        return c
+}
+
+// Now implements Clock
+func (c *fakeClock) Now() time.Time {
+        c.mu.Lock()
+        defer c.mu.Unlock()
+        return c.now
+}
+
+// AfterFunc implements Clock
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+        c.mu.Lock()
+        defer c.mu.Unlock()
+        timer := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+        c.timers = append(c.timers, timer)
+        return timer
+}
+
+// Stop implements ClockTimer
+func (t *fakeTimer) Stop() bool {
+        t.clock.mu.Lock()
+        defer t.clock.mu.Unlock()
+        stopped := !t.done
+        t.done = true
+        return stopped
+}
+
+// Advance moves the clock forward by d, calling the functions of the timers
+// that fall due in order; they run in the caller's goroutine
+func (c *fakeClock) Advance(d time.Duration) {
+        c.mu.Lock()
+        target := c.now.Add(d)
+        for {
+                var next *fakeTimer
+                for _, timer := range c.timers {
+                        if !timer.done && !timer.at.After(target) && (next == nil || timer.at.Before(next.at)) {
+                                next = timer
+                        }
+                }
+                if next == nil {
+                        break
+                }
+                next.done = true
+                c.now = next.at
+                c.mu.Unlock()
+                next.f()
+                c.mu.Lock()
+        }
+        c.now = target
+        pending := c.timers[:0]
+        for _, timer := range c.timers {
+                if !timer.done {
+                        pending = append(pending, timer)
+                }
+        }
+        c.timers = pending
+        c.mu.Unlock()
+}
+
+// tempLogPath returns the path of a log file in a fresh temporary directory
+func tempLogPath(t *testing.T, name string) string {
+        t.Helper()
+        return filepath.Join(t.TempDir(), name)
+}
+
+// readLog returns the content of a log file, failing the test if it cannot
+// be read
+func readLog(t *testing.T, path string) string {
+        t.Helper()
+        data, err := os.ReadFile(path)
+        if err != nil {
+                t.Fatalf("reading %s: %v", path, err)
+        }
+        return string(data)
+}
+
+// captureStderr redirects os.Stderr to a pipe until the returned function
+// is called, which returns what was written
+func captureStderr(t *testing.T) func() string {
+        t.Helper()
+        return captureFile(t, &os.Stderr)
+}
+
+// captureStdout redirects os.Stdout like captureStderr
+func captureStdout(t *testing.T) func() string {
+        t.Helper()
+        return captureFile(t, &os.Stdout)
+}
+
+// captureFile replaces *target with a pipe until the returned function is
+// called, which returns what was written
+func captureFile(t *testing.T, target **os.File) func() string {
+        t.Helper()
+        r, w, err := os.Pipe()
+        if err != nil {
+                t.Fatal(err)
+        }
+        previous := *target
+        *target = w
+        done := make(chan string)
+        go func() {
+                data, _ := io.ReadAll(r)
+                done <- string(data)
+        }()
+        var got *string
+        restore := func() string {
+                if got == nil {
+                        *target = previous
+                        w.Close()
+                        s := <-done
+                        got = &s
+                }
+                return *got
+        }
+        t.Cleanup(func() { restore() })
+        return restore
+}
+
+func TestReset(t *testing.T) {
+        captureOutput(t)
+        path := tempLogPath(t, "app.log")
+        if err := InitLogger(LevelDebug, true, path); err != nil {
+                t.Fatal(err)
+        }
+        SetFormat(FormatJSON)
+        RedactFields("password")
+        SetErrorHook(func(error) {})
+        AddOutput(&syncBuffer{}, FormatJSON)
+        AddFilter(func(int, string, map[string]interface{}) bool { return false })
+
+        Reset()
+
+        tests := []struct {
+                name string
+                ok   func() bool
+        }{
+                {"level is info", func() bool { return GetLevel() == LevelInfo }},
+                {"no log file", func() bool { return LogFilePath() == "" }},
+                {"console is stdout", func() bool { return consoleWriter == os.Stdout }},
+                {"console format is text", func() bool { return consoleFormat == FormatText }},
+                {"file format is text", func() bool { return fileFormat == FormatText }},
+                {"no additional outputs", func() bool { return len(Outputs()) == 0 }},
+                {"no redacted fields", func() bool { return !isRedacted("password") }},
+                {"no error hook", func() bool { return errorHook == nil }},
+                {"no filters", func() bool { return len(filters) == 0 }},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        if !tt.ok() {
+                                t.Error("default not restored")
+                        }
+                })
+        }
+}
+
+func TestFatalExitCode(t *testing.T) {
+        tests := []struct {
+                name       string
+                configured int // Code passed to SetFatalExitCode, 0 to keep the default
+                fatal      func()
+                want       int
+        }{
+                {name: "default", fatal: func() { Fatal("stop") }, want: 1},
+                {name: "configured", configured: 3, fatal: func() { Fatal("stop") }, want: 3},
+                {name: "configured, formatted", configured: 3, fatal: func() { Fatalf("stop %d", 1) }, want: 3},
+                {name: "explicit code", configured: 3, fatal: func() { FatalCode(7, "stop") }, want: 7},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        exitCodes := stubExit(t)
+                        if tt.configured != 0 {
+                                SetFatalExitCode(tt.configured)
+                        }
+                        tt.fatal()
+                        if codes := exitCodes(); len(codes) != 1 || codes[0] != tt.want {
+                                t.Errorf("exit codes %v, want [%d]", codes, tt.want)
+                        }
+                        if !strings.Contains(out.String(), "stop") {
+                                t.Errorf("fatal message not logged before exiting: %q", out.String())
+                        }
+                })
+        }
+}
+
+func TestLogFilePath(t *testing.T) {
+        captureOutput(t)
+        dir := t.TempDir()
+        first := filepath.Join(dir, "app.log")
+        second := filepath.Join(dir, "other.log")
+        tests := []struct {
+                name   string
+                change func() error
+                want   string
+        }{
+                {"no file", func() error { return nil }, ""},
+                {"configured", func() error { return InitLogger(LevelInfo, true, first) }, first},
+                {"after rotation", RotateLogFile, first},
+                {"reconfigured", func() error { return InitLogger(LevelInfo, true, second) }, second},
+                {"file logging disabled", func() error { return InitLogger(LevelInfo, false, "") }, ""},
+                {"closed", func() error { InitLogger(LevelInfo, true, first); CloseLogger(); return nil }, ""},
+        }
+        for _, tt := range tests {
+                if err := tt.change(); err != nil {
+                        t.Fatalf("%s: %v", tt.name, err)
+                }
+                if got := LogFilePath(); got != tt.want {
+                        t.Errorf("%s: LogFilePath() = %q, want %q", tt.name, got, tt.want)
+                }
+        }
+}
+
+func TestWithoutInit(t *testing.T) {
+        tests := []struct {
+                name string
+                log  func()
+                want []string // Empty for no output
+        }{
+                {name: "Info", log: func() { Info("hi") }, want: []string{"[INFO] ", "hi\n"}},
+                {name: "Warningf", log: func() { Warningf("disk %d%% full", 90) }, want: []string{"[WARN] ", "disk 90% full\n"}},
+                {name: "Error", log: func() { Error("failed") }, want: []string{"[ERROR] ", "failed\n"}},
+                {name: "Debug below the default level", log: func() { Debug("hidden") }},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        // Start from the defaults, with stdout as the console
+                        t.Cleanup(Reset)
+                        stdout := captureStdout(t)
+                        Reset()
+                        tt.log()
+                        got := stdout()
+                        if len(tt.want) == 0 && got != "" {
+                                t.Errorf("stdout %q, want nothing", got)
+                        }
+                        for _, want := range tt.want {
+                                if !strings.Contains(got, want) {
+                                        t.Errorf("stdout %q lacks %q", got, want)
+                                }
+                        }
+                })
+        }
+}
+
+func TestNoPanicBeforeInit(t *testing.T) {
+        tests := []struct {
+                name string
+                log  func()
+        }{
+                {"Debug", func() { Debug("m") }},
+                {"Info", func() { Info("m") }},
+                {"Infof", func() { Infof("%s", "m") }},
+                {"Warning", func() { Warning("m") }},
+                {"Error", func() { Error("m") }},
+                {"Fatal", func() { Fatal("m") }},
+                {"WithField", func() { WithField("k", "v").Info("m") }},
+                {"Named", func() { Named("db").Warning("m") }},
+                {"RotateLogFile", func() { RotateLogFile() }},
+                {"CloseLogger", func() { CloseLogger() }},
+                {"LogFilePath", func() { LogFilePath() }},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        stubExit(t)
+                        defer func() {
+                                if p := recover(); p != nil {
+                                        t.Errorf("panic before InitLogger: %v", p)
+                                }
+                        }()
+                        tt.log()
+                })
+        }
+}
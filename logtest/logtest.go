@@ -0,0 +1,100 @@
+// File: logtest.go
+// Description:
+// Package logtest provides helpers for tests that check what was logged
+// through the logger package.
+
+package logtest
+
+import (
+        "bufio"
+        "bytes"
+        "encoding/json"
+        "strings"
+        "sync"
+        "testing"
+
+        "github.com/tisoportes/logger"
+)
+
+// Recorder collects the records logged while a test runs
+type Recorder struct {
+        mu  sync.Mutex
+        buf bytes.Buffer
+}
+
+var (
+        // Recorders by test
+        recorders   = map[testing.TB]*Recorder{}
+        recordersMu sync.Mutex
+)
+
+// Capture starts recording the records logged during the test. The logger
+// is Reset when the test finishes, so capturing tests stay hermetic.
+func Capture(t testing.TB) *Recorder {
+        t.Helper()
+
+        r := &Recorder{}
+        logger.AddOutput(r, logger.FormatJSON)
+
+        recordersMu.Lock()
+        recorders[t] = r
+        recordersMu.Unlock()
+
+        t.Cleanup(func() {
+                recordersMu.Lock()
+                delete(recorders, t)
+                recordersMu.Unlock()
+                logger.Reset()
+        })
+        return r
+}
+
+// Write implements io.Writer
+func (r *Recorder) Write(p []byte) (int, error) {
+        r.mu.Lock()
+        defer r.mu.Unlock()
+        return r.buf.Write(p)
+}
+
+// Logged reports whether a record at the given level with a message
+// containing substring was recorded
+func (r *Recorder) Logged(level int, substring string) bool {
+        r.mu.Lock()
+        data := append([]byte(nil), r.buf.Bytes()...)
+        r.mu.Unlock()
+
+        name := logger.Level(level).String()
+        scanner := bufio.NewScanner(bytes.NewReader(data))
+        scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+        for scanner.Scan() {
+                var rec struct {
+                        Level   string `json:"level"`
+                        Message string `json:"message"`
+                }
+                if json.Unmarshal(scanner.Bytes(), &rec) != nil {
+                        continue
+                }
+                if rec.Level == name && strings.Contains(rec.Message, substring) {
+                        return true
+                }
+        }
+        return false
+}
+
+// AssertLogged fails the test unless a record at the given level with a
+// message containing substring was logged since Capture was called
+func AssertLogged(t testing.TB, level int, substring string) {
+        t.Helper()
+
+        recordersMu.Lock()
+        r := recorders[t]
+        recordersMu.Unlock()
+        if r == nil {
+                t.Fatalf("logtest: Capture must be called before AssertLogged")
+                return
+        }
+
+        if !r.Logged(level, substring) {
+                t.Errorf("logtest: no %s record containing %q was logged", logger.Level(level), substring)
+        }
+}
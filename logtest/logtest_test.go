@@ -0,0 +1,71 @@
+//go:build !logger_minimal
+
+package logtest_test
+
+import (
+        "errors"
+        "testing"
+
+        "github.com/tisoportes/logger"
+        "github.com/tisoportes/logger/logtest"
+)
+
+// saveOrder is code under test that logs its failures
+func saveOrder(id string) error {
+        err := errors.New("database unavailable")
+        logger.WithError(err).Errorf("failed to save order %s", id)
+        return err
+}
+
+// Asserting that the code under test logged an error
+func TestAssertLoggedError(t *testing.T) {
+        logtest.Capture(t)
+
+        if err := saveOrder("A-42"); err == nil {
+                t.Fatal("expected an error")
+        }
+
+        logtest.AssertLogged(t, logger.LevelError, "failed to save order A-42")
+}
+
+// recordingTB is a testing.TB that records failures instead of failing
+type recordingTB struct {
+        testing.TB
+        failed bool
+}
+
+func (r *recordingTB) Helper()                                   {}
+func (r *recordingTB) Errorf(format string, args ...interface{}) { r.failed = true }
+func (r *recordingTB) Fatalf(format string, args ...interface{}) { r.failed = true }
+
+func TestAssertLogged(t *testing.T) {
+        tests := []struct {
+                name      string
+                level     int
+                substring string
+                capture   bool
+                wantFail  bool
+        }{
+                {name: "matching record", level: logger.LevelWarning, substring: "disk almost full", capture: true},
+                {name: "partial message", level: logger.LevelWarning, substring: "almost", capture: true},
+                {name: "other level", level: logger.LevelError, substring: "disk almost full", capture: true, wantFail: true},
+                {name: "other message", level: logger.LevelWarning, substring: "disk full", capture: true, wantFail: true},
+                {name: "without Capture", level: logger.LevelWarning, substring: "disk almost full", wantFail: true},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        tb := &recordingTB{TB: t}
+                        if tt.capture {
+                                logtest.Capture(tb)
+                        } else {
+                                t.Cleanup(logger.Reset)
+                        }
+                        logger.Warning("disk almost full")
+
+                        logtest.AssertLogged(tb, tt.level, tt.substring)
+                        if tb.failed != tt.wantFail {
+                                t.Errorf("AssertLogged failed: %v, want %v", tb.failed, tt.wantFail)
+                        }
+                })
+        }
+}
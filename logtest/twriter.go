@@ -0,0 +1,66 @@
+// File: twriter.go
+// Description:
+// Routing of log output through testing.T, so logs are grouped under the
+// test that produced them and only shown when it fails (or with -v).
+
+package logtest
+
+import (
+        "bytes"
+        "io"
+        "sync"
+        "testing"
+)
+
+// tWriter sends each written line to t.Log
+type tWriter struct {
+        mu   sync.Mutex
+        t    testing.TB
+        buf  []byte
+        done bool
+}
+
+// TWriter returns a writer that logs each line through t.Log. Use it as
+// the console output:
+//
+//	logger.SetOutput(logtest.TWriter(t))
+//
+// Lines written after the test finished are discarded.
+func TWriter(t testing.TB) io.Writer {
+        w := &tWriter{t: t}
+        t.Cleanup(func() {
+                w.mu.Lock()
+                defer w.mu.Unlock()
+                w.flush()
+                w.done = true
+        })
+        return w
+}
+
+// Write implements io.Writer
+func (w *tWriter) Write(p []byte) (int, error) {
+        w.mu.Lock()
+        defer w.mu.Unlock()
+        if w.done {
+                return len(p), nil
+        }
+
+        w.buf = append(w.buf, p...)
+        for {
+                i := bytes.IndexByte(w.buf, '\n')
+                if i < 0 {
+                        break
+                }
+                w.t.Log(string(w.buf[:i]))
+                w.buf = w.buf[i+1:]
+        }
+        return len(p), nil
+}
+
+// flush logs a trailing partial line; w.mu must be held
+func (w *tWriter) flush() {
+        if len(w.buf) > 0 {
+                w.t.Log(string(w.buf))
+                w.buf = nil
+        }
+}
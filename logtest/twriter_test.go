@@ -0,0 +1,72 @@
+package logtest_test
+
+import (
+        "fmt"
+        "io"
+        "strings"
+        "testing"
+
+        "github.com/tisoportes/logger"
+        "github.com/tisoportes/logger/logtest"
+)
+
+// Routing the package logs through the test: the lines are shown under
+// this test, and only when it fails or with -v
+func TestTWriterUsage(t *testing.T) {
+        t.Cleanup(logger.Reset)
+        logger.SetOutput(logtest.TWriter(t))
+
+        logger.Info("shown under TestTWriterUsage")
+}
+
+// logTB is a testing.TB recording what is logged through it
+type logTB struct {
+        testing.TB
+        logged   []string
+        cleanups []func()
+}
+
+func (l *logTB) Log(args ...interface{}) { l.logged = append(l.logged, fmt.Sprint(args...)) }
+func (l *logTB) Cleanup(fn func())       { l.cleanups = append(l.cleanups, fn) }
+
+// finish runs the cleanups like the end of a test
+func (l *logTB) finish() {
+        for i := len(l.cleanups) - 1; i >= 0; i-- {
+                l.cleanups[i]()
+        }
+}
+
+func TestTWriter(t *testing.T) {
+        tests := []struct {
+                name   string
+                writes []string
+                after  string // Written after the test finished
+                want   []string
+        }{
+                {name: "one line per write", writes: []string{"a\n", "b\n"}, want: []string{"a", "b"}},
+                {name: "lines split across writes", writes: []string{"par", "tial\nnext\n"}, want: []string{"partial", "next"}},
+                {name: "several lines in one write", writes: []string{"a\nb\nc\n"}, want: []string{"a", "b", "c"}},
+                {name: "trailing partial line flushed", writes: []string{"a\nb"}, want: []string{"a", "b"}},
+                {name: "discarded after the test", writes: []string{"a\n"}, after: "late\n", want: []string{"a"}},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        tb := &logTB{TB: t}
+                        w := logtest.TWriter(tb)
+                        for _, s := range tt.writes {
+                                if _, err := io.WriteString(w, s); err != nil {
+                                        t.Fatal(err)
+                                }
+                        }
+                        tb.finish()
+                        if tt.after != "" {
+                                if n, err := io.WriteString(w, tt.after); n != len(tt.after) || err != nil {
+                                        t.Errorf("write after the test: %d, %v", n, err)
+                                }
+                        }
+                        if strings.Join(tb.logged, "|") != strings.Join(tt.want, "|") {
+                                t.Errorf("logged %q, want %q", tb.logged, tt.want)
+                        }
+                })
+        }
+}
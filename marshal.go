@@ -0,0 +1,65 @@
+// File: marshal.go
+// Description:
+// Rendering of field values. Times, durations and IP addresses are written
+// in their canonical forms (RFC 3339, "1.5s", dotted IP) by every format,
+// and applications can register marshalers for their own types.
+
+package logger
+
+import (
+        "net"
+        "reflect"
+        "sync"
+        "time"
+)
+
+var (
+        // Marshalers of field values by type
+        fieldMarshalers   = map[reflect.Type]func(interface{}) interface{}{}
+        fieldMarshalersMu sync.RWMutex
+)
+
+// RegisterFieldMarshaler makes field values of type t be rendered as the
+// value fn returns for them, e.g. to log a struct through a few of its
+// members or to hide parts of it. Registering nil removes the marshaler.
+func RegisterFieldMarshaler(t reflect.Type, fn func(interface{}) interface{}) {
+        fieldMarshalersMu.Lock()
+        defer fieldMarshalersMu.Unlock()
+        if fn == nil {
+                delete(fieldMarshalers, t)
+                return
+        }
+        fieldMarshalers[t] = fn
+}
+
+// clearFieldMarshalers removes every registered marshaler
+func clearFieldMarshalers() {
+        fieldMarshalersMu.Lock()
+        defer fieldMarshalersMu.Unlock()
+        fieldMarshalers = map[reflect.Type]func(interface{}) interface{}{}
+}
+
+// marshalField returns the value to render for a field value: the result
+// of its registered marshaler, or its canonical form for times, durations
+// and IP addresses
+func marshalField(value interface{}) interface{} {
+        if value == nil {
+                return nil
+        }
+        fieldMarshalersMu.RLock()
+        fn := fieldMarshalers[reflect.TypeOf(value)]
+        fieldMarshalersMu.RUnlock()
+        if fn != nil {
+                return fn(value)
+        }
+
+        switch v := value.(type) {
+        case time.Time:
+                return v.Format(time.RFC3339Nano)
+        case time.Duration:
+                return v.String()
+        case net.IP:
+                return v.String()
+        }
+        return value
+}
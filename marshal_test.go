@@ -0,0 +1,82 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "fmt"
+        "net"
+        "reflect"
+        "strings"
+        "testing"
+        "time"
+)
+
+// point is a custom field type rendered by a registered marshaler
+type point struct{ X, Y int }
+
+// registration is a call of RegisterFieldMarshaler
+type registration struct {
+        typ reflect.Type
+        fn  func(interface{}) interface{}
+}
+
+func TestFieldMarshaling(t *testing.T) {
+        pointMarshaler := func(v interface{}) interface{} {
+                p := v.(point)
+                return fmt.Sprintf("%d,%d", p.X, p.Y)
+        }
+        msMarshaler := func(v interface{}) interface{} {
+                return v.(time.Duration).Milliseconds()
+        }
+        tests := []struct {
+                name     string
+                format   int
+                value    interface{}
+                register []registration // In order
+                want     string
+        }{
+                {name: "duration text", format: FormatText, value: 1500 * time.Millisecond, want: "v=1.5s"},
+                {name: "duration JSON", format: FormatJSON, value: 1500 * time.Millisecond, want: `"v":"1.5s"`},
+                {name: "duration logfmt", format: FormatLogfmt, value: 1500 * time.Millisecond, want: "v=1.5s"},
+                {name: "time", format: FormatJSON, value: time.Date(2023, 3, 8, 10, 0, 0, 0, time.UTC), want: `"v":"2023-03-08T10:00:00Z"`},
+                {name: "IP", format: FormatJSON, value: net.IPv4(10, 0, 0, 1), want: `"v":"10.0.0.1"`},
+                {
+                        name:     "custom type",
+                        format:   FormatJSON,
+                        value:    point{3, 4},
+                        register: []registration{{reflect.TypeOf(point{}), pointMarshaler}},
+                        want:     `"v":"3,4"`,
+                },
+                {name: "custom type unregistered", format: FormatJSON, value: point{3, 4}, want: `"v":{"X":3,"Y":4}`},
+                {
+                        name:     "builtin overridden",
+                        format:   FormatJSON,
+                        value:    1500 * time.Millisecond,
+                        register: []registration{{reflect.TypeOf(time.Duration(0)), msMarshaler}},
+                        want:     `"v":1500`,
+                },
+                {
+                        name:   "marshaler removed",
+                        format: FormatJSON,
+                        value:  point{3, 4},
+                        register: []registration{
+                                {reflect.TypeOf(point{}), pointMarshaler},
+                                {reflect.TypeOf(point{}), nil},
+                        },
+                        want: `"v":{"X":3,"Y":4}`,
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetFormat(tt.format)
+                        for _, r := range tt.register {
+                                RegisterFieldMarshaler(r.typ, r.fn)
+                        }
+                        WithField("v", tt.value).Info("value")
+                        if got := out.String(); !strings.Contains(got, tt.want) {
+                                t.Errorf("got %q, want %q", got, tt.want)
+                        }
+                })
+        }
+}
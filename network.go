@@ -0,0 +1,147 @@
+// File: network.go
+// Description:
+// Network outputs. A network output keeps a connection to a log collector
+// and transparently reconnects after failures; records written while the
+// collector is unreachable are dropped and reported as write errors.
+// Every write is bounded by the network timeout so a stalled collector
+// cannot block logging.
+
+package logger
+
+import (
+        "errors"
+        "io"
+        "net"
+        "sync"
+        "sync/atomic"
+        "time"
+)
+
+// reconnectDelay is the minimum time between two connection attempts
+const reconnectDelay = time.Second
+
+// Deadline of network connects and writes (accessed atomically)
+var networkTimeout = int64(10 * time.Second)
+
+// SetNetworkTimeout bounds every connect and write of the network and
+// webhook outputs (10s by default). A write that times out is abandoned,
+// counted as dropped and the connection re-established; zero disables the
+// deadline.
+func SetNetworkTimeout(d time.Duration) {
+        atomic.StoreInt64(&networkTimeout, int64(d))
+}
+
+// NetworkTimeout returns the deadline of network connects and writes
+func NetworkTimeout() time.Duration {
+        return time.Duration(atomic.LoadInt64(&networkTimeout))
+}
+
+// writeWithTimeout writes p to conn, abandoning the write once timeout has
+// elapsed; zero or less waits indefinitely. The deadline is on the machine
+// clock, as the network doesn't follow the package clock.
+func writeWithTimeout(conn net.Conn, p []byte, timeout time.Duration) (int, error) {
+        var deadline time.Time
+        if timeout > 0 {
+                deadline = time.Now().Add(timeout)
+        }
+        conn.SetWriteDeadline(deadline)
+        return conn.Write(p)
+}
+
+// netOutput writes records to a (re)connecting network connection
+type netOutput struct {
+        mu          sync.Mutex
+        dial        func() (net.Conn, error)
+        conn        net.Conn
+        lastAttempt time.Time // On the machine clock, like the network timeouts
+        closed      bool
+}
+
+// newNetOutput connects using dial and returns the output
+func newNetOutput(dial func() (net.Conn, error)) (*netOutput, error) {
+        conn, err := dial()
+        if err != nil {
+                return nil, err
+        }
+        return &netOutput{dial: dial, conn: conn, lastAttempt: time.Now()}, nil
+}
+
+// Write sends one record, reconnecting first if the connection was lost
+func (n *netOutput) Write(p []byte) (int, error) {
+        n.mu.Lock()
+        defer n.mu.Unlock()
+        if n.closed {
+                return len(p), nil
+        }
+
+        if n.conn == nil {
+                if time.Since(n.lastAttempt) < reconnectDelay {
+                        return 0, errNotConnected
+                }
+                n.lastAttempt = time.Now()
+                conn, err := n.dial()
+                if err != nil {
+                        return 0, err
+                }
+                n.conn = conn
+        }
+
+        written, err := writeWithTimeout(n.conn, p, NetworkTimeout())
+        if err != nil {
+                var netErr net.Error
+                if errors.As(err, &netErr) && netErr.Timeout() {
+                        atomic.AddUint64(&statNetworkDropped, 1)
+                }
+
+                // Drop the connection; the next write reconnects
+                n.conn.Close()
+                n.conn = nil
+        }
+        return written, err
+}
+
+// Close closes the connection; later writes are discarded
+func (n *netOutput) Close() error {
+        n.mu.Lock()
+        defer n.mu.Unlock()
+        n.closed = true
+        if n.conn == nil {
+                return nil
+        }
+        err := n.conn.Close()
+        n.conn = nil
+        return err
+}
+
+// errNotConnected is returned while waiting to reconnect
+var errNotConnected = errors.New("not connected to log collector")
+
+// AddUnixSocketOutput adds an output sending JSON records to the Unix domain
+// socket at path, as datagrams (unixgram) or, if the socket is stream
+// oriented, over a unix connection. The connection is re-established after
+// failures. Closing the returned io.Closer removes the output and closes
+// the connection.
+func AddUnixSocketOutput(path string) (io.Closer, error) {
+        network := "unixgram"
+        if conn, err := net.Dial(network, path); err == nil {
+                conn.Close()
+        } else {
+                network = "unix"
+        }
+
+        out, err := newNetOutput(func() (net.Conn, error) {
+                return net.DialTimeout(network, path, NetworkTimeout())
+        })
+        if err != nil {
+                return nil, err
+        }
+        return outputCloser(AddOutput(out, FormatJSON)), nil
+}
+
+// outputCloser removes the output with its id when closed
+type outputCloser int
+
+// Close removes the output, see RemoveOutput
+func (id outputCloser) Close() error {
+        return RemoveOutput(int(id))
+}
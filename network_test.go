@@ -0,0 +1,224 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "bufio"
+        "errors"
+        "net"
+        "os"
+        "path/filepath"
+        "runtime"
+        "strings"
+        "testing"
+        "time"
+)
+
+// unixCollector receives the records sent to a Unix socket
+type unixCollector struct {
+        lines chan string
+        close func()
+}
+
+// listenUnix starts a collector on a socket of the given network
+func listenUnix(t *testing.T, network, path string) *unixCollector {
+        t.Helper()
+        c := &unixCollector{lines: make(chan string, 100)}
+        if network == "unixgram" {
+                conn, err := net.ListenPacket(network, path)
+                if err != nil {
+                        t.Fatal(err)
+                }
+                go func() {
+                        buf := make([]byte, 64*1024)
+                        for {
+                                n, _, err := conn.ReadFrom(buf)
+                                if err != nil {
+                                        return
+                                }
+                                c.lines <- strings.TrimSpace(string(buf[:n]))
+                        }
+                }()
+                c.close = func() { conn.Close() }
+        } else {
+                l, err := net.Listen(network, path)
+                if err != nil {
+                        t.Fatal(err)
+                }
+                go func() {
+                        for {
+                                conn, err := l.Accept()
+                                if err != nil {
+                                        return
+                                }
+                                go func() {
+                                        scanner := bufio.NewScanner(conn)
+                                        for scanner.Scan() {
+                                                c.lines <- scanner.Text()
+                                        }
+                                }()
+                        }
+                }()
+                c.close = func() { l.Close() }
+        }
+        t.Cleanup(c.close)
+        return c
+}
+
+// expect waits for a record containing s
+func (c *unixCollector) expect(t *testing.T, s string) {
+        t.Helper()
+        timeout := time.After(5 * time.Second)
+        for {
+                select {
+                case line := <-c.lines:
+                        if strings.Contains(line, s) {
+                                return
+                        }
+                case <-timeout:
+                        t.Fatalf("no record containing %q delivered", s)
+                }
+        }
+}
+
+// expectNothing checks that no record arrives for a short while
+func (c *unixCollector) expectNothing(t *testing.T) {
+        t.Helper()
+        select {
+        case line := <-c.lines:
+                t.Errorf("unexpected record %q", line)
+        case <-time.After(50 * time.Millisecond):
+        }
+}
+
+func TestUnixSocketOutput(t *testing.T) {
+        switch runtime.GOOS {
+        case "windows", "plan9", "js", "wasip1":
+                t.Skip("no Unix domain sockets")
+        }
+        tests := []struct {
+                network string
+                restart bool // Restart the collector between two records
+        }{
+                {network: "unixgram"},
+                {network: "unix"},
+                {network: "unixgram", restart: true},
+        }
+        for _, tt := range tests {
+                name := tt.network
+                if tt.restart {
+                        name += " reconnect"
+                }
+                t.Run(name, func(t *testing.T) {
+                        captureOutput(t)
+                        clock := useFakeClock(t)
+                        path := filepath.Join(t.TempDir(), "log.sock")
+                        collector := listenUnix(t, tt.network, path)
+                        closer, err := AddUnixSocketOutput(path)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        Info("first record")
+                        collector.expect(t, `"message":"first record"`)
+
+                        if tt.restart {
+                                collector.close()
+                                os.Remove(path)     // Datagram sockets leave the file behind
+                                Info("lost record") // Fails and drops the connection
+                                collector = listenUnix(t, tt.network, path)
+                                clock.Advance(reconnectDelay) // Not enough, reconnecting goes by the machine clock
+                                Info("before the reconnect delay")
+                                collector.expectNothing(t)
+                                time.Sleep(reconnectDelay)
+                                Info("after restart")
+                                collector.expect(t, "after restart")
+                        }
+
+                        if err := closer.Close(); err != nil {
+                                t.Fatal(err)
+                        }
+                        if outputs := Outputs(); len(outputs) != 0 {
+                                t.Errorf("outputs %v left after closing", outputs)
+                        }
+                        Info("after close")
+                        collector.expectNothing(t)
+                })
+        }
+}
+
+func TestNetworkWriteTimeout(t *testing.T) {
+        tests := []struct {
+                name        string
+                payload     int // Bytes written at once
+                wantTimeout bool
+        }{
+                {name: "responsive collector", payload: 100},
+                {name: "stalled collector", payload: 64 << 20, wantTimeout: true},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        clock := useFakeClock(t)
+                        SetNetworkTimeout(100 * time.Millisecond)
+
+                        // A collector that accepts connections but never reads
+                        l, err := net.Listen("tcp", "127.0.0.1:0")
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        defer l.Close()
+                        accepted := make(chan net.Conn, 1)
+                        go func() {
+                                if conn, err := l.Accept(); err == nil {
+                                        accepted <- conn
+                                }
+                        }()
+                        out, err := newNetOutput(func() (net.Conn, error) {
+                                return net.Dial("tcp", l.Addr().String())
+                        })
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        defer out.Close()
+                        defer func() {
+                                select {
+                                case conn := <-accepted:
+                                        conn.Close()
+                                default:
+                                }
+                        }()
+
+                        done := make(chan error, 1)
+                        go func() {
+                                _, err := out.Write(make([]byte, tt.payload))
+                                done <- err
+                        }()
+                        clock.Advance(time.Hour) // The deadline is on the machine clock
+                        var writeErr error
+                        select {
+                        case writeErr = <-done:
+                        case <-time.After(5 * time.Second):
+                                t.Fatal("write hung")
+                        }
+
+                        var netErr net.Error
+                        timedOut := errors.As(writeErr, &netErr) && netErr.Timeout()
+                        if timedOut != tt.wantTimeout {
+                                t.Fatalf("write error %v, want timeout: %v", writeErr, tt.wantTimeout)
+                        }
+                        wantDropped := uint64(0)
+                        if tt.wantTimeout {
+                                wantDropped = 1
+                        }
+                        if stats := GetStats(); stats.NetworkDropped != wantDropped || stats.DroppedRecords != 0 {
+                                t.Errorf("NetworkDropped = %d, DroppedRecords = %d; want %d, 0", stats.NetworkDropped, stats.DroppedRecords, wantDropped)
+                        }
+                        out.mu.Lock()
+                        connected := out.conn != nil
+                        out.mu.Unlock()
+                        if connected == tt.wantTimeout {
+                                t.Errorf("connection kept: %v after a timeout: %v", connected, tt.wantTimeout)
+                        }
+                })
+        }
+}
@@ -0,0 +1,186 @@
+// File: options.go
+// Description:
+// Struct based configuration. Options gathers the settings otherwise made
+// through InitLogger and the individual Set* functions, so a full setup can
+// be written down in one self-documenting value.
+
+package logger
+
+import "time"
+
+// Options configures the logger as a whole. The zero value logs debug and
+// above as text to stdout only; every setting is applied, so fields left
+// empty reset the corresponding feature to its default.
+type Options struct {
+        Level int    // Minimum level logged
+        File  string // Log file path, empty to log to stdout only
+
+        LazyFile bool // Create the log file on the first write
+
+        ConsoleFormat int // Format of stdout (FormatText, FormatJSON or a registered encoder)
+        FileFormat    int // Format of the log file
+        Color         int // Colors of FormatPretty: ColorAuto, ColorAlways or ColorNever
+
+        // Rotation
+        MaxBackups       int            // Rotated files kept, 0 keeps all
+        NumberedRotation bool           // Rotate to app.log.1, app.log.2, ...
+        RotateName       RotateNameFunc // Naming of rotated files, nil for the default
+        CurrentSymlink   bool           // Maintain a <name>-current<ext> symlink
+        CompactOnRotate  bool           // Collapse duplicate JSON records when rotating
+        MaxFileSize      int64          // Rotate above this size in bytes, 0 disables
+        RotateInterval   time.Duration  // Rotate at this interval, 0 disables
+
+        // Record content
+        IncludeSequence    bool     // Add a "seq" field
+        IncludeFingerprint bool     // Add a "fingerprint" field
+        IncludeHostname    bool     // Add a "host" field
+        Hostname           string   // Host name override for the "host" field
+        RedactFields       []string // Field names whose values are hidden
+        MaxMessageLength   int      // Truncate messages, 0 for unlimited
+        BinaryFormat       int      // BinaryHex or BinaryBase64
+
+        // Behavior
+        ErrorsToStderr   bool            // Mirror error and fatal records to stderr
+        StrictFormat     bool            // Report bad format strings
+        FatalExitCode    int             // Exit status of Fatal, 0 means 1
+        MaxWriteFailures int             // Disable outputs after repeated failures, 0 never
+        ErrorHook        func(err error) // Called with internal logging errors
+}
+
+// InitWithOptions initializes the logging system from opts
+func InitWithOptions(opts Options) error {
+        SetFormat(opts.ConsoleFormat)
+        SetFileFormat(opts.FileFormat)
+        SetColor(opts.Color)
+
+        SetLazyFile(opts.LazyFile)
+        SetMaxBackups(opts.MaxBackups)
+        SetNumberedRotation(opts.NumberedRotation)
+        SetRotateNameFunc(opts.RotateName)
+        SetCompactOnRotate(opts.CompactOnRotate)
+        SetMaxFileSize(opts.MaxFileSize)
+        SetRotateInterval(opts.RotateInterval)
+
+        SetIncludeSequence(opts.IncludeSequence)
+        SetIncludeFingerprint(opts.IncludeFingerprint)
+        SetHostname(opts.Hostname)
+        SetIncludeHostname(opts.IncludeHostname)
+        clearRedactedFields()
+        RedactFields(opts.RedactFields...)
+        SetMaxMessageLength(opts.MaxMessageLength)
+        SetBinaryFormat(opts.BinaryFormat)
+
+        SetErrorsToStderr(opts.ErrorsToStderr)
+        SetStrictFormat(opts.StrictFormat)
+        if opts.FatalExitCode == 0 {
+                opts.FatalExitCode = 1
+        }
+        SetFatalExitCode(opts.FatalExitCode)
+        SetMaxWriteFailures(opts.MaxWriteFailures)
+        SetErrorHook(opts.ErrorHook)
+
+        if err := InitLogger(opts.Level, opts.File != "", opts.File); err != nil {
+                return err
+        }
+
+        // The symlink needs the log file to be open
+        SetCurrentSymlink(opts.CurrentSymlink)
+        return nil
+}
+
+// Option configures the logger created by New
+type Option func(opts *Options)
+
+// Logger is the handle returned by New. It is the same type as Entry, so
+// fields can be attached to it with WithField and WithFields.
+type Logger = Entry
+
+// New initializes the logging system from functional options applied on
+// top of the defaults (info level, text to stdout only) and returns a
+// handle to log through
+func New(opts ...Option) (*Logger, error) {
+        options := Options{Level: LevelInfo}
+        for _, opt := range opts {
+                opt(&options)
+        }
+        if err := InitWithOptions(options); err != nil {
+                return nil, err
+        }
+        return &Logger{}, nil
+}
+
+// WithLevel sets the minimum level logged
+func WithLevel(level int) Option {
+        return func(opts *Options) {
+                opts.Level = level
+        }
+}
+
+// WithFile logs to the given file in addition to stdout
+func WithFile(path string) Option {
+        return func(opts *Options) {
+                opts.File = path
+        }
+}
+
+// WithFormat sets the format of stdout and the log file
+func WithFormat(format int) Option {
+        return func(opts *Options) {
+                opts.ConsoleFormat = format
+                opts.FileFormat = format
+        }
+}
+
+// WithFileFormat sets the format of the log file only
+func WithFileFormat(format int) Option {
+        return func(opts *Options) {
+                opts.FileFormat = format
+        }
+}
+
+// WithColor sets the colors of FormatPretty: ColorAuto, ColorAlways or
+// ColorNever
+func WithColor(mode int) Option {
+        return func(opts *Options) {
+                opts.Color = mode
+        }
+}
+
+// WithRotation keeps at most maxBackups rotated files (0 keeps all), named
+// logrotate style (app.log.1, app.log.2, ...) when numbered is true
+func WithRotation(maxBackups int, numbered bool) Option {
+        return func(opts *Options) {
+                opts.MaxBackups = maxBackups
+                opts.NumberedRotation = numbered
+        }
+}
+
+// WithCurrentSymlink maintains a <name>-current<ext> symlink to the log file
+func WithCurrentSymlink() Option {
+        return func(opts *Options) {
+                opts.CurrentSymlink = true
+        }
+}
+
+// WithRedactedFields hides the values of the given field names
+func WithRedactedFields(keys ...string) Option {
+        return func(opts *Options) {
+                opts.RedactFields = append(opts.RedactFields, keys...)
+        }
+}
+
+// WithHostname adds a "host" field to every record; an empty name uses
+// the one reported by the operating system
+func WithHostname(name string) Option {
+        return func(opts *Options) {
+                opts.IncludeHostname = true
+                opts.Hostname = name
+        }
+}
+
+// WithErrorHook registers a function called with internal logging errors
+func WithErrorHook(fn func(err error)) Option {
+        return func(opts *Options) {
+                opts.ErrorHook = fn
+        }
+}
@@ -0,0 +1,152 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "errors"
+        "strings"
+        "testing"
+)
+
+func TestInitWithOptions(t *testing.T) {
+        console := captureOutput(t)
+        exitCodes := stubExit(t)
+        path := tempLogPath(t, "app.log")
+        err := InitWithOptions(Options{
+                Level:           LevelWarning,
+                File:            path,
+                ConsoleFormat:   FormatText,
+                FileFormat:      FormatJSON,
+                RedactFields:    []string{"password"},
+                IncludeSequence: true,
+                Hostname:        "web-1",
+                IncludeHostname: true,
+                FatalExitCode:   5,
+        })
+        if err != nil {
+                t.Fatal(err)
+        }
+        SetOutput(console) // InitWithOptions keeps the console writer
+        Info("below the level")
+        WithField("password", "hunter2").Warning("login failed")
+        Fatal("giving up")
+
+        var file syncBuffer
+        file.Write([]byte(readLog(t, path)))
+        tests := []struct {
+                name string
+                ok   bool
+        }{
+                {"level", !strings.Contains(console.String(), "below the level") && len(file.Lines()) == 2},
+                {"console format", strings.HasPrefix(console.String(), "[WARN] ")},
+                {"file format", strings.HasPrefix(file.String(), "{")},
+                {"redacted fields", !strings.Contains(console.String()+file.String(), "hunter2")},
+                {"sequence", file.Records(t)[1][SequenceKey] == float64(2)},
+                {"hostname", file.Records(t)[0][HostKey] == "web-1"},
+                {"fatal exit code", len(exitCodes()) == 1 && exitCodes()[0] == 5},
+                {"log file path", LogFilePath() == path},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        if !tt.ok {
+                                t.Errorf("option not applied; console %q, file %q", console.String(), file.String())
+                        }
+                })
+        }
+}
+
+func TestOptionsColor(t *testing.T) {
+        tests := []struct {
+                name      string
+                color     int
+                terminal  bool
+                wantColor bool
+                wantJSON  bool
+        }{
+                {name: "auto on a terminal", color: ColorAuto, terminal: true, wantColor: true},
+                {name: "auto elsewhere", color: ColorAuto, wantJSON: true},
+                {name: "always elsewhere", color: ColorAlways, wantColor: true},
+                {name: "never on a terminal", color: ColorNever, terminal: true},
+                {name: "never elsewhere", color: ColorNever, wantJSON: true},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        forceTerminal(t, tt.terminal)
+                        stdout := captureStdout(t)
+                        err := InitWithOptions(Options{Level: LevelInfo, ConsoleFormat: FormatPretty, Color: tt.color})
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        SetOutput(nil) // The redirected stdout
+                        Info("hello")
+
+                        got := stdout()
+                        if strings.Contains(got, "\x1b[") != tt.wantColor {
+                                t.Errorf("output %q, want colors: %v", got, tt.wantColor)
+                        }
+                        if strings.HasPrefix(got, "{") != tt.wantJSON {
+                                t.Errorf("output %q, want JSON: %v", got, tt.wantJSON)
+                        }
+                        if !strings.Contains(got, "hello") {
+                                t.Errorf("output %q lacks the message", got)
+                        }
+                })
+        }
+}
+
+func TestNew(t *testing.T) {
+        captureOutput(t)
+        path := tempLogPath(t, "app.log")
+        hooked := false
+        log, err := New(
+                WithLevel(LevelDebug),
+                WithFile(path),
+                WithFormat(FormatJSON),
+                WithFileFormat(FormatLogfmt),
+                WithColor(ColorNever),
+                WithRotation(3, true),
+                WithRedactedFields("token"),
+                WithHostname("web-1"),
+                WithErrorHook(func(error) { hooked = true }),
+        )
+        if err != nil {
+                t.Fatal(err)
+        }
+        if log == nil {
+                t.Fatal("New returned no logger")
+        }
+
+        tests := []struct {
+                name string
+                ok   func() bool
+        }{
+                {"WithLevel", func() bool { return GetLevel() == LevelDebug }},
+                {"WithFile", func() bool { return LogFilePath() == path }},
+                {"WithFormat", func() bool { return consoleFormat == FormatJSON }},
+                {"WithFileFormat", func() bool { return fileFormat == FormatLogfmt }},
+                {"WithColor", func() bool { return colorMode == ColorNever }},
+                {"WithRotation", func() bool { return backupLimit() == 3 && isNumberedRotation() }},
+                {"WithRedactedFields", func() bool { return isRedacted("token") }},
+                {"WithHostname", func() bool { return includeHostname && hostname == "web-1" }},
+                {"WithErrorHook", func() bool { reportError(errors.New("test")); return hooked }},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        if !tt.ok() {
+                                t.Error("option not applied")
+                        }
+                })
+        }
+}
+
+func TestNewDefaults(t *testing.T) {
+        captureOutput(t)
+        SetLevel(LevelError)
+        if _, err := New(); err != nil {
+                t.Fatal(err)
+        }
+        if GetLevel() != LevelInfo || LogFilePath() != "" || consoleFormat != FormatText {
+                t.Errorf("New without options: level %d, file %q, format %d", GetLevel(), LogFilePath(), consoleFormat)
+        }
+}
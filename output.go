@@ -0,0 +1,499 @@
+// File: output.go
+// Description:
+// Output management. Besides stdout and the main log file, additional
+// outputs (such as sharded files) can be registered, each with its own
+// format; they receive every record and take part in rotation and close.
+
+package logger
+
+import (
+        "errors"
+        "fmt"
+        "io"
+        "os"
+        "path/filepath"
+        "sync"
+        "sync/atomic"
+        "time"
+)
+
+// output is an additional destination with its own format
+type output struct {
+        id       int
+        w        io.Writer
+        format   int
+        minLevel int // Records below this level are not sent to w
+        failures int // Consecutive failed writes
+}
+
+var (
+        // Console output, stdout unless replaced with SetOutput
+        consoleWriter io.Writer = os.Stdout
+
+        // Formats of stdout and the log file
+        consoleFormat = FormatText
+        fileFormat    = FormatText
+
+        // Also write error and fatal records to stderr
+        errorsToStderr bool
+
+        // Outputs registered in addition to stdout and the log file
+        extraOutputs []*output
+
+        // Identifier of the last output added
+        lastOutputID int
+
+        // Create the log file on the first write rather than in InitLogger
+        lazyFile bool
+
+        // Log file to create on the first write (lazy mode)
+        pendingLogFile string
+
+        // Consecutive failed writes to the log file
+        logFileFailures int
+
+        // Consecutive failures after which an output is disabled (0 never)
+        maxWriteFailures int
+
+        // Guards logFile, the formats and extraOutputs, and serializes writes
+        outputsMu sync.Mutex
+)
+
+// SetOutput replaces stdout as the console output, e.g. to route logs
+// through a test's log. Passing nil restores stdout.
+func SetOutput(w io.Writer) {
+        if w == nil {
+                w = os.Stdout
+        }
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        consoleWriter = w
+}
+
+// SetFormat sets the format of stdout and the log file (FormatText, FormatJSON
+// or a format returned by RegisterEncoder)
+func SetFormat(format int) {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        consoleFormat = format
+        fileFormat = format
+}
+
+// SetFileFormat sets the format of the log file only, e.g. to keep human
+// readable text on the console while writing JSON to the file
+func SetFileFormat(format int) {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        fileFormat = format
+}
+
+// SetErrorsToStderr makes error and fatal records also go to stderr, in the
+// console format, so they surface in container error streams even when
+// stdout is piped somewhere quiet
+func SetErrorsToStderr(enabled bool) {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        errorsToStderr = enabled
+}
+
+// SetMaxWriteFailures disables the log file or an additional output after
+// n consecutive failed writes; the error hook is told when that happens.
+// Zero (the default) keeps failing outputs enabled.
+func SetMaxWriteFailures(n int) {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        maxWriteFailures = n
+}
+
+// SetLazyFile defers creating the log file (and its directory) until the
+// first record is written, so services that rarely log don't leave empty
+// files behind. It applies to the next InitLogger call.
+func SetLazyFile(enabled bool) {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        lazyFile = enabled
+}
+
+// OutputInfo describes an output registered in addition to stdout and the
+// log file
+type OutputInfo struct {
+        ID       int    // Identifier to pass to RemoveOutput
+        Type     string // Go type of the writer, e.g. "*os.File"
+        Format   int    // Format the records are written in
+        MinLevel int    // Records below this level are not written
+}
+
+// AddOutput adds a destination that receives every record in the given
+// format, independently of the format used by the other outputs. If w
+// implements io.Closer it is closed by CloseLogger. The returned id
+// identifies the output in Outputs and RemoveOutput.
+func AddOutput(w io.Writer, format int) int {
+        return addOutput(&output{w: w, format: format})
+}
+
+// addOutput registers an additional output and returns its id
+func addOutput(o *output) int {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        lastOutputID++
+        o.id = lastOutputID
+        extraOutputs = append(extraOutputs, o)
+        return o.id
+}
+
+// Outputs lists the outputs registered in addition to stdout and the log
+// file, in the order they were added
+func Outputs() []OutputInfo {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        infos := make([]OutputInfo, 0, len(extraOutputs))
+        for _, o := range extraOutputs {
+                infos = append(infos, OutputInfo{
+                        ID:       o.id,
+                        Type:     fmt.Sprintf("%T", o.w),
+                        Format:   o.format,
+                        MinLevel: o.minLevel,
+                })
+        }
+        return infos
+}
+
+// RemoveOutput stops writing to the output with the given id and closes it
+// if it implements io.Closer
+func RemoveOutput(id int) error {
+        outputsMu.Lock()
+        var removed *output
+        for i, o := range extraOutputs {
+                if o.id == id {
+                        removed = o
+                        extraOutputs = append(extraOutputs[:i:i], extraOutputs[i+1:]...)
+                        break
+                }
+        }
+        outputsMu.Unlock()
+
+        if removed == nil {
+                return fmt.Errorf("no output with id %d", id)
+        }
+        if c, ok := removed.w.(io.Closer); ok {
+                return c.Close()
+        }
+        return nil
+}
+
+// writeRecord writes the record to every output and reports write errors.
+// The rate limit, sequence number, event id and counters apply here rather
+// than when the record is built, so buffered records that are discarded
+// leave no gaps and flushed ones are numbered in the order written.
+func writeRecord(rec *Record) {
+        if holdIfPaused(rec) {
+                return
+        }
+        if !allowedByRate(rec.Level) {
+                countDropped()
+                return
+        }
+        start := time.Now()
+        rec.Fields = withEventID(withSequence(rec.Fields))
+
+        openPendingLogFile()
+        for _, err := range writeOutputs(rec) {
+                reportError(err)
+        }
+        announceFailover()
+        crashDump(rec)
+        countError(rec)
+        publish(rec)
+        validateRecord(rec)
+        autoRotate(rec.Time)
+        countRecord(start)
+}
+
+// writeRecordTo encodes rec in format and writes it to w, bypassing the
+// encoding shared by the outputs; outputsMu must be held
+func writeRecordTo(w io.Writer, format int, rec *Record) error {
+        line, err := encoderFor(format).Encode(*rec)
+        if err != nil {
+                return err
+        }
+        n, err := w.Write(line)
+        countBytes(n)
+        return err
+}
+
+// openPendingLogFile creates the log file in lazy mode on the first write
+func openPendingLogFile() {
+        outputsMu.Lock()
+        if pendingLogFile == "" {
+                outputsMu.Unlock()
+                return
+        }
+        file, err := openLogFile(pendingLogFile)
+        if err == nil {
+                attachLogFile(file)
+                pendingLogFile = ""
+        }
+        outputsMu.Unlock()
+
+        if err != nil {
+                reportError(err)
+                return
+        }
+        updateCurrentSymlink()
+}
+
+// writeOutputs encodes the record once per format in use and writes it to
+// stdout, the log file and every additional output. A failed write falls
+// back to stderr so the record is not lost silently; the errors are
+// returned to be reported once the outputs are unlocked.
+func writeOutputs(rec *Record) []error {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+
+        var errs []error
+        encoded := make([][]byte, len(encoders))
+        encodeErrs := make([]error, len(encoders))
+        write := func(w io.Writer, format int) (int, error) {
+                if format < 0 || format >= len(encoders) {
+                        format = FormatText
+                }
+                format = outputFormat(w, format)
+                if encoded[format] == nil && encodeErrs[format] == nil {
+                        encoded[format], encodeErrs[format] = encoderFor(format).Encode(*rec)
+                }
+                if encodeErrs[format] != nil {
+                        return 0, encodeErrs[format]
+                }
+                n, err := w.Write(encoded[format])
+                countBytes(n)
+                if err != nil && w != os.Stderr {
+                        os.Stderr.Write(encoded[format])
+                }
+                return n, err
+        }
+
+        clearStatus()
+        if consoleWriter == os.Stdout {
+                guardBrokenPipe()
+        }
+        console := consoleWriter
+        if width := consoleWidth(); width > 0 {
+                console = wrapWriter{w: consoleWriter, width: width}
+        }
+        var err error
+        if collapsed := collapsedRecord(rec); collapsed != nil {
+                err = writeRecordTo(console, consoleFormat, collapsed)
+        } else {
+                _, err = write(console, consoleFormat)
+        }
+        if err != nil {
+                errs = append(errs, fmt.Errorf("failed to write to console: %v", err))
+                if isBrokenPipe(err) {
+                        dropConsole()
+                        errs = append(errs, errors.New("disabled console output: reader went away"))
+                }
+        }
+        alert(rec.Level)
+        if errorsToStderr && rec.Level >= LevelError {
+                write(os.Stderr, consoleFormat)
+        }
+
+        if logFile != nil {
+                n, err := write(bufferedFileWriter{rec.Level}, fileFormat)
+                logFileSize += int64(n)
+                scheduleGzipFlush()
+                if err != nil {
+                        errs = append(errs, fmt.Errorf("failed to write to log file: %v", err))
+                        if switched, ferr := startFailover(); switched {
+                                // Write the record again, to the failover file
+                                n, err = write(bufferedFileWriter{rec.Level}, fileFormat)
+                                logFileSize += int64(n)
+                        } else if ferr != nil {
+                                errs = append(errs, ferr)
+                        }
+                }
+                if err != nil {
+                        logFileFailures++
+                        if maxWriteFailures > 0 && logFileFailures >= maxWriteFailures {
+                                errs = append(errs, fmt.Errorf("disabled log file %s after %d failed writes", logFile.Name(), logFileFailures))
+                                detachLogFile().Close()
+                                logFileFailures = 0
+                        }
+                } else {
+                        logFileFailures = 0
+                        noteFileWrite()
+                        if err := syncAfterWrite(rec.Level); err != nil {
+                                errs = append(errs, fmt.Errorf("failed to sync log file: %v", err))
+                        }
+                }
+        }
+
+        kept := extraOutputs[:0]
+        for _, o := range extraOutputs {
+                if rec.Level >= o.minLevel {
+                        if _, err := write(o.w, o.format); err != nil {
+                                errs = append(errs, fmt.Errorf("failed to write to output: %v", err))
+                                o.failures++
+                                if maxWriteFailures > 0 && o.failures >= maxWriteFailures {
+                                        errs = append(errs, fmt.Errorf("disabled output after %d failed writes", o.failures))
+                                        if c, ok := o.w.(io.Closer); ok {
+                                                c.Close()
+                                        }
+                                        continue
+                                }
+                        } else {
+                                o.failures = 0
+                        }
+                }
+                kept = append(kept, o)
+        }
+        extraOutputs = kept
+
+        return errs
+}
+
+// rotateExtraOutputs rotates every additional output that supports it
+func rotateExtraOutputs() error {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+
+        var firstErr error
+        for _, o := range extraOutputs {
+                if r, ok := o.w.(interface{ rotate() error }); ok {
+                        if err := r.rotate(); err != nil && firstErr == nil {
+                                firstErr = err
+                        }
+                }
+        }
+        return firstErr
+}
+
+// closeExtraOutputs closes and forgets every additional output, returning
+// the errors of those that failed to close
+func closeExtraOutputs() []error {
+        outputsMu.Lock()
+        outputs := extraOutputs
+        extraOutputs = nil
+        outputsMu.Unlock()
+
+        var errs []error
+        for _, o := range outputs {
+                if c, ok := o.w.(io.Closer); ok {
+                        if err := c.Close(); err != nil {
+                                errs = append(errs, fmt.Errorf("failed to close output %d (%T): %v", o.id, o.w, err))
+                        }
+                }
+        }
+        return errs
+}
+
+// shardedOutput spreads records round-robin over several files
+type shardedOutput struct {
+        mu     sync.Mutex
+        files  []*os.File
+        next   int
+        closed bool
+}
+
+// AddShardedOutput adds an output that spreads records round-robin over
+// shards files named base-0.log .. base-(shards-1).log inside baseDir, so
+// that several readers can consume the logs concurrently. The shards are
+// rotated by RotateLogFile and closed by CloseLogger.
+func AddShardedOutput(baseDir, base string, shards int) error {
+        if shards < 1 {
+                return fmt.Errorf("invalid number of shards: %d", shards)
+        }
+        if err := reserveHandles(shards); err != nil {
+                return err
+        }
+
+        // Create the shards directory if it doesn't exist
+        if err := os.MkdirAll(baseDir, 0755); err != nil {
+                return fmt.Errorf("failed to create shards directory: %v", err)
+        }
+
+        out := &shardedOutput{}
+        for i := 0; i < shards; i++ {
+                name := filepath.Join(baseDir, fmt.Sprintf("%s-%d.log", base, i))
+                f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+                if err != nil {
+                        out.Close()
+                        return fmt.Errorf("failed to open shard file: %v", err)
+                }
+                out.files = append(out.files, f)
+                atomic.AddInt64(&openHandles, 1)
+        }
+
+        AddOutput(out, FormatText)
+        return nil
+}
+
+// Write sends one record to the next shard
+func (s *shardedOutput) Write(p []byte) (int, error) {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        if s.closed {
+                return 0, os.ErrClosed
+        }
+        f := s.files[s.next]
+        s.next = (s.next + 1) % len(s.files)
+        return f.Write(p)
+}
+
+// rotate rotates every shard file. A shard that can't be rotated keeps its
+// file, one that can't be reopened either is dropped, and the others keep
+// going; the errors are joined.
+func (s *shardedOutput) rotate() error {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        if s.closed {
+                return nil
+        }
+        var errs []error
+        kept := s.files[:0]
+        for _, f := range s.files {
+                newFile, newPath, err := rotateFile(f)
+                if err != nil {
+                        errs = append(errs, err)
+                        if newFile != nil {
+                                kept = append(kept, newFile) // Keep writing to the same file
+                        } else {
+                                atomic.AddInt64(&openHandles, -1)
+                        }
+                        continue
+                }
+                kept = append(kept, newFile)
+
+                if err := compactRotated(newPath); err != nil {
+                        errs = append(errs, err)
+                }
+                if err := pruneBackupsFor(newFile.Name()); err != nil {
+                        errs = append(errs, err)
+                }
+        }
+        s.files = kept
+        if len(s.files) == 0 {
+                s.closed = true
+        } else {
+                s.next %= len(s.files)
+        }
+        return errors.Join(errs...)
+}
+
+// Close closes every shard file
+func (s *shardedOutput) Close() error {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        if s.closed {
+                return nil
+        }
+        s.closed = true
+        var firstErr error
+        for _, f := range s.files {
+                atomic.AddInt64(&openHandles, -1)
+                if err := f.Close(); err != nil && firstErr == nil {
+                        firstErr = err
+                }
+        }
+        return firstErr
+}
@@ -0,0 +1,426 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "encoding/json"
+        "errors"
+        "fmt"
+        "os"
+        "path/filepath"
+        "strings"
+        "sync"
+        "testing"
+)
+
+// shardPath returns the path of shard i of base in dir
+func shardPath(dir, base string, i int) string {
+        return filepath.Join(dir, fmt.Sprintf("%s-%d.log", base, i))
+}
+
+// countLines returns the number of lines of a file
+func countLines(t *testing.T, path string) int {
+        t.Helper()
+        return strings.Count(readLog(t, path), "\n")
+}
+
+func TestShardedOutputDistribution(t *testing.T) {
+        tests := []struct {
+                shards  int
+                records int
+        }{
+                {shards: 1, records: 10},
+                {shards: 3, records: 100},
+                {shards: 4, records: 1000},
+        }
+        for _, tt := range tests {
+                t.Run(fmt.Sprintf("%d shards", tt.shards), func(t *testing.T) {
+                        captureOutput(t)
+                        dir := t.TempDir()
+                        if err := AddShardedOutput(dir, "app", tt.shards); err != nil {
+                                t.Fatal(err)
+                        }
+                        for i := 0; i < tt.records; i++ {
+                                Infof("record %d", i)
+                        }
+                        CloseLogger()
+
+                        total := 0
+                        for i := 0; i < tt.shards; i++ {
+                                n := countLines(t, shardPath(dir, "app", i))
+                                if n < tt.records/tt.shards || n > tt.records/tt.shards+1 {
+                                        t.Errorf("shard %d has %d records, want about %d", i, n, tt.records/tt.shards)
+                                }
+                                total += n
+                        }
+                        if total != tt.records {
+                                t.Errorf("shards hold %d records, want %d", total, tt.records)
+                        }
+                })
+        }
+}
+
+func TestInvalidShardCount(t *testing.T) {
+        captureOutput(t)
+        for _, shards := range []int{0, -1} {
+                if err := AddShardedOutput(t.TempDir(), "app", shards); err == nil {
+                        t.Errorf("AddShardedOutput with %d shards succeeded", shards)
+                }
+        }
+}
+
+func TestShardedOutputRotation(t *testing.T) {
+        tests := []struct {
+                name    string
+                lost    int // Shard removed before rotating, -1 for none
+                records int // Records logged after the rotation
+                kept    []int
+        }{
+                {name: "every shard rotated", lost: -1, records: 6, kept: []int{0, 1, 2}},
+                {name: "removed shard reopened", lost: 2, records: 6, kept: []int{0, 1, 2}},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        clock := useFakeClock(t)
+                        dir := t.TempDir()
+                        if err := AddShardedOutput(dir, "app", 3); err != nil {
+                                t.Fatal(err)
+                        }
+
+                        // The next record goes to the last shard
+                        Info("before rotation")
+                        Info("before rotation")
+                        if tt.lost >= 0 {
+                                if err := os.Remove(shardPath(dir, "app", tt.lost)); err != nil {
+                                        t.Fatal(err)
+                                }
+                        }
+                        rotatedAt := clock.Now()
+                        err := RotateLogFile()
+                        if (err != nil) != (tt.lost >= 0) {
+                                t.Errorf("RotateLogFile error %v", err)
+                        }
+                        for i := 0; i < tt.records; i++ {
+                                Info("after rotation")
+                        }
+                        CloseLogger()
+
+                        for _, i := range tt.kept {
+                                got := readLog(t, shardPath(dir, "app", i))
+                                if strings.Contains(got, "before rotation") {
+                                        t.Errorf("shard %d still holds records from before the rotation", i)
+                                }
+                                if n := strings.Count(got, "after rotation"); n != tt.records/len(tt.kept) {
+                                        t.Errorf("shard %d got %d records after the rotation, want %d", i, n, tt.records/len(tt.kept))
+                                }
+                                rotated := filepath.Join(dir, defaultRotateName(fmt.Sprintf("app-%d", i), ".log", rotatedAt))
+                                if i < 2 && !strings.Contains(readLog(t, rotated), "before rotation") {
+                                        t.Errorf("rotated shard %d lacks the records from before the rotation", i)
+                                }
+                        }
+                })
+        }
+}
+
+func TestPerOutputFormat(t *testing.T) {
+        tests := []struct {
+                name  string
+                setup func(t *testing.T) func() string // Returns the second destination's content
+        }{
+                {
+                        name: "additional output",
+                        setup: func(t *testing.T) func() string {
+                                buf := &syncBuffer{}
+                                AddOutput(buf, FormatJSON)
+                                return buf.String
+                        },
+                },
+                {
+                        name: "log file",
+                        setup: func(t *testing.T) func() string {
+                                path := tempLogPath(t, "app.log")
+                                if err := InitLogger(LevelInfo, true, path); err != nil {
+                                        t.Fatal(err)
+                                }
+                                SetFileFormat(FormatJSON)
+                                return func() string { return readLog(t, path) }
+                        },
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        console := captureOutput(t)
+                        second := tt.setup(t)
+                        WithField("user", "alice").Info("logged in")
+
+                        if got := console.String(); !strings.HasPrefix(got, "[INFO] ") || !strings.Contains(got, "logged in user=alice") {
+                                t.Errorf("console got %q, want text", got)
+                        }
+                        var record map[string]interface{}
+                        if err := json.Unmarshal([]byte(second()), &record); err != nil {
+                                t.Fatalf("second destination got %q, want JSON: %v", second(), err)
+                        }
+                        if record["message"] != "logged in" || record["user"] != "alice" {
+                                t.Errorf("JSON record %v", record)
+                        }
+                })
+        }
+}
+
+func TestErrorsToStderr(t *testing.T) {
+        tests := []struct {
+                name    string
+                enabled bool
+                want    []string
+                absent  []string
+        }{
+                {
+                        name:    "enabled",
+                        enabled: true,
+                        want:    []string{"[ERROR]", "disk full"},
+                        absent:  []string{"request served", "slow request"},
+                },
+                {
+                        name:   "disabled",
+                        absent: []string{"request served", "slow request", "disk full"},
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        stderr := captureStderr(t)
+                        SetErrorsToStderr(tt.enabled)
+                        Info("request served")
+                        Warning("slow request")
+                        Error("disk full")
+
+                        got := stderr()
+                        for _, s := range tt.want {
+                                if !strings.Contains(got, s) {
+                                        t.Errorf("stderr %q lacks %q", got, s)
+                                }
+                        }
+                        for _, s := range tt.absent {
+                                if strings.Contains(got, s) {
+                                        t.Errorf("stderr %q contains %q", got, s)
+                                }
+                        }
+                        if n := len(out.Lines()); n != 3 {
+                                t.Errorf("console got %d records, want 3", n)
+                        }
+                })
+        }
+}
+
+// failingWriter fails every write, like a full disk
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+        return 0, errors.New("no space left on device")
+}
+
+func TestFailingOutput(t *testing.T) {
+        tests := []struct {
+                name         string
+                maxFailures  int
+                records      int
+                wantFallback int  // Records written to stderr instead
+                wantDisabled bool // The output was removed
+        }{
+                {name: "fallback to stderr", records: 3, wantFallback: 3},
+                {name: "disabled after threshold", maxFailures: 2, records: 4, wantFallback: 2, wantDisabled: true},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        stderr := captureStderr(t)
+                        var mu sync.Mutex
+                        var hooked []string
+                        SetErrorHook(func(err error) {
+                                mu.Lock()
+                                defer mu.Unlock()
+                                hooked = append(hooked, err.Error())
+                        })
+                        SetMaxWriteFailures(tt.maxFailures)
+                        AddOutput(failingWriter{}, FormatText)
+                        for i := 0; i < tt.records; i++ {
+                                Infof("record %d", i)
+                        }
+
+                        if n := strings.Count(stderr(), ": record "); n != tt.wantFallback {
+                                t.Errorf("%d records fell back to stderr, want %d", n, tt.wantFallback)
+                        }
+                        mu.Lock()
+                        defer mu.Unlock()
+                        if len(hooked) < tt.wantFallback || !strings.Contains(hooked[0], "no space left on device") {
+                                t.Errorf("error hook got %q", hooked)
+                        }
+                        disabled := strings.Contains(strings.Join(hooked, "\n"), "disabled output")
+                        if disabled != tt.wantDisabled || (len(Outputs()) == 0) != tt.wantDisabled {
+                                t.Errorf("output disabled: hook %v, outputs %v, want %v", disabled, Outputs(), tt.wantDisabled)
+                        }
+                        if LastError() == nil {
+                                t.Error("LastError not set")
+                        }
+                })
+        }
+}
+
+func TestLazyFile(t *testing.T) {
+        tests := []struct {
+                name       string
+                lazy       bool
+                wantAtInit bool
+                wantBelow  bool // After a record below the level
+        }{
+                {name: "lazy", lazy: true},
+                {name: "eager", wantAtInit: true, wantBelow: true},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        dir := filepath.Join(t.TempDir(), "logs")
+                        path := filepath.Join(dir, "app.log")
+                        exists := func() bool {
+                                _, err := os.Stat(path)
+                                _, dirErr := os.Stat(dir)
+                                if (err == nil) != (dirErr == nil) {
+                                        t.Fatalf("file and directory exist: %v, %v", err == nil, dirErr == nil)
+                                }
+                                return err == nil
+                        }
+                        SetLazyFile(tt.lazy)
+                        if err := InitLogger(LevelWarning, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        if exists() != tt.wantAtInit {
+                                t.Fatalf("file exists after init: %v, want %v", exists(), tt.wantAtInit)
+                        }
+                        if LogFilePath() != path {
+                                t.Errorf("LogFilePath() = %q, want %q", LogFilePath(), path)
+                        }
+                        Info("below the level")
+                        if exists() != tt.wantBelow {
+                                t.Fatalf("file exists after a record below the level: %v, want %v", exists(), tt.wantBelow)
+                        }
+                        Warning("disk almost full")
+                        if got := readLog(t, path); !strings.Contains(got, "disk almost full") || strings.Contains(got, "below the level") {
+                                t.Errorf("log file %q", got)
+                        }
+                })
+        }
+}
+
+// closeRecorder is a writer reporting whether it was closed; Close
+// returns err
+type closeRecorder struct {
+        syncBuffer
+        closed bool
+        err    error
+}
+
+func (c *closeRecorder) Close() error {
+        c.closed = true
+        return c.err
+}
+
+func TestRemoveOutput(t *testing.T) {
+        captureOutput(t)
+        first, second := &closeRecorder{}, &syncBuffer{}
+        firstID := AddOutput(first, FormatJSON)
+        secondID := AddOutput(second, FormatText)
+        if firstID == secondID {
+                t.Fatalf("both outputs got id %d", firstID)
+        }
+
+        infos := Outputs()
+        want := []OutputInfo{
+                {ID: firstID, Type: "*logger.closeRecorder", Format: FormatJSON},
+                {ID: secondID, Type: "*logger.syncBuffer", Format: FormatText},
+        }
+        if len(infos) != len(want) {
+                t.Fatalf("Outputs() = %+v, want %+v", infos, want)
+        }
+        for i := range want {
+                if infos[i] != want[i] {
+                        t.Errorf("Outputs()[%d] = %+v, want %+v", i, infos[i], want[i])
+                }
+        }
+
+        Info("to both")
+        if err := RemoveOutput(firstID); err != nil {
+                t.Fatal(err)
+        }
+        Info("to the second only")
+
+        tests := []struct {
+                name string
+                ok   bool
+        }{
+                {"removed output closed", first.closed},
+                {"removed output got the first record", strings.Contains(first.String(), "to both")},
+                {"removed output got nothing after", !strings.Contains(first.String(), "second only")},
+                {"remaining output got both records", len(second.Lines()) == 2},
+                {"remaining output listed", len(Outputs()) == 1 && Outputs()[0].ID == secondID},
+                {"unknown id rejected", RemoveOutput(firstID) != nil},
+        }
+        for _, tt := range tests {
+                if !tt.ok {
+                        t.Errorf("%s: first %q, second %q", tt.name, first.String(), second.String())
+                }
+        }
+}
+
+func TestCloseAll(t *testing.T) {
+        errClose := errors.New("disk detached")
+        tests := []struct {
+                name    string
+                failing []bool // Whether each output fails to close
+        }{
+                {name: "all close", failing: []bool{false, false}},
+                {name: "first fails", failing: []bool{true, false, false}},
+                {name: "middle fails", failing: []bool{false, true, false}},
+                {name: "all fail", failing: []bool{true, true}},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        if err := InitLogger(LevelInfo, true, tempLogPath(t, "app.log")); err != nil {
+                                t.Fatal(err)
+                        }
+                        var outputs []*closeRecorder
+                        var failedIDs []int
+                        for _, failing := range tt.failing {
+                                o := &closeRecorder{}
+                                if failing {
+                                        o.err = errClose
+                                }
+                                id := AddOutput(o, FormatText)
+                                if failing {
+                                        failedIDs = append(failedIDs, id)
+                                }
+                                outputs = append(outputs, o)
+                        }
+                        Info("before shutdown")
+
+                        errs := CloseAll()
+                        for i, o := range outputs {
+                                if !o.closed {
+                                        t.Errorf("output %d not closed", i)
+                                }
+                        }
+                        if path := LogFilePath(); path != "" {
+                                t.Errorf("log file %s still open", path)
+                        }
+                        if len(errs) != len(failedIDs) {
+                                t.Fatalf("got errors %v, want %d", errs, len(failedIDs))
+                        }
+                        for i, err := range errs {
+                                if want := fmt.Sprintf("output %d ", failedIDs[i]); !strings.Contains(err.Error(), want) || !strings.Contains(err.Error(), errClose.Error()) {
+                                        t.Errorf("error %q doesn't name %q and the cause", err, want)
+                                }
+                        }
+                })
+        }
+}
@@ -0,0 +1,82 @@
+// File: pause.go
+// Description:
+// Pausing. While paused, records are not written: they are held in a
+// bounded buffer (SetPauseBuffer) and written on Resume, or dropped once the
+// buffer is full. The configuration is left untouched.
+
+package logger
+
+import (
+        "sync"
+        "sync/atomic"
+)
+
+var (
+        // Set while logging is paused (accessed atomically)
+        paused int32
+
+        // Records held while paused and the maximum held (0 holds none)
+        pausedRecords []*Record
+        pauseBuffer   int
+        pauseMu       sync.Mutex
+)
+
+// Pause stops writing records until Resume. Records logged meanwhile are
+// held up to the SetPauseBuffer limit and dropped beyond it.
+func Pause() {
+        atomic.StoreInt32(&paused, 1)
+}
+
+// Resume writes the records held while paused and resumes logging
+func Resume() {
+        pauseMu.Lock()
+        atomic.StoreInt32(&paused, 0)
+        held := pausedRecords
+        pausedRecords = nil
+        pauseMu.Unlock()
+
+        for _, rec := range held {
+                writeRecord(rec)
+        }
+}
+
+// Paused reports whether logging is paused
+func Paused() bool {
+        return atomic.LoadInt32(&paused) != 0
+}
+
+// SetPauseBuffer sets how many records are held while paused to be written
+// on Resume (0, the default, drops them all)
+func SetPauseBuffer(n int) {
+        pauseMu.Lock()
+        defer pauseMu.Unlock()
+        pauseBuffer = n
+}
+
+// clearPause resumes logging, dropping the held records, and clears the
+// pause buffer limit
+func clearPause() {
+        pauseMu.Lock()
+        defer pauseMu.Unlock()
+        atomic.StoreInt32(&paused, 0)
+        pausedRecords = nil
+        pauseBuffer = 0
+}
+
+// holdIfPaused keeps or drops rec while paused and reports whether it did
+func holdIfPaused(rec *Record) bool {
+        if atomic.LoadInt32(&paused) == 0 {
+                return false
+        }
+        pauseMu.Lock()
+        defer pauseMu.Unlock()
+        if atomic.LoadInt32(&paused) == 0 {
+                return false
+        }
+        if rec != nil && len(pausedRecords) < pauseBuffer {
+                pausedRecords = append(pausedRecords, rec)
+        } else {
+                countDropped()
+        }
+        return true
+}
@@ -0,0 +1,73 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "fmt"
+        "strings"
+        "testing"
+)
+
+func TestPauseResume(t *testing.T) {
+        tests := []struct {
+                name        string
+                buffer      int
+                wantHeld    []string // Paused records written on Resume
+                wantDropped uint64
+        }{
+                {name: "dropped while paused", wantDropped: 3},
+                {name: "held up to the buffer", buffer: 2, wantHeld: []string{"paused 0", "paused 1"}, wantDropped: 1},
+                {name: "all held", buffer: 10, wantHeld: []string{"paused 0", "paused 1", "paused 2"}},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        path := tempLogPath(t, "app.log")
+                        if err := InitLogger(LevelInfo, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        SetPauseBuffer(tt.buffer)
+                        Info("before")
+
+                        Pause()
+                        if !Paused() {
+                                t.Error("Paused() = false after Pause")
+                        }
+                        for i := 0; i < 3; i++ {
+                                Info(fmt.Sprintf("paused %d", i))
+                        }
+                        if got := out.String(); strings.Contains(got, "paused") {
+                                t.Errorf("console written while paused: %q", got)
+                        }
+                        if got := readLog(t, path); strings.Contains(got, "paused") {
+                                t.Errorf("log file written while paused: %q", got)
+                        }
+                        if got := GetLevel(); got != LevelInfo {
+                                t.Errorf("level %d while paused", got)
+                        }
+
+                        Resume()
+                        if Paused() {
+                                t.Error("Paused() = true after Resume")
+                        }
+                        Info("after")
+
+                        var want []string
+                        want = append(want, "before")
+                        want = append(want, tt.wantHeld...)
+                        want = append(want, "after")
+                        for name, got := range map[string]string{"console": out.String(), "log file": readLog(t, path)} {
+                                var messages []string
+                                for _, line := range strings.Split(strings.TrimSpace(got), "\n") {
+                                        messages = append(messages, line[strings.LastIndex(line, ": ")+2:])
+                                }
+                                if strings.Join(messages, ",") != strings.Join(want, ",") {
+                                        t.Errorf("%s messages %v, want %v", name, messages, want)
+                                }
+                        }
+                        if got := GetStats().DroppedRecords; got != tt.wantDropped {
+                                t.Errorf("%d records dropped, want %d", got, tt.wantDropped)
+                        }
+                })
+        }
+}
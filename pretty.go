@@ -0,0 +1,133 @@
+// File: pretty.go
+// Description:
+// Pretty console format for local development. FormatPretty renders
+// records as colorized, aligned lines on terminals and falls back to
+// compact JSON on every other destination, so one setting gives readable
+// console output and machine readable files.
+
+package logger
+
+import (
+        "fmt"
+        "io"
+        "os"
+        "sort"
+        "strings"
+        "sync/atomic"
+)
+
+// prettyMessageWidth is the column width messages are padded to, so that
+// the fields of consecutive lines line up
+const prettyMessageWidth = 40
+
+// ANSI color sequences of the pretty format
+const (
+        colorReset = "\x1b[0m"
+        colorDim   = "\x1b[2m"
+)
+
+// Color modes of the pretty format, see SetColor
+const (
+        ColorAuto   = iota // Colorize on terminals, JSON elsewhere (the default)
+        ColorAlways        // Colorize on every destination, e.g. for less -R
+        ColorNever         // Plain aligned lines on terminals, JSON elsewhere
+)
+
+// Color mode of the pretty format (accessed atomically)
+var colorMode int32
+
+// SetColor sets how FormatPretty uses colors: ColorAuto (the default),
+// ColorAlways or ColorNever
+func SetColor(mode int) {
+        atomic.StoreInt32(&colorMode, int32(mode))
+}
+
+// PrettyEncoder renders records as colorized lines for terminals:
+// "15:04:05.000 INFO  app.go:12  message   key=value"
+type PrettyEncoder struct{}
+
+// Encode implements Encoder. Fields are sorted by key.
+func (PrettyEncoder) Encode(rec Record) ([]byte, error) {
+        reset, dim, color := colorReset, colorDim, levelColor(rec.Level)
+        if atomic.LoadInt32(&colorMode) == ColorNever {
+                reset, dim, color = "", "", ""
+        }
+
+        var b strings.Builder
+        b.WriteString(dim)
+        b.WriteString(rec.Time.Format("15:04:05.000"))
+        b.WriteString(reset)
+        b.WriteByte(' ')
+        b.WriteString(color)
+        fmt.Fprintf(&b, "%-5s", levelTag(rec.Level))
+        b.WriteString(reset)
+        b.WriteByte(' ')
+        if rec.Caller != "" {
+                b.WriteString(dim)
+                b.WriteString(rec.Caller)
+                b.WriteString(reset)
+                b.WriteString("  ")
+        }
+        b.WriteString(rec.Message)
+
+        if len(rec.Fields) > 0 {
+                if pad := prettyMessageWidth - len(rec.Message); pad > 0 {
+                        b.WriteString(strings.Repeat(" ", pad))
+                }
+                keys := make([]string, 0, len(rec.Fields))
+                for k := range rec.Fields {
+                        keys = append(keys, k)
+                }
+                sort.Strings(keys)
+                for _, k := range keys {
+                        b.WriteByte(' ')
+                        b.WriteString(color)
+                        b.WriteString(k)
+                        b.WriteString(reset)
+                        fmt.Fprintf(&b, "=%v", fieldValue(k, rec.Fields[k]))
+                }
+        }
+        b.WriteString("\n")
+        return []byte(b.String()), nil
+}
+
+// levelColor returns the color sequence of a level
+func levelColor(level int) string {
+        switch level {
+        case LevelDebug:
+                return "\x1b[90m"
+        case LevelInfo:
+                return "\x1b[36m"
+        case LevelWarning:
+                return "\x1b[33m"
+        case LevelError:
+                return "\x1b[31m"
+        case LevelFatal:
+                return "\x1b[1;31m"
+        default:
+                return "\x1b[36m"
+        }
+}
+
+// outputFormat returns the format to write to w: FormatPretty becomes
+// FormatJSON unless w is a terminal or colors are forced
+func outputFormat(w io.Writer, format int) int {
+        if format != FormatPretty || atomic.LoadInt32(&colorMode) == ColorAlways || writerIsTerminal(w) {
+                return format
+        }
+        return FormatJSON
+}
+
+// writerIsTerminal reports whether w writes to a terminal
+func writerIsTerminal(w io.Writer) bool {
+        if ww, ok := w.(wrapWriter); ok {
+                w = ww.w
+        }
+        if w == os.Stdout {
+                return stdoutIsTerminal()
+        }
+        if f, ok := w.(*os.File); ok {
+                return isTerminal(f)
+        }
+        return false
+}
@@ -0,0 +1,81 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "encoding/json"
+        "fmt"
+        "os"
+        "strings"
+        "testing"
+)
+
+func TestFormatPretty(t *testing.T) {
+        tests := []struct {
+                name      string
+                terminal  bool
+                color     int
+                want      string // Console line, %d for the caller line, "" for JSON
+                allPretty bool   // Whether the log file gets the console line too
+        }{
+                {
+                        name:     "terminal",
+                        terminal: true,
+                        want:     "\x1b[2m10:00:00.000\x1b[0m \x1b[36mINFO \x1b[0m \x1b[2mpretty_test.go:%d\x1b[0m  started" + strings.Repeat(" ", 33) + " \x1b[36muser\x1b[0m=alice\n",
+                },
+                {
+                        name:     "terminal without colors",
+                        terminal: true,
+                        color:    ColorNever,
+                        want:     "10:00:00.000 INFO  pretty_test.go:%d  started" + strings.Repeat(" ", 33) + " user=alice\n",
+                },
+                {name: "not a terminal"},
+                {
+                        name:      "colors forced",
+                        color:     ColorAlways,
+                        allPretty: true,
+                        want:      "\x1b[2m10:00:00.000\x1b[0m \x1b[36mINFO \x1b[0m \x1b[2mpretty_test.go:%d\x1b[0m  started" + strings.Repeat(" ", 33) + " \x1b[36muser\x1b[0m=alice\n",
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        useFakeClock(t)
+                        forceTerminal(t, tt.terminal)
+                        stdout := captureStdout(t)
+                        path := tempLogPath(t, "app.log")
+                        if err := InitLogger(LevelInfo, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        SetOutput(os.Stdout)
+                        SetFormat(FormatPretty)
+                        SetColor(tt.color)
+                        line := thisLine() + 1
+                        WithField("user", "alice").Info("started")
+
+                        console := stdout()
+                        if tt.want != "" {
+                                if want := fmt.Sprintf(tt.want, line); console != want {
+                                        t.Errorf("console got\n%q\nwant\n%q", console, want)
+                                }
+                        } else if !json.Valid([]byte(console)) || !strings.Contains(console, `"message":"started"`) {
+                                t.Errorf("console got %q, want compact JSON", console)
+                        }
+
+                        file := readLog(t, path)
+                        if tt.allPretty {
+                                if file != console {
+                                        t.Errorf("log file holds %q, want the console line", file)
+                                }
+                                return
+                        }
+                        var record map[string]interface{}
+                        if err := json.Unmarshal([]byte(file), &record); err != nil || strings.Count(file, "\n") != 1 {
+                                t.Fatalf("log file holds %q, want one compact JSON record", file)
+                        }
+                        if record["message"] != "started" || record["user"] != "alice" {
+                                t.Errorf("log file record %v", record)
+                        }
+                })
+        }
+}
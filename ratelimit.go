@@ -0,0 +1,81 @@
+// File: ratelimit.go
+// Description:
+// Global rate limiting. A token bucket caps the number of records written
+// per second, protecting downstream systems from floods during incidents.
+// Unlike sampling it is an absolute throughput cap; dropped records are
+// counted in Stats.
+
+package logger
+
+import (
+        "sync"
+        "sync/atomic"
+        "time"
+)
+
+var (
+        // Token bucket: refill rate per second (0 disables), capacity, tokens
+        // left and time of the last refill
+        rateLimit    float64
+        rateBurst    float64
+        rateTokens   float64
+        rateLastFill time.Time
+
+        // Error and fatal records bypass the limit
+        rateExemptErrors bool
+
+        rateMu sync.Mutex
+)
+
+// SetRateLimit limits records to perSecond on average, allowing bursts of
+// up to burst records (at least 1); records beyond the limit are dropped
+// and counted in Stats.RateLimited and Stats.DroppedRecords. Zero
+// perSecond disables the limit.
+func SetRateLimit(perSecond int, burst int) {
+        if burst < 1 {
+                burst = 1
+        }
+        rateMu.Lock()
+        defer rateMu.Unlock()
+        rateLimit = float64(perSecond)
+        rateBurst = float64(burst)
+        rateTokens = rateBurst
+        rateLastFill = now()
+}
+
+// SetRateLimitExemptErrors lets error and fatal records through regardless
+// of the rate limit; they still consume tokens when available
+func SetRateLimitExemptErrors(exempt bool) {
+        rateMu.Lock()
+        defer rateMu.Unlock()
+        rateExemptErrors = exempt
+}
+
+// allowedByRate reports whether a record of the given level passes the
+// rate limit, taking a token if so
+func allowedByRate(level int) bool {
+        rateMu.Lock()
+        defer rateMu.Unlock()
+        if rateLimit <= 0 {
+                return true
+        }
+
+        t := now()
+        if elapsed := t.Sub(rateLastFill).Seconds(); elapsed > 0 {
+                rateTokens += elapsed * rateLimit
+                if rateTokens > rateBurst {
+                        rateTokens = rateBurst
+                }
+        }
+        rateLastFill = t
+
+        if rateTokens >= 1 {
+                rateTokens--
+                return true
+        }
+        if rateExemptErrors && level >= LevelError {
+                return true
+        }
+        atomic.AddUint64(&statRateLimited, 1)
+        return false
+}
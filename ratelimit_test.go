@@ -0,0 +1,54 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "testing"
+        "time"
+)
+
+func TestRateLimit(t *testing.T) {
+        tests := []struct {
+                name        string
+                perSecond   int
+                exempt      bool
+                workload    string // i, e: a record of that level; +: a second passes; -: 100ms pass
+                wantWritten int
+                wantDropped uint64
+        }{
+                {name: "burst", perSecond: 10, workload: "iiiiiiii", wantWritten: 5, wantDropped: 3},
+                {name: "refilled up to the burst", perSecond: 10, workload: "iiiii+iiiiiiii", wantWritten: 10, wantDropped: 3},
+                {name: "partial refill", perSecond: 10, workload: "iiiii-ii", wantWritten: 6, wantDropped: 1},
+                {name: "errors limited", perSecond: 10, workload: "iiiiiee", wantWritten: 5, wantDropped: 2},
+                {name: "errors exempt", perSecond: 10, exempt: true, workload: "iiiiieei", wantWritten: 7, wantDropped: 1},
+                {name: "disabled", workload: "iiiiiiii", wantWritten: 8},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        clock := useFakeClock(t)
+                        SetRateLimit(tt.perSecond, 5)
+                        SetRateLimitExemptErrors(tt.exempt)
+                        for _, step := range tt.workload {
+                                switch step {
+                                case 'i':
+                                        Info("record")
+                                case 'e':
+                                        Error("record")
+                                case '+':
+                                        clock.Advance(time.Second)
+                                case '-':
+                                        clock.Advance(100 * time.Millisecond)
+                                }
+                        }
+
+                        if got := len(out.Lines()); got != tt.wantWritten {
+                                t.Errorf("%d records written, want %d", got, tt.wantWritten)
+                        }
+                        stats := GetStats()
+                        if stats.RateLimited != tt.wantDropped || stats.DroppedRecords != tt.wantDropped {
+                                t.Errorf("stats count %d rate limited and %d dropped records, want %d", stats.RateLimited, stats.DroppedRecords, tt.wantDropped)
+                        }
+                })
+        }
+}
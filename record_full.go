@@ -0,0 +1,90 @@
+//go:build !logger_minimal
+
+// File: record_full.go
+// Description:
+// Record building for the regular build: caller lookup, message formatting,
+// filters, sampling and automatic fields. The logger_minimal build tag
+// replaces it with the stripped down version in record_minimal.go.
+
+package logger
+
+import (
+        "fmt"
+        "path/filepath"
+        "runtime"
+        "time"
+)
+
+// emit builds a record for an enabled level and writes it to the outputs,
+// or holds it in buf if not nil. skip is the number of frames to skip above
+// the exported function (see callerDepth).
+func emit(buf *BufferedContext, skip int, level int, fields Fields, format string, v ...interface{}) {
+        pc, file, line, _ := runtime.Caller(callerDepth + skip)
+        emitAt(buf, callerDepth+skip+1, time.Time{}, pc, file, line, level, fields, format, v...)
+}
+
+// emitAt is emit for a known call site (file is empty if unknown) and time
+// (zero for now). stackSkip is the number of frames above emitAt left out of
+// stack traces.
+func emitAt(buf *BufferedContext, stackSkip int, t time.Time, pc uintptr, file string, line int, level int, fields Fields, format string, v ...interface{}) {
+        if t.IsZero() {
+                t = now()
+        }
+
+        var caller string
+        if file != "" {
+                caller = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+        }
+
+        // Package overrides may raise or lower the threshold for this
+        // caller; request logs hold every level
+        if (buf == nil || !buf.allLevels) && level < levelForCaller(pc, file) {
+                return
+        }
+
+        var msg string
+        v = wrapBinaryArgs(v)
+        if format == "" {
+                msg = fmt.Sprint(v...)
+        } else {
+                msg = fmt.Sprintf(format, v...)
+        }
+        msg = sanitizeMessage(truncateMessage(msg))
+        checkFormat(format, msg, caller)
+
+        if !passesFilters(level, msg, fields) {
+                return
+        }
+        if !sampled(fields) {
+                countDropped()
+                return
+        }
+
+        // Attach automatic fields
+        fields = limitFields(fields)
+        fields = withFingerprint(fields, level, format, caller)
+        fields = withHostname(fields)
+        fields = withGlobalFields(fields)
+        fields = withDynamicFields(fields)
+        fields = withStack(fields, level, stackSkip)
+        fields = withSourceLine(fields, file, line)
+        fields = withExpiry(fields, level, t)
+
+        rec := &Record{
+                Time:    t,
+                Level:   level,
+                Caller:  caller,
+                Message: msg,
+                Fields:  fields,
+        }
+        if isDeterministic() {
+                rec.Time = deterministicTime
+                rec.Caller = ""
+        }
+
+        if buf != nil {
+                buf.add(rec)
+        } else {
+                writeRecord(rec)
+        }
+}
@@ -0,0 +1,126 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "runtime"
+        "strings"
+        "testing"
+)
+
+// pausedRecordsHeld reports whether records logged while paused are held
+// for Resume; logger_minimal builds drop them
+const pausedRecordsHeld = true
+
+// thisLine returns the line it is called from
+func thisLine() int {
+        _, _, line, _ := runtime.Caller(1)
+        return line
+}
+
+func TestCallerLine(t *testing.T) {
+        ctx := context.Background()
+        entry := WithField("k", "v")
+        tests := []struct {
+                name  string
+                debug bool
+                log   func() int // Logs and returns the line of the call
+        }{
+                {"Debug", true, func() int { Debug("m"); return thisLine() }},
+                {"Debugf", true, func() int { Debugf("%s", "m"); return thisLine() }},
+                {"Debugw", true, func() int { Debugw("m", "k", "v"); return thisLine() }},
+                {"Info", false, func() int { Info("m"); return thisLine() }},
+                {"Infof", false, func() int { Infof("%s", "m"); return thisLine() }},
+                {"Infow", false, func() int { Infow("m", "k", "v"); return thisLine() }},
+                {"Warning", false, func() int { Warning("m"); return thisLine() }},
+                {"Warningf", false, func() int { Warningf("%s", "m"); return thisLine() }},
+                {"Warningw", false, func() int { Warningw("m", "k", "v"); return thisLine() }},
+                {"Error", false, func() int { Error("m"); return thisLine() }},
+                {"Errorf", false, func() int { Errorf("%s", "m"); return thisLine() }},
+                {"Errorw", false, func() int { Errorw("m", "k", "v"); return thisLine() }},
+                {"ErrorWith", false, func() int { ErrorWith(errors.New("e"), "m"); return thisLine() }},
+                {"LogError", false, func() int { LogError(errors.New("m")); return thisLine() }},
+                {"Fatal", false, func() int { Fatal("m"); return thisLine() }},
+                {"Fatalf", false, func() int { Fatalf("%s", "m"); return thisLine() }},
+                {"FatalCode", false, func() int { FatalCode(2, "m"); return thisLine() }},
+                {"FatalCtx", false, func() int { FatalCtx(ctx, "m"); return thisLine() }},
+                {"FatalfCtx", false, func() int { FatalfCtx(ctx, "%s", "m"); return thisLine() }},
+                {"Entry.Debug", true, func() int { entry.Debug("m"); return thisLine() }},
+                {"Entry.Debugf", true, func() int { entry.Debugf("%s", "m"); return thisLine() }},
+                {"Entry.Info", false, func() int { entry.Info("m"); return thisLine() }},
+                {"Entry.Infof", false, func() int { entry.Infof("%s", "m"); return thisLine() }},
+                {"Entry.Warning", false, func() int { entry.Warning("m"); return thisLine() }},
+                {"Entry.Warningf", false, func() int { entry.Warningf("%s", "m"); return thisLine() }},
+                {"Entry.Error", false, func() int { entry.Error("m"); return thisLine() }},
+                {"Entry.Errorf", false, func() int { entry.Errorf("%s", "m"); return thisLine() }},
+                {"Entry.Fatal", false, func() int { entry.Fatal("m"); return thisLine() }},
+                {"Entry.Fatalf", false, func() int { entry.Fatalf("%s", "m"); return thisLine() }},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        if tt.debug {
+                                requireDebug(t)
+                        }
+                        out := captureOutput(t)
+                        stubExit(t)
+                        SetLevel(LevelDebug)
+                        line := tt.log()
+                        want := fmt.Sprintf("record_full_test.go:%d:", line)
+                        if got := out.String(); !strings.Contains(got, want) {
+                                t.Errorf("output %q lacks the call site %s", got, want)
+                        }
+                })
+        }
+}
+
+// Two layers of a framework wrapping the logger; each records the line of
+// its call into the next layer
+var adapterLine, helperLine int
+
+func adapterLog(l *Logger, msg string) {
+        adapterHelper(l, msg)
+        adapterLine = thisLine() - 1
+}
+
+func adapterHelper(l *Logger, msg string) {
+        l.Infof("%s", msg)
+        helperLine = thisLine() - 1
+}
+
+func TestWithCallerSkip(t *testing.T) {
+        tests := []struct {
+                name   string
+                logger func() *Logger
+                want   func(call int) int // Expected line given the user call site
+        }{
+                {"no skip", func() *Logger { return WithField("k", "v") }, func(int) int { return helperLine }},
+                {"one frame", func() *Logger { return WithField("k", "v").WithCallerSkip(1) }, func(int) int { return adapterLine }},
+                {"both layers", func() *Logger { return WithField("k", "v").WithCallerSkip(2) }, func(call int) int { return call }},
+                {"skips add up", func() *Logger { return WithField("k", "v").WithCallerSkip(1).WithCallerSkip(1) }, func(call int) int { return call }},
+                {"kept by WithField", func() *Logger { return WithField("k", "v").WithCallerSkip(2).WithField("x", 1) }, func(call int) int { return call }},
+                {"kept by Named", func() *Logger { return Named("app").WithCallerSkip(2).Named("db") }, func(call int) int { return call }},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        l := tt.logger()
+                        adapterLog(l, "first")
+                        first := thisLine() - 1
+                        adapterLog(l, "second")
+                        second := thisLine() - 1
+                        lines := out.Lines()
+                        if len(lines) != 2 {
+                                t.Fatalf("got %d lines, want 2", len(lines))
+                        }
+                        for i, call := range []int{first, second} {
+                                want := fmt.Sprintf("record_full_test.go:%d:", tt.want(call))
+                                if !strings.Contains(lines[i], want) {
+                                        t.Errorf("line %q lacks the frame %s", lines[i], want)
+                                }
+                        }
+                })
+        }
+}
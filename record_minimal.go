@@ -0,0 +1,60 @@
+//go:build logger_minimal
+
+// File: record_minimal.go
+// Description:
+// Record building for the logger_minimal build tag. Caller lookup and all
+// structured features (fields, formats, filters, sampling, additional
+// outputs) are left out of the record path: enabled records are written
+// as "[LEVEL] message" lines to the console and log file, which keeps the
+// cost of a log call low. The public API is unchanged so code
+// builds the same with or without the tag; the other features therefore
+// remain in the package and its dependencies are the same.
+
+package logger
+
+import (
+        "fmt"
+        "io"
+        "sync/atomic"
+        "time"
+)
+
+// emit writes a plain line for an enabled level. Buffering is not
+// supported: buffered records are written immediately and records logged
+// while paused are dropped.
+func emit(buf *BufferedContext, skip int, level int, fields Fields, format string, v ...interface{}) {
+        emitAt(buf, 0, time.Time{}, 0, "", 0, level, fields, format, v...)
+}
+
+// emitAt is emit for a known call site and time, which are not shown either
+func emitAt(buf *BufferedContext, stackSkip int, t time.Time, pc uintptr, file string, lineNo int, level int, fields Fields, format string, v ...interface{}) {
+        if level < GetLevel() {
+                return
+        }
+        line := "[" + levelTag(level) + "] " + sanitizeMessage(plainMessage(format, v)) + "\n"
+        if holdIfPaused(nil) {
+                return
+        }
+
+        openPendingLogFile()
+
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        n, _ := io.WriteString(consoleWriter, line)
+        countBytes(n)
+        if logFile != nil {
+                n, _ = io.WriteString(bufferedFileWriter{level}, line)
+                countBytes(n)
+                scheduleGzipFlush()
+        }
+        atomic.AddUint64(&statTotalRecords, 1)
+}
+
+// plainMessage formats the arguments of a log call, with fmt.Sprintf if
+// there is a format
+func plainMessage(format string, v []interface{}) string {
+        if format == "" {
+                return fmt.Sprint(v...)
+        }
+        return fmt.Sprintf(format, v...)
+}
@@ -0,0 +1,58 @@
+//go:build logger_minimal
+
+package logger
+
+import (
+        "errors"
+        "testing"
+)
+
+// pausedRecordsHeld reports whether records logged while paused are held
+// for Resume; logger_minimal builds drop them
+const pausedRecordsHeld = false
+
+func TestMinimalRecords(t *testing.T) {
+        tests := []struct {
+                name  string
+                level int
+                log   func()
+                want  string
+        }{
+                {"plain line", LevelInfo, func() { Info("server started") }, "[INFO] server started\n"},
+                {"formatted", LevelInfo, func() { Warningf("%d retries", 3) }, "[WARN] 3 retries\n"},
+                {"below the level", LevelWarning, func() { Info("server started") }, ""},
+                {"fields dropped", LevelInfo, func() { WithField("user", "alice").Info("login") }, "[INFO] login\n"},
+                {"key/value pairs dropped", LevelInfo, func() { Errorw("failed", "attempt", 2) }, "[ERROR] failed\n"},
+                {"error entry", LevelInfo, func() { WithError(errors.New("boom")).Error("failed") }, "[ERROR] failed\n"},
+                {"binary payload unformatted", LevelInfo, func() { Info("payload:", []byte{0xca, 0xfe}) }, "[INFO] payload:[202 254]\n"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetLevel(tt.level)
+                        tt.log()
+                        if got := out.String(); got != tt.want {
+                                t.Errorf("output %q, want %q", got, tt.want)
+                        }
+                })
+        }
+}
+
+func TestMinimalLogFile(t *testing.T) {
+        out := captureOutput(t)
+        path := tempLogPath(t, "app.log")
+        if err := InitLogger(LevelInfo, true, path); err != nil {
+                t.Fatal(err)
+        }
+        Info("to both")
+        CloseLogger()
+        if got := readLog(t, path); got != "[INFO] to both\n" {
+                t.Errorf("log file %q", got)
+        }
+        if got := out.String(); got != "[INFO] to both\n" {
+                t.Errorf("console %q", got)
+        }
+        if stats := GetStats(); stats.TotalRecords != 1 {
+                t.Errorf("TotalRecords = %d, want 1", stats.TotalRecords)
+        }
+}
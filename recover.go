@@ -0,0 +1,99 @@
+// File: recover.go
+// Description:
+// Panic logging. Recover, deferred at the top of a goroutine, logs a panic
+// with its value and stack as structured fields, then continues, re-panics
+// or exits as configured, so panics are reported the same way everywhere.
+
+package logger
+
+import (
+        "fmt"
+        "path/filepath"
+        "runtime"
+        "strings"
+        "sync/atomic"
+)
+
+// What Recover does after logging a panic
+const (
+        PanicContinue = iota // Swallow the panic; the goroutine returns normally
+        PanicRepanic         // Panic again with the same value
+        PanicExit            // Exit with the fatal exit code
+)
+
+// PanicKey is the field name of the panic value
+const PanicKey = "panic"
+
+// Action taken by Recover after logging (accessed atomically)
+var panicAction int32 = PanicContinue
+
+// SetPanicAction sets what Recover does after logging a panic:
+// PanicContinue (the default), PanicRepanic or PanicExit
+func SetPanicAction(action int) {
+        atomic.StoreInt32(&panicAction, int32(action))
+}
+
+// Recover logs a panic, if any, with the panic value in "panic" and the
+// stack in "stack". It must be deferred directly:
+//
+//	defer logger.Recover()
+//
+// The panic is logged at error level, or at fatal when the panic action is
+// PanicExit.
+func Recover() {
+        r := recover()
+        if r == nil {
+                return
+        }
+        recovered(r, nil, panicSite())
+}
+
+// recovered logs a recovered panic with fields in addition to the panic
+// value and stack, then applies the panic action. It must be called by the
+// deferred function that recovered r, with skip computed there by panicSite.
+func recovered(r interface{}, fields Fields, skip int) {
+        action := int(atomic.LoadInt32(&panicAction))
+        level := LevelError
+        if action == PanicExit {
+                level = LevelFatal
+        }
+        fields = withField(fields, PanicKey, fmt.Sprint(r))
+        fields[StackKey] = captureStack(2)
+        logPanic(level, skip+1, fields, "panic: %v", r)
+
+        switch action {
+        case PanicRepanic:
+                panic(r)
+        case PanicExit:
+                exitFunc(FatalExitCode())
+        }
+}
+
+// panicSite returns the number of frames between the runtime's panic
+// handling that called Recover (or another deferred function calling
+// panicSite directly) and the frame that panicked, skipping the
+// runtime frames of panics raised by the runtime (nil map, index out of
+// range, ...)
+func panicSite() int {
+        pcs := make([]uintptr, maxStackDepth)
+        n := runtime.Callers(3, pcs) // Skip Callers, panicSite and the deferred function
+        frames := runtime.CallersFrames(pcs[:n])
+        for i := 0; ; i++ {
+                frame, more := frames.Next()
+                if !strings.HasPrefix(filepath.ToSlash(frame.File), goRootSrc) {
+                        return i
+                }
+                if !more {
+                        return 0
+                }
+        }
+}
+
+// logPanic logs a panic recovered by Recover. The caller is looked up skip
+// frames above recovered.
+func logPanic(level int, skip int, fields Fields, format string, v ...interface{}) {
+        if level < minEnabledLevel() {
+                return
+        }
+        emit(nil, skip, level, fields, format, v...)
+}
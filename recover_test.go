@@ -0,0 +1,124 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "errors"
+        "fmt"
+        "runtime"
+        "strings"
+        "testing"
+)
+
+// panicky panics with v after deferring Recover; it returns the line of
+// the panic
+func panicky(v interface{}, line *int) {
+        defer Recover()
+        *line = thisLine() + 1
+        panic(v)
+}
+
+// nilMapWrite triggers a runtime panic after deferring Recover
+func nilMapWrite(line *int) {
+        defer Recover()
+        var m map[string]int
+        *line = thisLine() + 1
+        m["k"] = 1
+}
+
+func TestRecover(t *testing.T) {
+        tests := []struct {
+                name        string
+                action      int
+                run         func(line *int)
+                wantLevel   string
+                wantPanic   string
+                wantRepanic bool
+                wantExited  bool
+        }{
+                {
+                        name:      "continue",
+                        run:       func(line *int) { panicky("boom", line) },
+                        wantLevel: "error",
+                        wantPanic: "boom",
+                },
+                {
+                        name:      "error value",
+                        run:       func(line *int) { panicky(fmt.Errorf("wrapped: %w", errTest), line) },
+                        wantLevel: "error",
+                        wantPanic: "wrapped: test error",
+                },
+                {
+                        name:      "runtime panic",
+                        run:       nilMapWrite,
+                        wantLevel: "error",
+                        wantPanic: "assignment to entry in nil map",
+                },
+                {
+                        name:        "repanic",
+                        action:      PanicRepanic,
+                        run:         func(line *int) { panicky("boom", line) },
+                        wantLevel:   "error",
+                        wantPanic:   "boom",
+                        wantRepanic: true,
+                },
+                {
+                        name:       "exit",
+                        action:     PanicExit,
+                        run:        func(line *int) { panicky("boom", line) },
+                        wantLevel:  "fatal",
+                        wantPanic:  "boom",
+                        wantExited: true,
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        exits := stubExit(t)
+                        SetFormat(FormatJSON)
+                        SetPanicAction(tt.action)
+                        // Keep the frames of the tests, which are part of the package
+                        SetStackFilter(func(frame runtime.Frame) bool {
+                                return strings.HasSuffix(frame.File, "_test.go") || defaultStackFilter(frame)
+                        })
+
+                        var line int
+                        repanicked := func() (p interface{}) {
+                                defer func() { p = recover() }()
+                                tt.run(&line)
+                                return nil
+                        }()
+                        if (repanicked != nil) != tt.wantRepanic {
+                                t.Errorf("panic after Recover: %v", repanicked)
+                        }
+                        if exited := len(exits()) > 0; exited != tt.wantExited {
+                                t.Errorf("exited %v, want %v", exited, tt.wantExited)
+                        }
+
+                        records := out.Records(t)
+                        if len(records) != 1 {
+                                t.Fatalf("got %d records, want 1", len(records))
+                        }
+                        record := records[0]
+                        if record["level"] != tt.wantLevel || record[PanicKey] != tt.wantPanic {
+                                t.Errorf("record %v, want level %s and panic %q", record, tt.wantLevel, tt.wantPanic)
+                        }
+                        if want := fmt.Sprintf("recover_test.go:%d", line); record["caller"] != want {
+                                t.Errorf("caller %v, want %s", record["caller"], want)
+                        }
+                        stack, _ := record[StackKey].(string)
+                        if files := stackFiles(stack); len(files) == 0 || files[0] != fmt.Sprintf("%s:%d", thisFile(), line) {
+                                t.Errorf("stack doesn't start at the panic:\n%s", stack)
+                        }
+                })
+        }
+}
+
+// errTest is an error value to panic with
+var errTest = errors.New("test error")
+
+// thisFile returns the path of the file it is called from
+func thisFile() string {
+        _, file, _, _ := runtime.Caller(1)
+        return file
+}
@@ -0,0 +1,189 @@
+// File: requestlog.go
+// Description:
+// Request logging with elevation on error. A RequestLog holds back the
+// debug and info records of a request: if an error is logged during the
+// request everything held is written, otherwise the debug records are
+// dropped when the request ends. Detailed logs show up exactly for the
+// requests that need them.
+
+package logger
+
+import (
+        "context"
+        "sync"
+)
+
+// RequestLog buffers the debug and info records of one request
+type RequestLog struct {
+        buf    BufferedContext
+        fields Fields
+
+        mu       sync.Mutex
+        elevated bool // An error was logged, records are written directly
+        ended    bool // End was called, records are written directly
+}
+
+// requestLogContextKey is the context key of the request log
+type requestLogContextKey struct{}
+
+// BeginRequestLogging starts buffering the debug and info records of a
+// request and returns a context carrying the request log (see
+// RequestLogFromContext). Warnings are written right away. The first error
+// writes everything held and every record after it, whatever the log
+// level; otherwise End writes the held info records the log level allows
+// and drops the debug ones. Records carry the request id of ctx, if any
+// (see Middleware).
+func BeginRequestLogging(ctx context.Context) (context.Context, *RequestLog) {
+        l := &RequestLog{buf: BufferedContext{allLevels: true}}
+        if id := RequestIDFromContext(ctx); id != "" {
+                l.fields = Fields{RequestIDKey: id}
+        }
+        return context.WithValue(ctx, requestLogContextKey{}, l), l
+}
+
+// RequestLogFromContext returns the request log started with
+// BeginRequestLogging, or nil. A nil request log writes its records
+// directly.
+func RequestLogFromContext(ctx context.Context) *RequestLog {
+        l, _ := ctx.Value(requestLogContextKey{}).(*RequestLog)
+        return l
+}
+
+// End finishes the request: the held info records are written (all held
+// records if an error was logged) and the debug records are dropped.
+// Records logged after End are written directly.
+func (l *RequestLog) End() {
+        if l == nil {
+                return
+        }
+        l.mu.Lock()
+        elevated := l.elevated
+        l.ended = true
+        l.mu.Unlock()
+
+        if elevated {
+                l.buf.Flush()
+                return
+        }
+        l.buf.mu.Lock()
+        records := l.buf.records
+        l.buf.records = nil
+        l.buf.mu.Unlock()
+        level := GetLevel()
+        for _, rec := range records {
+                if rec.Level > LevelDebug && rec.Level >= level {
+                        writeRecord(rec)
+                }
+        }
+}
+
+// Elevated reports whether an error was logged, so that every record of
+// the request is written
+func (l *RequestLog) Elevated() bool {
+        if l == nil {
+                return false
+        }
+        l.mu.Lock()
+        defer l.mu.Unlock()
+        return l.elevated
+}
+
+// logRequest logs a message with the caller info through l. During the
+// request, records below the log level are held (and written once the
+// request is elevated) too.
+func logRequest(l *RequestLog, level int, fields Fields, format string, v ...interface{}) {
+        if level == LevelDebug && !debugCompiled {
+                return
+        }
+        if l == nil {
+                if level >= minEnabledLevel() {
+                        emit(nil, 0, level, fields, format, v...)
+                }
+                return
+        }
+        fields = mergeFields(l.fields, fields)
+
+        l.mu.Lock()
+        flush := level >= LevelError && !l.elevated && !l.ended
+        if flush {
+                l.elevated = true
+        }
+        elevated, ended := l.elevated, l.ended
+        l.mu.Unlock()
+
+        if flush {
+                l.buf.Flush()
+        }
+        switch {
+        case ended || (level >= LevelWarning && !elevated):
+                // Written as usual
+                if level >= minEnabledLevel() {
+                        emit(nil, 0, level, fields, format, v...)
+                }
+        case elevated:
+                // Every level is written for an elevated request
+                direct := &BufferedContext{allLevels: true}
+                emit(direct, 0, level, fields, format, v...)
+                direct.Flush()
+        default:
+                emit(&l.buf, 0, level, fields, format, v...)
+        }
+}
+
+// mergeFields returns the union of two field sets, b taking precedence
+func mergeFields(a, b Fields) Fields {
+        if len(a) == 0 {
+                return b
+        }
+        if len(b) == 0 {
+                return a
+        }
+        merged := make(Fields, len(a)+len(b))
+        for k, v := range a {
+                merged[k] = v
+        }
+        for k, v := range b {
+                merged[k] = v
+        }
+        return merged
+}
+
+// Info logs an info message, held until the request ends or errors
+func (l *RequestLog) Info(v ...interface{}) {
+        logRequest(l, LevelInfo, nil, "", v...)
+}
+
+// Infof logs a formatted info message, held until the request ends or errors
+func (l *RequestLog) Infof(format string, v ...interface{}) {
+        logRequest(l, LevelInfo, nil, format, v...)
+}
+
+// Warning logs a warning message
+func (l *RequestLog) Warning(v ...interface{}) {
+        logRequest(l, LevelWarning, nil, "", v...)
+}
+
+// Warningf logs a formatted warning message
+func (l *RequestLog) Warningf(format string, v ...interface{}) {
+        logRequest(l, LevelWarning, nil, format, v...)
+}
+
+// Error logs an error message, writing the held records first
+func (l *RequestLog) Error(v ...interface{}) {
+        logRequest(l, LevelError, nil, "", v...)
+}
+
+// Errorf logs a formatted error message, writing the held records first
+func (l *RequestLog) Errorf(format string, v ...interface{}) {
+        logRequest(l, LevelError, nil, format, v...)
+}
+
+// Infow logs an info message with alternating key/value pairs
+func (l *RequestLog) Infow(msg string, keysAndValues ...interface{}) {
+        logRequest(l, LevelInfo, kvToFields(keysAndValues), "", msg)
+}
+
+// Errorw logs an error message with alternating key/value pairs
+func (l *RequestLog) Errorw(msg string, keysAndValues ...interface{}) {
+        logRequest(l, LevelError, kvToFields(keysAndValues), "", msg)
+}
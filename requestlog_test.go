@@ -0,0 +1,76 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "context"
+        "fmt"
+        "strings"
+        "testing"
+)
+
+func TestRequestLogging(t *testing.T) {
+        tests := []struct {
+                name     string
+                workload string // d, i, w, e: a record of that level; E: the request ends
+                want     string // Messages written, by workload index
+        }{
+                {name: "no error", workload: "diwdiE", want: "w2 i1 i4"},
+                {name: "error", workload: "diweE", want: "w2 d0 i1 e3"},
+                {name: "after an error", workload: "dedwiE", want: "d0 e1 d2 w3 i4"},
+                {name: "after the end", workload: "dEdi", want: "i3"},
+                {name: "nothing held", workload: "wE", want: "w0"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        requireDebug(t)
+                        out := captureOutput(t)
+                        SetFormat(FormatJSON)
+                        ctx := context.WithValue(context.Background(), requestIDContextKey{}, "req-5")
+                        ctx, l := BeginRequestLogging(ctx)
+                        if RequestLogFromContext(ctx) != l {
+                                t.Fatalf("context doesn't carry the request log")
+                        }
+                        for i, step := range tt.workload {
+                                msg := fmt.Sprintf("%c%d", step, i)
+                                switch step {
+                                case 'd':
+                                        l.Debug(msg)
+                                case 'i':
+                                        l.Info(msg)
+                                case 'w':
+                                        l.Warning(msg)
+                                case 'e':
+                                        l.Error(msg)
+                                case 'E':
+                                        l.End()
+                                }
+                        }
+
+                        var got []string
+                        for _, record := range out.Records(t) {
+                                got = append(got, fmt.Sprint(record["message"]))
+                                if record[RequestIDKey] != "req-5" {
+                                        t.Errorf("record %v lacks the request id", record)
+                                }
+                        }
+                        if strings.Join(got, " ") != tt.want {
+                                t.Errorf("written %q, want %q", strings.Join(got, " "), tt.want)
+                        }
+                        if elevated := strings.Contains(tt.workload, "e"); l.Elevated() != elevated {
+                                t.Errorf("Elevated() = %v, want %v", l.Elevated(), elevated)
+                        }
+                })
+        }
+}
+
+func TestRequestLogNil(t *testing.T) {
+        out := captureOutput(t)
+        var l *RequestLog
+        l.Debug("dropped")
+        l.Info("written")
+        l.End()
+        if got := out.Lines(); len(got) != 1 || !strings.HasSuffix(got[0], "written") {
+                t.Errorf("nil request log wrote %q, want only the info record", got)
+        }
+}
@@ -0,0 +1,47 @@
+// File: retention.go
+// Description:
+// Retention metadata. Records can carry an "expires_at" field computed from
+// a retention configured per level (e.g. debug for a day, errors for 90
+// days), so downstream storage can apply differential retention.
+
+package logger
+
+import (
+        "sync/atomic"
+        "time"
+)
+
+// ExpiresKey is the field name of the record expiry time
+const ExpiresKey = "expires_at"
+
+// Retention by level, 0 for no expiry field (accessed atomically)
+var retention [LevelFatal + 1]int64
+
+// SetRetention attaches an "expires_at" field (RFC 3339, UTC) to records of
+// the given level, set to the record time plus d. Zero removes the field.
+func SetRetention(level int, d time.Duration) {
+        if level < LevelDebug || level > LevelFatal {
+                return
+        }
+        atomic.StoreInt64(&retention[level], int64(d))
+}
+
+// clearRetention removes the retention of every level
+func clearRetention() {
+        for i := range retention {
+                atomic.StoreInt64(&retention[i], 0)
+        }
+}
+
+// withExpiry adds the expiry time of a record logged at t to fields if a
+// retention is configured for level
+func withExpiry(fields Fields, level int, t time.Time) Fields {
+        if level < LevelDebug || level > LevelFatal {
+                return fields
+        }
+        d := time.Duration(atomic.LoadInt64(&retention[level]))
+        if d <= 0 {
+                return fields
+        }
+        return withField(fields, ExpiresKey, t.Add(d).UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,66 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "testing"
+        "time"
+)
+
+func TestRetention(t *testing.T) {
+        retentions := map[int]time.Duration{
+                LevelDebug: 24 * time.Hour,
+                LevelInfo:  7 * 24 * time.Hour,
+                LevelError: 90 * 24 * time.Hour,
+        }
+        tests := []struct {
+                name    string
+                debug   bool
+                advance time.Duration // Clock advance before logging
+                log     func()
+                want    interface{} // nil for no expiry field
+        }{
+                {name: "debug", debug: true, log: func() { Debug("m") }, want: "2023-03-09T10:00:00Z"},
+                {name: "info", log: func() { Info("m") }, want: "2023-03-15T10:00:00Z"},
+                {name: "error", log: func() { Error("m") }, want: "2023-06-06T10:00:00Z"},
+                {name: "level without retention", log: func() { Warning("m") }},
+                {name: "record time", advance: 90 * time.Minute, log: func() { Info("m") }, want: "2023-03-15T11:30:00Z"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        if tt.debug {
+                                requireDebug(t)
+                        }
+                        out := captureOutput(t)
+                        clock := useFakeClock(t)
+                        SetFormat(FormatJSON)
+                        SetLevel(LevelDebug)
+                        for level, d := range retentions {
+                                SetRetention(level, d)
+                        }
+                        clock.Advance(tt.advance)
+                        tt.log()
+                        records := out.Records(t)
+                        if len(records) != 1 {
+                                t.Fatalf("got %d records, want 1", len(records))
+                        }
+                        if got := records[0][ExpiresKey]; got != tt.want {
+                                t.Errorf("%s = %v, want %v", ExpiresKey, got, tt.want)
+                        }
+                })
+        }
+}
+
+func TestRetentionRemoved(t *testing.T) {
+        out := captureOutput(t)
+        SetFormat(FormatJSON)
+        SetRetention(LevelInfo, time.Hour)
+        SetRetention(LevelInfo, 0)
+        SetRetention(LevelFatal+1, time.Hour) // Ignored
+        Info("m")
+        for _, record := range out.Records(t) {
+                if got, ok := record[ExpiresKey]; ok {
+                        t.Errorf("%s = %v after removing the retention", ExpiresKey, got)
+                }
+        }
+}
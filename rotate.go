@@ -0,0 +1,400 @@
+// File: rotate.go
+// Description:
+// Helpers around log file rotation: naming of rotated files, pruning of old
+// backups, the optional "current" symlink to the active log file and the
+// rotation callback.
+
+package logger
+
+import (
+        "fmt"
+        "os"
+        "path/filepath"
+        "sort"
+        "strings"
+        "sync"
+        "sync/atomic"
+        "time"
+)
+
+// RotateNameFunc builds the file name of a rotated log file from the base
+// name (without extension), the extension (with its dot) and the rotation time
+type RotateNameFunc func(base, ext string, t time.Time) string
+
+var (
+        // Naming pattern for rotated files, nil for the default
+        rotateName atomic.Pointer[RotateNameFunc]
+
+        // Number of rotated files to keep, 0 keeps all (accessed atomically)
+        maxBackups int64
+
+        // Maintain a "<name>-current<ext>" symlink to the active log file
+        // (accessed atomically)
+        currentSymlink int32
+
+        // Rotate logrotate style (app.log.1, app.log.2, ...) instead of by
+        // name (accessed atomically)
+        numberedRotation int32
+
+        // Called after the log file was rotated
+        onRotate   func(oldPath, newPath string)
+        onRotateMu sync.RWMutex
+)
+
+// rotateFile closes f, moves it aside using the configured naming pattern and
+// opens a fresh file under the original name. It returns the new file and
+// the path the old contents were moved to. If rotating fails, the returned
+// file is f's path reopened for appending (nil if that fails too), so the
+// caller can keep logging to it.
+func rotateFile(f *os.File) (*os.File, string, error) {
+        path := f.Name()
+
+        // Close current log file
+        f.Close()
+
+        newFile, newPath, err := moveAside(path)
+        if err != nil {
+                reopened, rerr := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+                if rerr != nil {
+                        return nil, "", fmt.Errorf("%v; failed to reopen log file: %v", err, rerr)
+                }
+                return reopened, "", err
+        }
+        return newFile, newPath, nil
+}
+
+// moveAside renames the closed file at path using the configured naming
+// pattern and creates a fresh file under path
+func moveAside(path string) (*os.File, string, error) {
+        // Get the path and base filename
+        dir, filename := filepath.Split(path)
+        ext := filepath.Ext(filename)
+        baseFilename := strings.TrimSuffix(filename, ext)
+
+        // Create a new filename using the configured naming pattern
+        newFilename := rotateNameFunc()(baseFilename, ext, now())
+        newPath := filepath.Join(dir, newFilename)
+
+        // Numbered rotation shifts existing backups and always uses .1
+        if isNumberedRotation() {
+                if err := shiftNumberedBackups(path); err != nil {
+                        return nil, "", fmt.Errorf("failed to shift numbered log files: %v", err)
+                }
+                newPath = numberedBackupPath(path, 1)
+        }
+
+        // Rename the old file
+        if err := os.Rename(path, newPath); err != nil {
+                return nil, "", fmt.Errorf("failed to rename log file: %v", err)
+        }
+
+        // Open a new log file
+        newFile, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+        if err != nil {
+                return nil, "", fmt.Errorf("failed to open new log file: %v", err)
+        }
+
+        return newFile, newPath, nil
+}
+
+// pruneBackupsFor removes the oldest rotated copies of the given log file
+// beyond the configured limit
+func pruneBackupsFor(path string) error {
+        dir, filename := filepath.Split(path)
+        ext := filepath.Ext(filename)
+        return pruneBackups(dir, strings.TrimSuffix(filename, ext), ext)
+}
+
+// defaultRotateName names rotated files base-20060102-150405.ext
+func defaultRotateName(base, ext string, t time.Time) string {
+        return fmt.Sprintf("%s-%s%s", base, t.Format("20060102-150405"), ext)
+}
+
+// SetRotateNameFunc sets the naming pattern used for rotated log files.
+// Pruning recognizes rotated files by comparing names with the ones fn
+// returns for other times, so only the parts derived from the time should
+// vary (e.g. "app-2023-03-08.log"). Passing nil restores the default
+// timestamped pattern.
+func SetRotateNameFunc(fn RotateNameFunc) {
+        if fn == nil {
+                rotateName.Store(nil)
+                return
+        }
+        rotateName.Store(&fn)
+}
+
+// rotateNameFunc returns the naming pattern for rotated files
+func rotateNameFunc() RotateNameFunc {
+        if fn := rotateName.Load(); fn != nil {
+                return *fn
+        }
+        return defaultRotateName
+}
+
+// SetMaxBackups sets how many rotated log files are kept; older ones are
+// removed after each rotation. Zero keeps all of them.
+func SetMaxBackups(n int) {
+        atomic.StoreInt64(&maxBackups, int64(n))
+}
+
+// backupLimit returns the number of rotated files to keep (0 keeps all)
+func backupLimit() int {
+        return int(atomic.LoadInt64(&maxBackups))
+}
+
+// SetNumberedRotation enables logrotate style rotation: on each rotation
+// app.log becomes app.log.1, app.log.1 becomes app.log.2 and so on, keeping
+// at most MaxBackups numbered files (all of them when MaxBackups is zero).
+// When enabled it takes precedence over SetRotateNameFunc.
+func SetNumberedRotation(enabled bool) {
+        var v int32
+        if enabled {
+                v = 1
+        }
+        atomic.StoreInt32(&numberedRotation, v)
+}
+
+// isNumberedRotation reports whether rotation is logrotate style
+func isNumberedRotation() bool {
+        return atomic.LoadInt32(&numberedRotation) != 0
+}
+
+// numberedBackupPath returns the path of the n-th numbered backup
+func numberedBackupPath(logPath string, n int) string {
+        return fmt.Sprintf("%s.%d", logPath, n)
+}
+
+// shiftNumberedBackups renames logPath.N to logPath.N+1 for every existing
+// backup, highest first so nothing is clobbered, dropping the ones that
+// would exceed maxBackups. Afterwards logPath.1 is free.
+func shiftNumberedBackups(logPath string) error {
+        // Find the highest existing index
+        limit := backupLimit()
+        last := 0
+        for {
+                if _, err := os.Stat(numberedBackupPath(logPath, last+1)); err != nil {
+                        break
+                }
+                last++
+        }
+
+        for n := last; n >= 1; n-- {
+                from := numberedBackupPath(logPath, n)
+                if limit > 0 && n >= limit {
+                        if err := os.Remove(from); err != nil {
+                                return err
+                        }
+                        continue
+                }
+                if err := os.Rename(from, numberedBackupPath(logPath, n+1)); err != nil {
+                        return err
+                }
+        }
+        return nil
+}
+
+// Rotation times the naming pattern is sampled at to tell the parts of a
+// rotated name derived from the time from the fixed ones
+var (
+        rotateSampleA = time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+        rotateSampleB = time.Date(2019, 11, 28, 17, 49, 58, 0, time.UTC)
+)
+
+// isBackupFile reports whether name is a rotated copy of base+ext under the
+// active naming scheme, so pruning never touches unrelated files sharing
+// the prefix (e.g. app-server.log next to app.log)
+func isBackupFile(name, base, ext string) bool {
+        if name == base+ext || name == base+"-current"+ext {
+                return false
+        }
+        if isNumberedRotation() {
+                n := strings.TrimPrefix(name, base+ext+".")
+                return n != name && isDigits(n)
+        }
+
+        // Compare with the names of two rotations: characters that are the
+        // same in both must match, the others must be letters or digits
+        fn := rotateNameFunc()
+        a, b := fn(base, ext, rotateSampleA), fn(base, ext, rotateSampleB)
+        prefix := 0
+        for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+                prefix++
+        }
+        suffix := 0
+        for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+                suffix++
+        }
+        if !strings.HasPrefix(name, a[:prefix]) || !strings.HasSuffix(name, a[len(a)-suffix:]) || len(name) < prefix+suffix {
+                return false
+        }
+        middle := name[prefix : len(name)-suffix]
+        if len(a) != len(b) {
+                // Variable length (e.g. month names): any name, as long as it
+                // has a digit
+                return middle != "" && strings.IndexFunc(middle, isDigit) >= 0 && strings.IndexFunc(middle, isSeparator) < 0
+        }
+        if len(name) != len(a) {
+                return false
+        }
+        for i := 0; i < len(middle); i++ {
+                c, ca, cb := name[prefix+i], a[prefix+i], b[prefix+i]
+                if ca == cb {
+                        if c != ca {
+                                return false
+                        }
+                } else if !isAlnum(c) {
+                        return false
+                }
+        }
+        return true
+}
+
+// isDigits reports whether s is a non-empty run of decimal digits
+func isDigits(s string) bool {
+        if s == "" {
+                return false
+        }
+        for i := 0; i < len(s); i++ {
+                if s[i] < '0' || s[i] > '9' {
+                        return false
+                }
+        }
+        return true
+}
+
+// isDigit reports whether r is a decimal digit
+func isDigit(r rune) bool {
+        return r >= '0' && r <= '9'
+}
+
+// isSeparator reports whether r separates path elements
+func isSeparator(r rune) bool {
+        return r == '/' || r == '\\'
+}
+
+// isAlnum reports whether c is an ASCII letter or digit
+func isAlnum(c byte) bool {
+        return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// pruneBackups removes the oldest rotated files beyond maxBackups
+func pruneBackups(dir, base, ext string) error {
+        limit := backupLimit()
+        if limit <= 0 {
+                return nil
+        }
+        if dir == "" {
+                dir = "."
+        }
+
+        entries, err := os.ReadDir(dir)
+        if err != nil {
+                return err
+        }
+
+        type backup struct {
+                path    string
+                modTime time.Time
+        }
+        var backups []backup
+        for _, entry := range entries {
+                if !entry.Type().IsRegular() || !isBackupFile(entry.Name(), base, ext) {
+                        continue
+                }
+                info, err := entry.Info()
+                if err != nil {
+                        continue
+                }
+                backups = append(backups, backup{filepath.Join(dir, entry.Name()), info.ModTime()})
+        }
+        if len(backups) <= limit {
+                return nil
+        }
+
+        // Newest first, then drop everything past the limit
+        sort.Slice(backups, func(i, j int) bool {
+                return backups[i].modTime.After(backups[j].modTime)
+        })
+        for _, b := range backups[limit:] {
+                if err := os.Remove(b.path); err != nil {
+                        return err
+                }
+        }
+        return nil
+}
+
+// SetCurrentSymlink enables a "<name>-current<ext>" symlink next to the log
+// file (e.g. app-current.log) that is updated to the active file after every
+// rotation. Where symlinks are not permitted (e.g. Windows without the
+// required privilege) the link is skipped with a warning.
+func SetCurrentSymlink(enabled bool) {
+        var v int32
+        if enabled {
+                v = 1
+        }
+        atomic.StoreInt32(&currentSymlink, v)
+        if enabled {
+                updateCurrentSymlink()
+        }
+}
+
+// currentSymlinkPath returns the path of the "current" symlink for a log file
+func currentSymlinkPath(logPath string) string {
+        dir, filename := filepath.Split(logPath)
+        ext := filepath.Ext(filename)
+        return filepath.Join(dir, strings.TrimSuffix(filename, ext)+"-current"+ext)
+}
+
+// updateCurrentSymlink points the "current" symlink at the active log file
+func updateCurrentSymlink() {
+        outputsMu.Lock()
+        f := logFile
+        outputsMu.Unlock()
+        if atomic.LoadInt32(&currentSymlink) == 0 || f == nil {
+                return
+        }
+
+        link := currentSymlinkPath(f.Name())
+
+        // Replace a previous link, but never a file the link name happens to
+        // belong to; the target is relative so the directory can be moved or
+        // mounted elsewhere
+        if info, err := os.Lstat(link); err == nil {
+                if info.Mode()&os.ModeSymlink == 0 {
+                        Warningf("failed to update current log symlink: %s exists and is not a symlink", link)
+                        return
+                }
+                os.Remove(link)
+        }
+        if err := os.Symlink(filepath.Base(f.Name()), link); err != nil {
+                Warningf("failed to update current log symlink: %v", err)
+        }
+}
+
+// SetOnRotate registers a function called after each successful rotation of
+// the log file with the path the old contents were archived to and the path
+// of the new active file, e.g. to upload the archive. A panic in fn is
+// logged as an error and doesn't affect the rotation. Passing nil removes
+// the callback.
+func SetOnRotate(fn func(oldPath, newPath string)) {
+        onRotateMu.Lock()
+        defer onRotateMu.Unlock()
+        onRotate = fn
+}
+
+// runOnRotate calls the rotation callback, if any
+func runOnRotate(oldPath, newPath string) {
+        onRotateMu.RLock()
+        fn := onRotate
+        onRotateMu.RUnlock()
+        if fn == nil {
+                return
+        }
+
+        defer func() {
+                if r := recover(); r != nil {
+                        Errorf("rotation callback failed: %v", r)
+                }
+        }()
+        fn(oldPath, newPath)
+}
@@ -0,0 +1,361 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "fmt"
+        "os"
+        "path/filepath"
+        "runtime"
+        "strings"
+        "testing"
+        "time"
+)
+
+func TestCurrentSymlink(t *testing.T) {
+        if runtime.GOOS == "windows" {
+                t.Skip("symlinks need extra privileges on Windows")
+        }
+        tests := []struct {
+                name     string
+                existing string // Regular file content at the link path, if any
+        }{
+                {name: "link follows rotation"},
+                {name: "regular file kept", existing: "not a link\n"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        path := tempLogPath(t, "app.log")
+                        link := filepath.Join(filepath.Dir(path), "app-current.log")
+                        if tt.existing != "" {
+                                if err := os.WriteFile(link, []byte(tt.existing), 0644); err != nil {
+                                        t.Fatal(err)
+                                }
+                        }
+                        if err := InitLogger(LevelInfo, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        SetCurrentSymlink(true)
+                        Info("before rotation")
+                        if err := RotateLogFile(); err != nil {
+                                t.Fatal(err)
+                        }
+                        Info("after rotation")
+
+                        if tt.existing != "" {
+                                if got := readLog(t, link); got != tt.existing {
+                                        t.Errorf("regular file at link path changed to %q", got)
+                                }
+                                if !strings.Contains(out.String(), "is not a symlink") {
+                                        t.Errorf("no warning about the regular file in %q", out.String())
+                                }
+                                return
+                        }
+                        target, err := os.Readlink(link)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        if target != filepath.Base(LogFilePath()) {
+                                t.Errorf("link targets %q, want %q", target, filepath.Base(LogFilePath()))
+                        }
+                        got := readLog(t, link)
+                        if !strings.Contains(got, "after rotation") || strings.Contains(got, "before rotation") {
+                                t.Errorf("link does not resolve to the active file, content %q", got)
+                        }
+                })
+        }
+}
+
+// dateRotateName names rotated files base.2006-01-02.ext
+func dateRotateName(base, ext string, t time.Time) string {
+        return base + "." + t.Format("2006-01-02") + ext
+}
+
+func TestIsBackupFile(t *testing.T) {
+        tests := []struct {
+                name     string
+                fn       RotateNameFunc
+                numbered bool
+                file     string
+                want     bool
+        }{
+                {name: "default scheme", file: "app-20230308-101010.log", want: true},
+                {name: "active file", file: "app.log", want: false},
+                {name: "current symlink", file: "app-current.log", want: false},
+                {name: "sibling log", file: "app-server.log", want: false},
+                {name: "sibling with suffix", file: "app_audit.log", want: false},
+                {name: "other extension", file: "app-20230308-101010.txt", want: false},
+                {name: "custom scheme", fn: dateRotateName, file: "app.2023-03-08.log", want: true},
+                {name: "custom scheme, default name", fn: dateRotateName, file: "app-20230308-101010.log", want: false},
+                {name: "custom scheme, sibling", fn: dateRotateName, file: "app.old-copy.log", want: false},
+                {name: "numbered", numbered: true, file: "app.log.3", want: true},
+                {name: "numbered, not a number", numbered: true, file: "app.log.bak", want: false},
+                {name: "numbered, timestamped", numbered: true, file: "app-20230308-101010.log", want: false},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        SetRotateNameFunc(tt.fn)
+                        SetNumberedRotation(tt.numbered)
+                        if got := isBackupFile(tt.file, "app", ".log"); got != tt.want {
+                                t.Errorf("isBackupFile(%q) = %v, want %v", tt.file, got, tt.want)
+                        }
+                })
+        }
+}
+
+func TestRotateNameFunc(t *testing.T) {
+        captureOutput(t)
+        path := tempLogPath(t, "app.log")
+        dir := filepath.Dir(path)
+        if err := InitLogger(LevelInfo, true, path); err != nil {
+                t.Fatal(err)
+        }
+
+        // Older rotations of app.log and an unrelated file sharing its prefix
+        old := time.Now().Add(-time.Hour)
+        for i, name := range []string{"app.2023-03-06.log", "app.2023-03-07.log", "app-server.log"} {
+                p := filepath.Join(dir, name)
+                if err := os.WriteFile(p, nil, 0644); err != nil {
+                        t.Fatal(err)
+                }
+                mod := old.Add(time.Duration(i) * time.Minute)
+                if err := os.Chtimes(p, mod, mod); err != nil {
+                        t.Fatal(err)
+                }
+        }
+
+        var rotatedAt time.Time
+        SetRotateNameFunc(func(base, ext string, t time.Time) string {
+                if rotatedAt.IsZero() { // Later calls are pruning samples
+                        rotatedAt = t
+                }
+                return dateRotateName(base, ext, t)
+        })
+        SetMaxBackups(2)
+        Info("before rotation")
+        if err := RotateLogFile(); err != nil {
+                t.Fatal(err)
+        }
+
+        tests := []struct {
+                file   string
+                exists bool
+        }{
+                {dateRotateName("app", ".log", rotatedAt), true},
+                {"app.2023-03-07.log", true},
+                {"app.2023-03-06.log", false},
+                {"app-server.log", true},
+                {"app.log", true},
+        }
+        for _, tt := range tests {
+                _, err := os.Stat(filepath.Join(dir, tt.file))
+                if exists := err == nil; exists != tt.exists {
+                        t.Errorf("%s exists = %v, want %v", tt.file, exists, tt.exists)
+                }
+        }
+        if got := readLog(t, filepath.Join(dir, dateRotateName("app", ".log", rotatedAt))); !strings.Contains(got, "before rotation") {
+                t.Errorf("rotated file lacks the old records: %q", got)
+        }
+}
+
+func TestNumberedRotation(t *testing.T) {
+        tests := []struct {
+                name       string
+                maxBackups int
+                rotations  int
+                want       []int // Record held by app.log.1, app.log.2, ...
+        }{
+                {name: "unlimited", rotations: 3, want: []int{3, 2, 1}},
+                {name: "capped", maxBackups: 3, rotations: 5, want: []int{5, 4, 3}},
+                {name: "single backup", maxBackups: 1, rotations: 4, want: []int{4}},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        path := tempLogPath(t, "app.log")
+                        if err := InitLogger(LevelInfo, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        SetNumberedRotation(true)
+                        SetMaxBackups(tt.maxBackups)
+                        for i := 1; i <= tt.rotations; i++ {
+                                Infof("record %d", i)
+                                if err := rotateLogFile(false); err != nil {
+                                        t.Fatal(err)
+                                }
+                        }
+
+                        for i, record := range tt.want {
+                                got := readLog(t, numberedBackupPath(path, i+1))
+                                if !strings.Contains(got, fmt.Sprintf("record %d\n", record)) {
+                                        t.Errorf("%s.%d = %q, want record %d", path, i+1, got, record)
+                                }
+                        }
+                        if _, err := os.Stat(numberedBackupPath(path, len(tt.want)+1)); err == nil {
+                                t.Errorf("%s.%d exists beyond the limit", path, len(tt.want)+1)
+                        }
+                })
+        }
+}
+
+func TestOnRotate(t *testing.T) {
+        tests := []struct {
+                name     string
+                setup    func(t *testing.T)
+                callback func(calls *[][2]string) func(oldPath, newPath string)
+                wantOld  string // Name of the archive, empty for no callback call
+                panics   bool
+        }{
+                {
+                        name:     "timestamped archive",
+                        callback: recordRotation,
+                        wantOld:  "app-20230308-100000.log",
+                },
+                {
+                        name:     "numbered archive",
+                        setup:    func(*testing.T) { SetNumberedRotation(true) },
+                        callback: recordRotation,
+                        wantOld:  "app.log.1",
+                },
+                {
+                        name: "additional outputs don't call back",
+                        setup: func(t *testing.T) {
+                                if _, err := AddFileOutput(filepath.Join(t.TempDir(), "extra.log"), FormatText); err != nil {
+                                        t.Fatal(err)
+                                }
+                        },
+                        callback: recordRotation,
+                        wantOld:  "app-20230308-100000.log",
+                },
+                {
+                        name: "panicking callback",
+                        callback: func(calls *[][2]string) func(string, string) {
+                                return func(oldPath, newPath string) {
+                                        recordRotation(calls)(oldPath, newPath)
+                                        panic("upload failed")
+                                }
+                        },
+                        wantOld: "app-20230308-100000.log",
+                        panics:  true,
+                },
+                {
+                        name:     "no callback",
+                        callback: func(*[][2]string) func(string, string) { return nil },
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        useFakeClock(t)
+                        path := tempLogPath(t, "app.log")
+                        dir := filepath.Dir(path)
+                        if err := InitLogger(LevelInfo, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        if tt.setup != nil {
+                                tt.setup(t)
+                        }
+                        var calls [][2]string
+                        SetOnRotate(tt.callback(&calls))
+                        Info("before rotation")
+                        if err := RotateLogFile(); err != nil {
+                                t.Fatalf("rotation failed: %v", err)
+                        }
+                        Info("after rotation")
+
+                        if tt.wantOld == "" {
+                                if len(calls) != 0 {
+                                        t.Errorf("callback called: %v", calls)
+                                }
+                                return
+                        }
+                        want := [2]string{filepath.Join(dir, tt.wantOld), path}
+                        if len(calls) != 1 || calls[0] != want {
+                                t.Fatalf("callback calls %v, want %v", calls, want)
+                        }
+                        if got := readLog(t, want[0]); !strings.Contains(got, "before rotation") {
+                                t.Errorf("archive %q", got)
+                        }
+                        if got := readLog(t, path); !strings.Contains(got, "after rotation") {
+                                t.Errorf("active file %q", got)
+                        }
+                        if panicked := strings.Contains(out.String(), "rotation callback failed: upload failed"); panicked != tt.panics {
+                                t.Errorf("console %q", out.String())
+                        }
+                })
+        }
+}
+
+// recordRotation returns a rotation callback appending its arguments to calls
+func recordRotation(calls *[][2]string) func(oldPath, newPath string) {
+        return func(oldPath, newPath string) {
+                *calls = append(*calls, [2]string{oldPath, newPath})
+        }
+}
+
+func TestRotateFailure(t *testing.T) {
+        tests := []struct {
+                name     string
+                breakDir func(t *testing.T, dir string) // Makes rotation in dir fail
+        }{
+                {
+                        name: "missing target directory",
+                        breakDir: func(t *testing.T, dir string) {
+                                SetRotateNameFunc(func(base, ext string, at time.Time) string {
+                                        return filepath.Join("missing", defaultRotateName(base, ext, at))
+                                })
+                        },
+                },
+                {
+                        name: "unwritable directory",
+                        breakDir: func(t *testing.T, dir string) {
+                                if os.Geteuid() == 0 {
+                                        t.Skip("root can write to read-only directories")
+                                }
+                                if err := os.Chmod(dir, 0555); err != nil {
+                                        t.Fatal(err)
+                                }
+                                t.Cleanup(func() { os.Chmod(dir, 0755) })
+                        },
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        dir := t.TempDir()
+                        path := filepath.Join(dir, "app.log")
+                        if err := InitLogger(LevelInfo, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        extra := filepath.Join(dir, "extra.log")
+                        if _, err := AddFileOutput(extra, FormatText); err != nil {
+                                t.Fatal(err)
+                        }
+                        if err := AddShardedOutput(dir, "shard", 2); err != nil {
+                                t.Fatal(err)
+                        }
+                        tt.breakDir(t, dir)
+
+                        if err := rotateLogFile(false); err == nil {
+                                t.Errorf("rotating the log file succeeded")
+                        }
+                        if err := rotateExtraOutputs(); err == nil {
+                                t.Errorf("rotating the additional outputs succeeded")
+                        }
+                        Info("after one")
+                        Info("after two")
+
+                        for _, p := range []string{path, extra} {
+                                if got := readLog(t, p); !strings.Contains(got, "after one") || !strings.Contains(got, "after two") {
+                                        t.Errorf("%s lacks the records logged after the failed rotation:\n%s", filepath.Base(p), got)
+                                }
+                        }
+                        shards := readLog(t, shardPath(dir, "shard", 0)) + readLog(t, shardPath(dir, "shard", 1))
+                        if !strings.Contains(shards, "after one") || !strings.Contains(shards, "after two") {
+                                t.Errorf("shards lack the records logged after the failed rotation:\n%s", shards)
+                        }
+                })
+        }
+}
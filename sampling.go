@@ -0,0 +1,76 @@
+// File: sampling.go
+// Description:
+// Keyed sampling. Records carrying a given field are sampled per value of
+// that field (e.g. per tenant_id): of every N records with the same value
+// only the first is kept, so high-volume keys are thinned out while the
+// first record of a key that rarely logs always gets through.
+
+package logger
+
+import (
+        "fmt"
+        "math"
+        "sync"
+)
+
+// maxSampleKeys bounds the number of field values counted at once; when a
+// new value would exceed it, the counts start over
+const maxSampleKeys = 4096
+
+var (
+        // Field sampled on and keep one record in sampleEvery
+        sampleField string
+        sampleEvery uint64
+        sampleMu    sync.RWMutex
+
+        // Records seen per field value
+        sampleCounts   = map[string]uint64{}
+        sampleCountsMu sync.Mutex
+)
+
+// SetKeyedSampling samples records carrying field so that roughly rate
+// (between 0 and 1) of the records of each distinct field value are kept.
+// The first record of every value is always kept. Up to 4096 values are
+// counted at once; beyond that the counts start over. Sampled out records
+// are counted in Stats.DroppedRecords. An empty field or a rate of 1 or more
+// disables sampling.
+func SetKeyedSampling(field string, rate float64) {
+        sampleMu.Lock()
+        defer sampleMu.Unlock()
+
+        if field == "" || rate >= 1 || rate <= 0 {
+                sampleField = ""
+                sampleEvery = 0
+        } else {
+                sampleField = field
+                sampleEvery = uint64(math.Round(1 / rate))
+        }
+        sampleCountsMu.Lock()
+        sampleCounts = map[string]uint64{}
+        sampleCountsMu.Unlock()
+}
+
+// sampled reports whether a record with these fields is kept
+func sampled(fields Fields) bool {
+        sampleMu.RLock()
+        field, every := sampleField, sampleEvery
+        sampleMu.RUnlock()
+        if every == 0 {
+                return true
+        }
+
+        value, ok := fields[field]
+        if !ok {
+                return true
+        }
+
+        key := fmt.Sprint(value)
+        sampleCountsMu.Lock()
+        defer sampleCountsMu.Unlock()
+        n, seen := sampleCounts[key]
+        if !seen && len(sampleCounts) >= maxSampleKeys {
+                sampleCounts = map[string]uint64{}
+        }
+        sampleCounts[key] = n + 1
+        return n%every == 0
+}
@@ -0,0 +1,105 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "fmt"
+        "strings"
+        "testing"
+)
+
+func TestKeyedSampling(t *testing.T) {
+        tests := []struct {
+                name    string
+                field   string
+                rate    float64
+                records map[string]int // Records logged per tenant, "" for none
+                want    map[string]int // Records kept per tenant
+        }{
+                {
+                        name:    "per key rate",
+                        field:   "tenant_id",
+                        rate:    0.1,
+                        records: map[string]int{"acme": 1000, "globex": 100, "initech": 3, "": 20},
+                        want:    map[string]int{"acme": 100, "globex": 10, "initech": 1, "": 20},
+                },
+                {
+                        name:    "half",
+                        field:   "tenant_id",
+                        rate:    0.5,
+                        records: map[string]int{"acme": 10, "globex": 1},
+                        want:    map[string]int{"acme": 5, "globex": 1},
+                },
+                {
+                        name:    "many rare keys",
+                        field:   "tenant_id",
+                        rate:    0.1,
+                        records: tenants(2000, 1),
+                        want:    tenants(2000, 1),
+                },
+                {
+                        name:    "more keys than counted at once",
+                        field:   "tenant_id",
+                        rate:    0.5,
+                        records: tenants(maxSampleKeys+100, 2),
+                        want:    tenants(maxSampleKeys+100, 1),
+                },
+                {
+                        name:    "disabled",
+                        field:   "tenant_id",
+                        rate:    1,
+                        records: map[string]int{"acme": 50},
+                        want:    map[string]int{"acme": 50},
+                },
+                {
+                        name:    "other field",
+                        field:   "user_id",
+                        rate:    0.1,
+                        records: map[string]int{"acme": 50},
+                        want:    map[string]int{"acme": 50},
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetKeyedSampling(tt.field, tt.rate)
+                        dropped := 0
+                        for tenant, n := range tt.records {
+                                for i := 0; i < n; i++ {
+                                        if tenant == "" {
+                                                Info("no tenant")
+                                        } else {
+                                                Infow("request", "tenant_id", tenant)
+                                        }
+                                }
+                                dropped += n - tt.want[tenant]
+                        }
+
+                        got := map[string]int{}
+                        for _, line := range out.Lines() {
+                                if i := strings.Index(line, "tenant_id="); i >= 0 {
+                                        got[line[i+len("tenant_id="):]]++
+                                } else {
+                                        got[""]++
+                                }
+                        }
+                        for tenant, want := range tt.want {
+                                if got[tenant] != want {
+                                        t.Errorf("kept %d records of %q, want %d", got[tenant], tenant, want)
+                                }
+                        }
+                        if stats := GetStats(); stats.DroppedRecords != uint64(dropped) {
+                                t.Errorf("DroppedRecords = %d, want %d", stats.DroppedRecords, dropped)
+                        }
+                })
+        }
+}
+
+// tenants returns n tenant names, each mapped to count
+func tenants(n, count int) map[string]int {
+        m := make(map[string]int, n)
+        for i := 0; i < n; i++ {
+                m[fmt.Sprintf("tenant-%d", i)] = count
+        }
+        return m
+}
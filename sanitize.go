@@ -0,0 +1,56 @@
+// File: sanitize.go
+// Description:
+// Sanitizing of messages against log injection. Untrusted input embedding
+// newlines or terminal escape sequences could forge entries or drive the
+// terminal of whoever reads the logs; with sanitizing enabled such control
+// characters are written escaped.
+
+package logger
+
+import (
+        "fmt"
+        "strings"
+        "sync/atomic"
+)
+
+// Escape control characters in messages (accessed atomically)
+var sanitizeControlChars int32
+
+// SetSanitizeControlChars escapes control characters in messages before
+// they are written: newlines and carriage returns become \n and \r, and
+// other control bytes such as the ESC starting ANSI sequences become \x1b.
+// Tabs are kept. The line separator added by the formats is not affected.
+func SetSanitizeControlChars(enabled bool) {
+        var v int32
+        if enabled {
+                v = 1
+        }
+        atomic.StoreInt32(&sanitizeControlChars, v)
+}
+
+// sanitizeMessage escapes the control characters of msg if enabled
+func sanitizeMessage(msg string) string {
+        if atomic.LoadInt32(&sanitizeControlChars) == 0 || strings.IndexFunc(msg, isControl) < 0 {
+                return msg
+        }
+
+        var b strings.Builder
+        for _, r := range msg {
+                switch {
+                case r == '\n':
+                        b.WriteString(`\n`)
+                case r == '\r':
+                        b.WriteString(`\r`)
+                case isControl(r):
+                        fmt.Fprintf(&b, `\x%02x`, r)
+                default:
+                        b.WriteRune(r)
+                }
+        }
+        return b.String()
+}
+
+// isControl reports whether r is a C0 or C1 control character other than tab
+func isControl(r rune) bool {
+        return (r < 0x20 && r != '\t') || (r >= 0x7f && r <= 0x9f)
+}
@@ -0,0 +1,42 @@
+package logger
+
+import (
+        "strings"
+        "testing"
+)
+
+func TestSanitizeControlChars(t *testing.T) {
+        tests := []struct {
+                name    string
+                enabled bool
+                input   string
+                want    string
+        }{
+                {
+                        name:    "forged entry",
+                        enabled: true,
+                        input:   "user bob\n[ERROR] 2023/03/08 10:00:00 auth.go:1: admin logged in",
+                        want:    `user bob\n[ERROR] 2023/03/08 10:00:00 auth.go:1: admin logged in`,
+                },
+                {name: "carriage return", enabled: true, input: "ok\r\nfake", want: `ok\r\nfake`},
+                {name: "ANSI escape", enabled: true, input: "\x1b[31mred\x1b[0m", want: `\x1b[31mred\x1b[0m`},
+                {name: "C1 control", enabled: true, input: "a\u009bb\x7f", want: `a\x9bb\x7f`},
+                {name: "tab kept", enabled: true, input: "a\tb", want: "a\tb"},
+                {name: "unicode kept", enabled: true, input: "héllo ✓", want: "héllo ✓"},
+                {name: "disabled", input: "a\nb", want: "a\nb"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetSanitizeControlChars(tt.enabled)
+                        Info(tt.input)
+                        got := out.String()
+                        if !strings.HasSuffix(got, tt.want+"\n") {
+                                t.Errorf("output %q doesn't end with %q", got, tt.want+"\n")
+                        }
+                        if tt.enabled && strings.Count(got, "\n") != 1 {
+                                t.Errorf("output %q is not a single line", got)
+                        }
+                })
+        }
+}
@@ -0,0 +1,264 @@
+// File: schema.go
+// Description:
+// Development-mode validation of records against a JSON schema, for teams
+// with strict log contracts. Every record is encoded as JSON and checked;
+// violations are reported to the error hook. It is meant for development
+// and tests, not for production hot paths.
+
+package logger
+
+import (
+        "encoding/json"
+        "fmt"
+        "regexp"
+        "sort"
+        "strings"
+        "sync"
+)
+
+// SchemaViolation is the error reported when a record doesn't match the
+// schema set with SetSchemaValidation
+type SchemaViolation struct {
+        Record   string   // The record, as JSON
+        Problems []string // What doesn't match, e.g. `missing required property "request_id"`
+}
+
+// Error implements error
+func (v *SchemaViolation) Error() string {
+        return "record violates schema: " + strings.Join(v.Problems, "; ")
+}
+
+// compiledSchema is a parsed schema with its patterns compiled
+type compiledSchema struct {
+        root     map[string]interface{}
+        patterns map[string]*regexp.Regexp
+}
+
+var (
+        // Schema records are validated against, nil when disabled
+        recordSchema   *compiledSchema
+        recordSchemaMu sync.RWMutex
+)
+
+// SetSchemaValidation validates every record, encoded as JSON, against
+// schema and reports a *SchemaViolation to the error hook for each record
+// that doesn't match. A subset of JSON Schema is supported: type, enum,
+// const, required, properties, additionalProperties (boolean or schema),
+// items, minLength, maxLength, pattern, minimum and maximum. A nil schema
+// disables validation.
+func SetSchemaValidation(schema []byte) error {
+        var compiled *compiledSchema
+        if schema != nil {
+                compiled = &compiledSchema{patterns: map[string]*regexp.Regexp{}}
+                if err := json.Unmarshal(schema, &compiled.root); err != nil {
+                        return fmt.Errorf("invalid schema: %v", err)
+                }
+                if err := compiled.compile(compiled.root); err != nil {
+                        return fmt.Errorf("invalid schema: %v", err)
+                }
+        }
+        recordSchemaMu.Lock()
+        defer recordSchemaMu.Unlock()
+        recordSchema = compiled
+        return nil
+}
+
+// compile compiles the patterns of a schema and its subschemas
+func (s *compiledSchema) compile(schema map[string]interface{}) error {
+        if p, ok := schema["pattern"].(string); ok {
+                re, err := regexp.Compile(p)
+                if err != nil {
+                        return err
+                }
+                s.patterns[p] = re
+        }
+        if props, ok := schema["properties"].(map[string]interface{}); ok {
+                for _, v := range props {
+                        if sub, ok := v.(map[string]interface{}); ok {
+                                if err := s.compile(sub); err != nil {
+                                        return err
+                                }
+                        }
+                }
+        }
+        for _, key := range []string{"items", "additionalProperties"} {
+                if sub, ok := schema[key].(map[string]interface{}); ok {
+                        if err := s.compile(sub); err != nil {
+                                return err
+                        }
+                }
+        }
+        return nil
+}
+
+// validateRecord checks a written record against the schema, if any
+func validateRecord(rec *Record) {
+        recordSchemaMu.RLock()
+        schema := recordSchema
+        recordSchemaMu.RUnlock()
+        if schema == nil {
+                return
+        }
+
+        outputsMu.Lock()
+        encoded, err := encoderFor(FormatJSON).Encode(*rec)
+        outputsMu.Unlock()
+        if err != nil {
+                return
+        }
+        var doc interface{}
+        if err := json.Unmarshal(encoded, &doc); err != nil {
+                return
+        }
+        if problems := schema.validate(schema.root, doc, ""); len(problems) > 0 {
+                reportError(&SchemaViolation{
+                        Record:   strings.TrimSuffix(string(encoded), "\n"),
+                        Problems: problems,
+                })
+        }
+}
+
+// validate returns the ways value doesn't match schema, the root or one of
+// its subschemas; path locates value in the record
+func (s *compiledSchema) validate(schema map[string]interface{}, value interface{}, path string) []string {
+        at := ""
+        if path != "" {
+                at = " at " + path
+        }
+
+        if t, ok := schema["type"]; ok && !matchesType(t, value) {
+                return []string{fmt.Sprintf("expected type %v%s, got %s", t, at, jsonType(value))}
+        }
+        var problems []string
+        if c, ok := schema["const"]; ok && !jsonEqual(c, value) {
+                problems = append(problems, fmt.Sprintf("expected %v%s", c, at))
+        }
+        if enum, ok := schema["enum"].([]interface{}); ok {
+                found := false
+                for _, e := range enum {
+                        if jsonEqual(e, value) {
+                                found = true
+                                break
+                        }
+                }
+                if !found {
+                        problems = append(problems, fmt.Sprintf("value %v%s is not one of %v", value, at, enum))
+                }
+        }
+
+        switch v := value.(type) {
+        case map[string]interface{}:
+                if required, ok := schema["required"].([]interface{}); ok {
+                        for _, r := range required {
+                                name, _ := r.(string)
+                                if _, ok := v[name]; !ok {
+                                        problems = append(problems, fmt.Sprintf("missing required property %q%s", name, at))
+                                }
+                        }
+                }
+                props, _ := schema["properties"].(map[string]interface{})
+                keys := make([]string, 0, len(v))
+                for k := range v {
+                        keys = append(keys, k)
+                }
+                sort.Strings(keys)
+                for _, k := range keys {
+                        if sub, ok := props[k].(map[string]interface{}); ok {
+                                problems = append(problems, s.validate(sub, v[k], joinPath(path, k))...)
+                                continue
+                        }
+                        if _, ok := props[k]; ok {
+                                continue
+                        }
+                        switch extra := schema["additionalProperties"].(type) {
+                        case bool:
+                                if !extra {
+                                        problems = append(problems, fmt.Sprintf("unexpected property %q%s", k, at))
+                                }
+                        case map[string]interface{}:
+                                problems = append(problems, s.validate(extra, v[k], joinPath(path, k))...)
+                        }
+                }
+        case []interface{}:
+                if items, ok := schema["items"].(map[string]interface{}); ok {
+                        for i, item := range v {
+                                problems = append(problems, s.validate(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+                        }
+                }
+        case string:
+                if n, ok := schema["minLength"].(float64); ok && float64(len([]rune(v))) < n {
+                        problems = append(problems, fmt.Sprintf("string%s shorter than %v", at, n))
+                }
+                if n, ok := schema["maxLength"].(float64); ok && float64(len([]rune(v))) > n {
+                        problems = append(problems, fmt.Sprintf("string%s longer than %v", at, n))
+                }
+                if p, ok := schema["pattern"].(string); ok {
+                        if re := s.patterns[p]; re != nil && !re.MatchString(v) {
+                                problems = append(problems, fmt.Sprintf("string%s does not match %q", at, p))
+                        }
+                }
+        case float64:
+                if n, ok := schema["minimum"].(float64); ok && v < n {
+                        problems = append(problems, fmt.Sprintf("number%s below %v", at, n))
+                }
+                if n, ok := schema["maximum"].(float64); ok && v > n {
+                        problems = append(problems, fmt.Sprintf("number%s above %v", at, n))
+                }
+        }
+        return problems
+}
+
+// matchesType reports whether value has the JSON type t, a name or a list
+// of names
+func matchesType(t interface{}, value interface{}) bool {
+        switch t := t.(type) {
+        case string:
+                actual := jsonType(value)
+                return actual == t || (t == "number" && actual == "integer")
+        case []interface{}:
+                for _, name := range t {
+                        if matchesType(name, value) {
+                                return true
+                        }
+                }
+                return false
+        }
+        return true
+}
+
+// jsonType returns the JSON schema type name of a decoded value
+func jsonType(value interface{}) string {
+        switch v := value.(type) {
+        case nil:
+                return "null"
+        case bool:
+                return "boolean"
+        case string:
+                return "string"
+        case float64:
+                if v == float64(int64(v)) {
+                        return "integer"
+                }
+                return "number"
+        case []interface{}:
+                return "array"
+        case map[string]interface{}:
+                return "object"
+        }
+        return "unknown"
+}
+
+// jsonEqual reports whether two decoded JSON values are equal
+func jsonEqual(a, b interface{}) bool {
+        x, _ := json.Marshal(a)
+        y, _ := json.Marshal(b)
+        return string(x) == string(y)
+}
+
+// joinPath appends a property name to a path
+func joinPath(path, key string) string {
+        if path == "" {
+                return key
+        }
+        return path + "." + key
+}
@@ -0,0 +1,105 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "errors"
+        "fmt"
+        "strings"
+        "testing"
+)
+
+func TestSchemaValidation(t *testing.T) {
+        const requestIDSchema = `{
+		"type": "object",
+		"required": ["message", "request_id"],
+		"properties": {
+			"level": {"enum": ["info", "warning", "error"]},
+			"request_id": {"type": "string", "pattern": "^req-[0-9]+$"},
+			"status": {"type": "integer", "minimum": 100, "maximum": 599}
+		}
+	}`
+        tests := []struct {
+                name   string
+                schema string
+                fields Fields
+                want   []string // Problems reported
+        }{
+                {name: "valid", schema: requestIDSchema, fields: Fields{"request_id": "req-42", "status": 200}},
+                {name: "missing request id", schema: requestIDSchema, want: []string{`missing required property "request_id"`}},
+                {
+                        name:   "wrong type",
+                        schema: requestIDSchema,
+                        fields: Fields{"request_id": 42},
+                        want:   []string{"expected type string at request_id, got integer"},
+                },
+                {
+                        name:   "pattern and range",
+                        schema: requestIDSchema,
+                        fields: Fields{"request_id": "abc", "status": 700},
+                        want:   []string{`string at request_id does not match "^req-[0-9]+$"`, "number at status above 599"},
+                },
+                {
+                        name:   "no additional properties",
+                        schema: `{"properties": {"time": {}, "level": {}, "caller": {}, "message": {}}, "additionalProperties": false}`,
+                        fields: Fields{"user": "alice"},
+                        want:   []string{`unexpected property "user"`},
+                },
+                {name: "disabled", fields: Fields{"user": "alice"}},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        var violations []*SchemaViolation
+                        SetErrorHook(func(err error) {
+                                var v *SchemaViolation
+                                if errors.As(err, &v) {
+                                        violations = append(violations, v)
+                                }
+                        })
+                        var schema []byte
+                        if tt.schema != "" {
+                                schema = []byte(tt.schema)
+                        }
+                        if err := SetSchemaValidation(schema); err != nil {
+                                t.Fatal(err)
+                        }
+                        WithFields(tt.fields).Info("handled")
+
+                        if len(tt.want) == 0 {
+                                if len(violations) != 0 {
+                                        t.Errorf("unexpected violations %v", violations)
+                                }
+                                return
+                        }
+                        if len(violations) != 1 {
+                                t.Fatalf("got %d violations, want 1", len(violations))
+                        }
+                        v := violations[0]
+                        if fmt.Sprint(v.Problems) != fmt.Sprint(tt.want) {
+                                t.Errorf("problems %q, want %q", v.Problems, tt.want)
+                        }
+                        if !strings.Contains(v.Record, `"message":"handled"`) {
+                                t.Errorf("violation record %s", v.Record)
+                        }
+                })
+        }
+}
+
+func TestSchemaValidationInvalid(t *testing.T) {
+        tests := []struct {
+                name   string
+                schema string
+        }{
+                {name: "not JSON", schema: `{"type":`},
+                {name: "bad pattern", schema: `{"properties": {"id": {"pattern": "("}}}`},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        if err := SetSchemaValidation([]byte(tt.schema)); err == nil {
+                                t.Errorf("schema %s accepted", tt.schema)
+                        }
+                })
+        }
+}
@@ -0,0 +1,71 @@
+// File: shutdown.go
+// Description:
+// Controlled shutdown on fatal conditions. Servers can register shutdown
+// functions that FatalCtx runs (bounded by the context deadline) before the
+// process exits, so in-flight work gets a chance to drain.
+
+package logger
+
+import (
+        "context"
+        "sync"
+)
+
+var (
+        // Functions run by FatalCtx before exiting
+        shutdownFuncs   []func(ctx context.Context)
+        shutdownFuncsMu sync.Mutex
+)
+
+// RegisterShutdown registers a function that FatalCtx runs before exiting.
+// The function receives the FatalCtx context and should return once its
+// cleanup is done or the context is cancelled.
+func RegisterShutdown(fn func(ctx context.Context)) {
+        shutdownFuncsMu.Lock()
+        defer shutdownFuncsMu.Unlock()
+        shutdownFuncs = append(shutdownFuncs, fn)
+}
+
+// FatalCtx logs a fatal message, runs the registered shutdown functions and
+// waits for them until the context is done before exiting the program
+func FatalCtx(ctx context.Context, v ...interface{}) {
+        logWithCallerInfo(LevelFatal, nil, "", v...)
+        runShutdown(ctx)
+        exitFunc(FatalExitCode())
+}
+
+// FatalfCtx logs a formatted fatal message, runs the registered shutdown
+// functions and waits for them until the context is done before exiting
+func FatalfCtx(ctx context.Context, format string, v ...interface{}) {
+        logWithCallerInfo(LevelFatal, nil, format, v...)
+        runShutdown(ctx)
+        exitFunc(FatalExitCode())
+}
+
+// runShutdown runs all shutdown functions concurrently and waits for them
+// to finish or for the context to be done, whichever comes first
+func runShutdown(ctx context.Context) {
+        shutdownFuncsMu.Lock()
+        funcs := append([]func(ctx context.Context){}, shutdownFuncs...)
+        shutdownFuncsMu.Unlock()
+
+        var wg sync.WaitGroup
+        for _, fn := range funcs {
+                wg.Add(1)
+                go func(fn func(ctx context.Context)) {
+                        defer wg.Done()
+                        fn(ctx)
+                }(fn)
+        }
+
+        done := make(chan struct{})
+        go func() {
+                wg.Wait()
+                close(done)
+        }()
+
+        select {
+        case <-done:
+        case <-ctx.Done():
+        }
+}
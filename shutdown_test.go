@@ -0,0 +1,51 @@
+package logger
+
+import (
+        "context"
+        "testing"
+        "time"
+)
+
+func TestFatalCtx(t *testing.T) {
+        tests := []struct {
+                name      string
+                block     bool // The cleanup waits past the deadline
+                wantClean bool // The cleanup finished before the exit
+        }{
+                {name: "cleanup runs before the exit", wantClean: true},
+                {name: "exit at the deadline", block: true},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        exitCodes := stubExit(t)
+                        release := make(chan struct{})
+                        defer close(release)
+                        cleaned := make(chan bool, 1)
+                        RegisterShutdown(func(ctx context.Context) {
+                                if tt.block {
+                                        <-release
+                                }
+                                cleaned <- len(exitCodes()) == 0
+                        })
+
+                        ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+                        defer cancel()
+                        FatalCtx(ctx, "shutting down")
+
+                        if codes := exitCodes(); len(codes) != 1 || codes[0] != 1 {
+                                t.Fatalf("exit codes %v, want [1]", codes)
+                        }
+                        select {
+                        case beforeExit := <-cleaned:
+                                if !tt.wantClean || !beforeExit {
+                                        t.Errorf("cleanup finished, before the exit: %v", beforeExit)
+                                }
+                        default:
+                                if tt.wantClean {
+                                        t.Error("exited before the cleanup finished")
+                                }
+                        }
+                })
+        }
+}
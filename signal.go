@@ -0,0 +1,151 @@
+// File: signal.go
+// Description:
+// Signal handling. Rotation on demand, for containers without logrotate: an
+// operator can rotate the log file with e.g. `kill -USR2 <pid>`. Flushing
+// on SIGINT/SIGTERM, so Ctrl-C doesn't lose held or queued records.
+
+package logger
+
+import (
+        "context"
+        "os"
+        "os/signal"
+        "sync"
+        "syscall"
+        "time"
+)
+
+// shutdownFlushTimeout bounds the shutdown functions run by ShutdownFlush
+const shutdownFlushTimeout = 5 * time.Second
+
+var (
+        // Stop functions of the installed signal handlers
+        signalStops   []func()
+        signalStopsMu sync.Mutex
+)
+
+// RotateOnSignal installs a handler rotating the log file (as
+// RotateLogFile) every time sig arrives, e.g. syscall.SIGUSR2. Handlers for
+// different signals are independent of each other. Rotation errors are
+// passed to the error hook. The returned function removes the handler.
+func RotateOnSignal(sig os.Signal) (stop func()) {
+        ch := make(chan os.Signal, 1)
+        done := make(chan struct{})
+        signal.Notify(ch, sig)
+
+        go func() {
+                for {
+                        select {
+                        case <-ch:
+                                if err := RotateLogFile(); err != nil {
+                                        reportError(err)
+                                }
+                        case <-done:
+                                return
+                        }
+                }
+        }()
+
+        var once sync.Once
+        stop = func() {
+                once.Do(func() {
+                        signal.Stop(ch)
+                        close(done)
+                })
+        }
+
+        signalStopsMu.Lock()
+        signalStops = append(signalStops, stop)
+        signalStopsMu.Unlock()
+        return stop
+}
+
+// ShutdownFlush writes the records held while paused, runs the registered
+// shutdown functions (for at most 5 seconds) and closes the logger, which
+// drains the webhook queues and flushes the compressed log file. It is meant
+// for applications handling the signals themselves, once they are done
+// logging.
+func ShutdownFlush() {
+        Resume()
+        ctx, cancel := context.WithTimeout(context.Background(), shutdownFlushTimeout)
+        defer cancel()
+        runShutdown(ctx)
+        CloseLogger()
+}
+
+// InstallShutdownFlush installs a handler for SIGINT and SIGTERM that writes
+// the records held while paused, runs the registered shutdown functions (for
+// at most 5 seconds) and flushes the log file to disk. Unlike ShutdownFlush
+// it leaves the logger open, so the application's own handlers can still
+// log; records queued for webhooks are only drained by closing the logger.
+// The handler then removes itself and raises the signal again, so the
+// default behavior (termination) proceeds unless the application has its
+// own handler for it, which then receives the signal a second time. The
+// returned function removes the handler.
+func InstallShutdownFlush() (stop func()) {
+        ch := make(chan os.Signal, 1)
+        done := make(chan struct{})
+        signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+
+        var once sync.Once
+        stop = func() {
+                once.Do(func() {
+                        signal.Stop(ch)
+                        close(done)
+                })
+        }
+
+        go func() {
+                select {
+                case sig := <-ch:
+                        flushOnSignal()
+                        stop()
+                        raise(sig)
+                case <-done:
+                }
+        }()
+
+        signalStopsMu.Lock()
+        signalStops = append(signalStops, stop)
+        signalStopsMu.Unlock()
+        return stop
+}
+
+// flushOnSignal is ShutdownFlush without closing the logger
+func flushOnSignal() {
+        Resume()
+        ctx, cancel := context.WithTimeout(context.Background(), shutdownFlushTimeout)
+        defer cancel()
+        runShutdown(ctx)
+
+        outputsMu.Lock()
+        err := syncLogFile()
+        outputsMu.Unlock()
+        if err != nil {
+                reportError(err)
+        }
+}
+
+// raise sends sig to the current process, exiting if that isn't supported
+func raise(sig os.Signal) {
+        p, err := os.FindProcess(os.Getpid())
+        if err == nil {
+                err = p.Signal(sig)
+        }
+        if err != nil {
+                exitFunc(1)
+        }
+}
+
+// stopSignalHandlers removes every handler installed by RotateOnSignal and
+// InstallShutdownFlush
+func stopSignalHandlers() {
+        signalStopsMu.Lock()
+        stops := signalStops
+        signalStops = nil
+        signalStopsMu.Unlock()
+
+        for _, stop := range stops {
+                stop()
+        }
+}
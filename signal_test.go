@@ -0,0 +1,146 @@
+//go:build linux || darwin
+
+package logger
+
+import (
+        "context"
+        "fmt"
+        "os"
+        "os/signal"
+        "strings"
+        "syscall"
+        "testing"
+        "time"
+)
+
+func TestRotateOnSignal(t *testing.T) {
+        tests := []struct {
+                name     string
+                handlers []os.Signal
+                send     []os.Signal
+        }{
+                {name: "one signal", handlers: []os.Signal{syscall.SIGUSR2}, send: []os.Signal{syscall.SIGUSR2}},
+                {name: "repeated signal", handlers: []os.Signal{syscall.SIGUSR2}, send: []os.Signal{syscall.SIGUSR2, syscall.SIGUSR2}},
+                {
+                        name:     "independent handlers",
+                        handlers: []os.Signal{syscall.SIGUSR1, syscall.SIGUSR2},
+                        send:     []os.Signal{syscall.SIGUSR1, syscall.SIGUSR2},
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        clock := useFakeClock(t)
+                        path := tempLogPath(t, "app.log")
+                        if err := InitLogger(LevelInfo, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        rotated := make(chan string, len(tt.send))
+                        SetOnRotate(func(oldPath, newPath string) { rotated <- oldPath })
+                        for _, sig := range tt.handlers {
+                                RotateOnSignal(sig)
+                        }
+
+                        for i, sig := range tt.send {
+                                record := fmt.Sprintf("record %d", i)
+                                Info(record)
+                                if err := syscall.Kill(os.Getpid(), sig.(syscall.Signal)); err != nil {
+                                        t.Fatal(err)
+                                }
+                                var archive string
+                                select {
+                                case archive = <-rotated:
+                                case <-time.After(5 * time.Second):
+                                        t.Fatalf("no rotation after %v", sig)
+                                }
+                                if got := readLog(t, archive); !strings.Contains(got, record) {
+                                        t.Errorf("archive %s content %q", archive, got)
+                                }
+                                if got := readLog(t, path); strings.Contains(got, record) {
+                                        t.Errorf("active file not new after %v: %q", sig, got)
+                                }
+                                clock.Advance(time.Second)
+                        }
+                })
+        }
+}
+
+func TestShutdownFlush(t *testing.T) {
+        tests := []struct {
+                name   string
+                paused bool
+                setup  func()
+        }{
+                {name: "buffered records", setup: func() { SetLevelBuffer(LevelInfo, 1<<20) }},
+                {name: "records held while paused", paused: true, setup: func() { SetPauseBuffer(10); Pause() }},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        if tt.paused && !pausedRecordsHeld {
+                                t.Skip("records logged while paused are dropped in this build")
+                        }
+                        captureOutput(t)
+                        path := tempLogPath(t, "app.log")
+                        if err := InitLogger(LevelInfo, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        shutdown := false
+                        RegisterShutdown(func(ctx context.Context) { shutdown = true })
+                        tt.setup()
+                        Info("pending record")
+                        if got := readLog(t, path); strings.Contains(got, "pending record") {
+                                t.Fatalf("record written before the flush: %q", got)
+                        }
+
+                        ShutdownFlush()
+                        if got := readLog(t, path); !strings.Contains(got, "pending record") {
+                                t.Errorf("log file %q after ShutdownFlush", got)
+                        }
+                        if !shutdown {
+                                t.Error("shutdown functions not run")
+                        }
+                        if LogFilePath() != "" {
+                                t.Error("logger not closed")
+                        }
+                })
+        }
+}
+
+func TestInstallShutdownFlush(t *testing.T) {
+        captureOutput(t)
+        path := tempLogPath(t, "app.log")
+        if err := InitLogger(LevelInfo, true, path); err != nil {
+                t.Fatal(err)
+        }
+        SetLevelBuffer(LevelInfo, 1<<20)
+        Info("pending record")
+
+        // The application's own handler keeps SIGTERM from ending the test and
+        // gets the signal again once the logger is flushed; it can still log
+        own := make(chan os.Signal, 2)
+        signal.Notify(own, syscall.SIGTERM)
+        defer signal.Stop(own)
+        InstallShutdownFlush()
+
+        if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+                t.Fatal(err)
+        }
+        for i := 0; i < 2; i++ {
+                select {
+                case <-own:
+                case <-time.After(5 * time.Second):
+                        t.Fatalf("application handler got the signal %d times, want 2", i)
+                }
+        }
+        if got := readLog(t, path); !strings.Contains(got, "pending record") {
+                t.Errorf("log file %q after SIGTERM", got)
+        }
+        if LogFilePath() != path {
+                t.Fatal("logger closed by the signal handler")
+        }
+        SetLevelBuffer(LevelInfo, 0)
+        Info("stopping")
+        if got := readLog(t, path); !strings.Contains(got, "stopping") {
+                t.Errorf("log file %q after logging in the application handler", got)
+        }
+}
@@ -0,0 +1,103 @@
+// File: slog.go
+// Description:
+// log/slog integration. SlogHandler is a slog.Handler writing through this
+// package, so slog calls get the same levels, formats, outputs and rotation
+// as the rest of the logs; InstallAsSlogDefault makes it slog's default.
+
+package logger
+
+import (
+        "context"
+        "log/slog"
+        "runtime"
+)
+
+// SlogHandler is a slog.Handler logging through this package. Attributes
+// become fields, with group names prepended and separated by dots.
+type SlogHandler struct {
+        fields Fields
+        prefix string // Group prefix of the attributes, e.g. "request."
+}
+
+// NewSlogHandler returns a handler logging slog records through this package
+func NewSlogHandler() *SlogHandler {
+        return &SlogHandler{}
+}
+
+// InstallAsSlogDefault makes a SlogHandler the handler of slog's default
+// logger, so slog.Info and friends log through this package. The level set
+// with SetLevel applies to them. Note that slog.SetDefault also routes the
+// standard log package through the handler.
+func InstallAsSlogDefault() {
+        slog.SetDefault(slog.New(NewSlogHandler()))
+}
+
+// Enabled implements slog.Handler
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+        return Enabled(levelFromSlog(level))
+}
+
+// Handle implements slog.Handler. The caller and time are the ones recorded
+// by slog.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+        level := levelFromSlog(r.Level)
+        if level < minEnabledLevel() {
+                return nil
+        }
+
+        fields := make(Fields, len(h.fields)+r.NumAttrs())
+        for k, v := range h.fields {
+                fields[k] = v
+        }
+        r.Attrs(func(a slog.Attr) bool {
+                addSlogAttr(fields, h.prefix, a)
+                return true
+        })
+
+        var file string
+        var line int
+        if r.PC != 0 {
+                frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+                file, line = frame.File, frame.Line
+        }
+        emitAt(nil, 1, r.Time, r.PC, file, line, level, fields, "", r.Message)
+        return nil
+}
+
+// WithAttrs implements slog.Handler
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+        fields := make(Fields, len(h.fields)+len(attrs))
+        for k, v := range h.fields {
+                fields[k] = v
+        }
+        for _, a := range attrs {
+                addSlogAttr(fields, h.prefix, a)
+        }
+        return &SlogHandler{fields: fields, prefix: h.prefix}
+}
+
+// WithGroup implements slog.Handler
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+        if name == "" {
+                return h
+        }
+        return &SlogHandler{fields: h.fields, prefix: h.prefix + name + "."}
+}
+
+// addSlogAttr adds an attribute to fields, flattening groups
+func addSlogAttr(fields Fields, prefix string, a slog.Attr) {
+        value := a.Value.Resolve()
+        if value.Kind() == slog.KindGroup {
+                if a.Key != "" {
+                        prefix += a.Key + "."
+                }
+                for _, member := range value.Group() {
+                        addSlogAttr(fields, prefix, member)
+                }
+                return
+        }
+        if a.Key == "" {
+                return
+        }
+        fields[prefix+a.Key] = value.Any()
+}
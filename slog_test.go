@@ -0,0 +1,127 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "context"
+        "fmt"
+        "log"
+        "log/slog"
+        "strings"
+        "testing"
+        "time"
+)
+
+// installSlogDefault installs the handler as slog's default for the
+// duration of the test
+func installSlogDefault(t *testing.T) {
+        t.Helper()
+        prev, writer, flags := slog.Default(), log.Writer(), log.Flags()
+        InstallAsSlogDefault()
+        t.Cleanup(func() {
+                slog.SetDefault(prev)
+                log.SetOutput(writer)
+                log.SetFlags(flags)
+        })
+}
+
+func TestInstallAsSlogDefault(t *testing.T) {
+        tests := []struct {
+                name  string
+                level int
+                log   func() int // Logs and returns the line of the call
+                want  string     // Expected file line suffix, "" for none
+        }{
+                {
+                        name:  "info",
+                        level: LevelInfo,
+                        log: func() int {
+                                slog.Info("user login", "user", "alice", "attempt", 2)
+                                return thisLine() - 1
+                        },
+                        want: "user login attempt=2 user=alice",
+                },
+                {
+                        name:  "group",
+                        level: LevelInfo,
+                        log: func() int {
+                                slog.Default().WithGroup("req").Warn("slow", "ms", 900)
+                                return thisLine() - 1
+                        },
+                        want: "slow req.ms=900",
+                },
+                {
+                        name:  "filtered by SetLevel",
+                        level: LevelWarning,
+                        log: func() int {
+                                slog.Info("user login")
+                                return thisLine() - 1
+                        },
+                },
+                {
+                        name:  "debug enabled by SetLevel",
+                        level: LevelDebug,
+                        log: func() int {
+                                slog.Debug("cache miss", "key", "k1")
+                                return thisLine() - 1
+                        },
+                        want: "cache miss key=k1",
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        if tt.level == LevelDebug {
+                                requireDebug(t)
+                        }
+                        captureOutput(t)
+                        path := tempLogPath(t, "app.log")
+                        if err := InitLogger(LevelInfo, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        installSlogDefault(t)
+                        SetLevel(tt.level)
+                        line := tt.log()
+
+                        got := strings.TrimSuffix(readLog(t, path), "\n")
+                        if tt.want == "" {
+                                if got != "" {
+                                        t.Errorf("log file holds %q, want nothing", got)
+                                }
+                                return
+                        }
+                        if !strings.HasSuffix(got, tt.want) || strings.Contains(got, "\n") {
+                                t.Errorf("log file holds %q, want one line ending with %q", got, tt.want)
+                        }
+                        if caller := fmt.Sprintf(" slog_test.go:%d: ", line); !strings.Contains(got, caller) {
+                                t.Errorf("log file line %q lacks the caller %q", got, caller)
+                        }
+                })
+        }
+}
+
+func TestSlogHandlerTime(t *testing.T) {
+        recorded := time.Date(2022, 11, 2, 8, 30, 0, 0, time.UTC)
+        tests := []struct {
+                name string
+                time time.Time // Time of the slog record
+                want string
+        }{
+                {name: "recorded time", time: recorded, want: "2022-11-02T08:30:00Z"},
+                {name: "no time", want: "2023-03-08T10:00:00Z"}, // The package clock
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        useFakeClock(t)
+                        SetFormat(FormatJSON)
+                        r := slog.NewRecord(tt.time, slog.LevelInfo, "replayed", 0)
+                        if err := NewSlogHandler().Handle(context.Background(), r); err != nil {
+                                t.Fatal(err)
+                        }
+                        records := out.Records(t)
+                        if len(records) != 1 || records[0]["time"] != tt.want {
+                                t.Errorf("records %v, want one at %s", records, tt.want)
+                        }
+                })
+        }
+}
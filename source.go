@@ -0,0 +1,73 @@
+// File: source.go
+// Description:
+// Experimental source line capture. With SetIncludeSourceLine the text of
+// the calling line is read from the source file and attached to the record.
+// It only works where the sources are available at the path recorded at
+// build time (not in trimmed or shipped binaries), and files are read once
+// and cached.
+
+package logger
+
+import (
+        "bufio"
+        "os"
+        "strings"
+        "sync"
+        "sync/atomic"
+)
+
+// SourceKey is the field name of the calling source line
+const SourceKey = "source"
+
+var (
+        // Attach the calling source line to every record (accessed atomically)
+        includeSourceLine int32
+
+        // Lines of the source files read so far, nil for unreadable files
+        sourceFiles   = map[string][]string{}
+        sourceFilesMu sync.Mutex
+)
+
+// SetIncludeSourceLine attaches the text of the calling source line to
+// every record in a "source" field, when the source file can be read.
+// It is meant for debugging sessions: the first record from each file
+// reads the whole file.
+func SetIncludeSourceLine(enabled bool) {
+        var v int32
+        if enabled {
+                v = 1
+        }
+        atomic.StoreInt32(&includeSourceLine, v)
+}
+
+// withSourceLine adds the source text at file:line to fields if enabled
+func withSourceLine(fields Fields, file string, line int) Fields {
+        if atomic.LoadInt32(&includeSourceLine) == 0 || file == "" {
+                return fields
+        }
+        lines := sourceLines(file)
+        if line < 1 || line > len(lines) {
+                return fields
+        }
+        return withField(fields, SourceKey, strings.TrimSpace(lines[line-1]))
+}
+
+// sourceLines returns the lines of a source file, reading it on first use
+func sourceLines(file string) []string {
+        sourceFilesMu.Lock()
+        defer sourceFilesMu.Unlock()
+        if lines, ok := sourceFiles[file]; ok {
+                return lines
+        }
+
+        var lines []string
+        if f, err := os.Open(file); err == nil {
+                scanner := bufio.NewScanner(f)
+                for scanner.Scan() {
+                        lines = append(lines, scanner.Text())
+                }
+                f.Close()
+        }
+        sourceFiles[file] = lines
+        return lines
+}
@@ -0,0 +1,80 @@
+//go:build !logger_minimal
+
+package logger
+
+import "testing"
+
+func TestIncludeSourceLine(t *testing.T) {
+        tests := []struct {
+                name    string
+                enabled bool
+                log     func()
+                want    interface{} // nil for no source field
+        }{
+                {
+                        name:    "Info",
+                        enabled: true,
+                        log: func() {
+                                Info("plain call")
+                        },
+                        want: `Info("plain call")`,
+                },
+                {
+                        name:    "Infof",
+                        enabled: true,
+                        log: func() {
+                                Infof("formatted %d", 42) // with a comment
+                        },
+                        want: `Infof("formatted %d", 42) // with a comment`,
+                },
+                {
+                        name:    "entry",
+                        enabled: true,
+                        log: func() {
+                                WithField("k", "v").Error("entry call")
+                        },
+                        want: `WithField("k", "v").Error("entry call")`,
+                },
+                {
+                        name: "disabled",
+                        log: func() {
+                                Info("plain call")
+                        },
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetFormat(FormatJSON)
+                        SetIncludeSourceLine(tt.enabled)
+                        tt.log()
+                        tt.log() // Served from the cache
+                        for _, record := range out.Records(t) {
+                                if got := record[SourceKey]; got != tt.want {
+                                        t.Errorf("source %v, want %v", got, tt.want)
+                                }
+                        }
+                })
+        }
+}
+
+func TestSourceLineUnavailable(t *testing.T) {
+        captureOutput(t)
+        SetIncludeSourceLine(true)
+        tests := []struct {
+                name string
+                file string
+                line int
+        }{
+                {name: "missing file", file: "/nonexistent/main.go", line: 3},
+                {name: "line past the end", file: "source_test.go", line: 100000},
+                {name: "no file", line: 3},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        if fields := withSourceLine(nil, tt.file, tt.line); fields[SourceKey] != nil {
+                                t.Errorf("source %v attached", fields[SourceKey])
+                        }
+                })
+        }
+}
@@ -0,0 +1,153 @@
+// File: stack.go
+// Description:
+// Stack traces. Records at or above a configurable level carry the stack of
+// the logging goroutine in a "stack" field. Frames of the standard library
+// and of the logger itself are left out by default so traces show only
+// application code; SetStackFilter customizes the selection.
+
+package logger
+
+import (
+        "errors"
+        "path/filepath"
+        "reflect"
+        "runtime"
+        "strconv"
+        "strings"
+        "sync"
+        "sync/atomic"
+)
+
+// StackKey is the field name of the stack trace
+const StackKey = "stack"
+
+// maxStackDepth is the maximum number of frames captured
+const maxStackDepth = 64
+
+var (
+        // Minimum level of records carrying a stack trace, negative to disable
+        // (accessed atomically)
+        stackTraceLevel int32 = -1
+
+        // Decides which frames appear in stack traces, nil for the default
+        stackFilter   func(frame runtime.Frame) bool
+        stackFilterMu sync.RWMutex
+
+        // Function name prefix of this package and its subpackages
+        packagePrefix = loggerPackagePath()
+
+        // Source directory of the standard library
+        goRootSrc = filepath.ToSlash(filepath.Join(runtime.GOROOT(), "src")) + "/"
+)
+
+// SetStackTraceLevel attaches a stack trace to records at or above level.
+// A negative level (the default) disables stack traces.
+func SetStackTraceLevel(level int) {
+        atomic.StoreInt32(&stackTraceLevel, int32(level))
+}
+
+// SetStackFilter replaces the selection of frames in stack traces: fn
+// returns true for frames to keep. Passing nil restores the default, which
+// drops frames of the standard library and of the logger package.
+func SetStackFilter(fn func(frame runtime.Frame) bool) {
+        stackFilterMu.Lock()
+        defer stackFilterMu.Unlock()
+        stackFilter = fn
+}
+
+// loggerPackagePath returns the import path of this package
+func loggerPackagePath() string {
+        name := runtime.FuncForPC(reflect.ValueOf(SetStackFilter).Pointer()).Name()
+        return strings.TrimSuffix(name, ".SetStackFilter")
+}
+
+// defaultStackFilter keeps frames outside the standard library and the logger
+func defaultStackFilter(frame runtime.Frame) bool {
+        if strings.HasPrefix(frame.Function, packagePrefix+".") || strings.HasPrefix(frame.Function, packagePrefix+"/") {
+                return false
+        }
+        return !strings.HasPrefix(filepath.ToSlash(frame.File), goRootSrc)
+}
+
+// withStack adds the current stack trace to fields if enabled for level.
+// A stack already attached (by WithError) is kept. skip is the number of
+// frames to omit, starting with the caller of withStack.
+func withStack(fields Fields, level int, skip int) Fields {
+        if !stackTraceEnabled(level) {
+                return fields
+        }
+        if _, ok := fields[StackKey]; ok {
+                return fields
+        }
+        return withField(fields, StackKey, captureStack(skip+1))
+}
+
+// stackTraceEnabled reports whether records of level carry a stack trace
+func stackTraceEnabled(level int) bool {
+        min := atomic.LoadInt32(&stackTraceLevel)
+        return min >= 0 && int32(level) >= min
+}
+
+// errorStack returns the stack trace carried by err or an error it wraps
+// (a StackTrace() method, as in github.com/pkg/errors), or else the current
+// stack. skip is the number of frames to omit, starting with the caller of
+// errorStack.
+func errorStack(err error, skip int) string {
+        for e := err; e != nil; e = errors.Unwrap(e) {
+                m := reflect.ValueOf(e).MethodByName("StackTrace")
+                if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+                        continue
+                }
+                trace := m.Call(nil)[0]
+                if trace.Kind() != reflect.Slice || trace.Type().Elem().Kind() != reflect.Uintptr {
+                        continue
+                }
+                pcs := make([]uintptr, trace.Len())
+                for i := range pcs {
+                        pcs[i] = uintptr(trace.Index(i).Uint())
+                }
+                return renderStack(pcs)
+        }
+        return captureStack(skip + 1)
+}
+
+// captureStack renders the current stack, keeping the frames selected by
+// the stack filter. skip is the number of frames to omit, starting with the
+// caller of captureStack.
+func captureStack(skip int) string {
+        pcs := make([]uintptr, maxStackDepth)
+        n := runtime.Callers(skip+2, pcs)
+        return renderStack(pcs[:n])
+}
+
+// renderStack renders the frames of program counters as returned by
+// runtime.Callers, keeping the frames selected by the stack filter
+func renderStack(pcs []uintptr) string {
+        stackFilterMu.RLock()
+        keep := stackFilter
+        stackFilterMu.RUnlock()
+        if keep == nil {
+                keep = defaultStackFilter
+        }
+
+        frames := runtime.CallersFrames(pcs)
+
+        var b strings.Builder
+        for {
+                frame, more := frames.Next()
+                if keep(frame) {
+                        if b.Len() > 0 {
+                                b.WriteByte('\n')
+                        }
+                        b.WriteString(frame.Function)
+                        b.WriteString("\n\t")
+                        b.WriteString(frame.File)
+                        b.WriteByte(':')
+                        b.WriteString(strconv.Itoa(frame.Line))
+                }
+                if !more {
+                        break
+                }
+        }
+        return b.String()
+}
@@ -0,0 +1,105 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "path/filepath"
+        "runtime"
+        "strings"
+        "testing"
+)
+
+// stackFiles returns the files of the frames of a rendered stack
+func stackFiles(stack string) []string {
+        var files []string
+        for _, line := range strings.Split(stack, "\n") {
+                if strings.HasPrefix(line, "\t") {
+                        files = append(files, strings.TrimPrefix(line, "\t"))
+                }
+        }
+        return files
+}
+
+func TestDefaultStackFilter(t *testing.T) {
+        tests := []struct {
+                name  string
+                frame runtime.Frame
+                want  bool
+        }{
+                {
+                        name:  "logger function",
+                        frame: runtime.Frame{Function: packagePrefix + ".Info", File: "/src/logger/logger.go"},
+                },
+                {
+                        name:  "logger method",
+                        frame: runtime.Frame{Function: packagePrefix + ".(*Entry).Info", File: "/src/logger/fields.go"},
+                },
+                {
+                        name:  "logger subpackage",
+                        frame: runtime.Frame{Function: packagePrefix + "/logtest.AssertLogged", File: "/src/logger/logtest/logtest.go"},
+                },
+                {
+                        name:  "standard library",
+                        frame: runtime.Frame{Function: "net/http.HandlerFunc.ServeHTTP", File: filepath.Join(runtime.GOROOT(), "src", "net", "http", "server.go")},
+                },
+                {
+                        name:  "application code",
+                        frame: runtime.Frame{Function: "example.com/app.handle", File: "/src/app/main.go"},
+                        want:  true,
+                },
+                {
+                        name:  "package sharing the prefix",
+                        frame: runtime.Frame{Function: packagePrefix + "x.Run", File: "/src/loggerx/run.go"},
+                        want:  true,
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        if got := defaultStackFilter(tt.frame); got != tt.want {
+                                t.Errorf("defaultStackFilter(%s) = %v, want %v", tt.frame.Function, got, tt.want)
+                        }
+                })
+        }
+}
+
+func TestStackFilter(t *testing.T) {
+        // The tests are part of the logger package, so keep their own frames
+        // to stand in for application code
+        withTests := func(frame runtime.Frame) bool {
+                return strings.HasSuffix(frame.File, "_test.go") || defaultStackFilter(frame)
+        }
+        tests := []struct {
+                name     string
+                filter   func(runtime.Frame) bool
+                internal bool // logger and standard library frames expected
+        }{
+                {name: "default with test frames", filter: withTests},
+                {name: "keep all", filter: func(runtime.Frame) bool { return true }, internal: true},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetFormat(FormatJSON)
+                        SetStackTraceLevel(LevelError)
+                        SetStackFilter(tt.filter)
+                        Error("boom")
+                        WithField("k", "v").Error("boom")
+                        for _, record := range out.Records(t) {
+                                stack, _ := record[StackKey].(string)
+                                files := stackFiles(stack)
+                                if len(files) == 0 || !strings.Contains(files[0], "stack_test.go") {
+                                        t.Fatalf("stack doesn't start at the caller:\n%s", stack)
+                                }
+                                internal := false
+                                for _, file := range files {
+                                        if !strings.Contains(file, "_test.go") {
+                                                internal = true
+                                        }
+                                }
+                                if internal != tt.internal {
+                                        t.Errorf("internal frames present = %v, want %v:\n%s", internal, tt.internal, stack)
+                                }
+                        }
+                })
+        }
+}
@@ -0,0 +1,128 @@
+// File: stats.go
+// Description:
+// Internal instrumentation of the logging pipeline: how many records were
+// written or dropped, how many bytes reached the outputs and how long log
+// records take to write. All counters are updated atomically.
+
+package logger
+
+import (
+        "sync/atomic"
+        "time"
+)
+
+// Stats is a snapshot of the logging counters since start (or Reset)
+type Stats struct {
+        TotalRecords      uint64        // Records written to the outputs
+        BytesWritten      uint64        // Bytes written, summed over all outputs
+        DroppedRecords    uint64        // Records dropped before reaching the outputs
+        AverageLatency    time.Duration // Average time spent writing a record
+        LatencyBuckets    [7]uint64     // Records written in up to 1µs, 10µs, 100µs, 1ms, 10ms, 100ms and longer
+        RecordsPerSecond  float64       // Average emission rate
+        WebhookQueued     uint64        // Records waiting for webhook delivery
+        WebhookDropped    uint64        // Records a webhook dropped or failed to deliver
+        FileSyncs         uint64        // Syncs of the log file by the durability policy
+        SubscriberDropped uint64        // Records dropped because a subscriber fell behind
+        RateLimited       uint64        // Records dropped by the rate limit
+        NetworkDropped    uint64        // Records a network output dropped after a write timeout
+}
+
+var (
+        // Counters behind Stats (accessed atomically)
+        statTotalRecords      uint64
+        statBytesWritten      uint64
+        statDroppedRecords    uint64
+        statLatencyNanos      uint64
+        statWebhookQueued     int64
+        statWebhookDropped    uint64
+        statFileSyncs         uint64
+        statSubscriberDropped uint64
+        statRateLimited       uint64
+        statNetworkDropped    uint64
+        statLatencyBuckets    [len(latencyBounds) + 1]uint64
+        statStartNanos        = time.Now().UnixNano()
+)
+
+// latencyBounds are the upper bounds of the latency buckets; the last
+// bucket holds the slower records
+var latencyBounds = [...]time.Duration{
+        time.Microsecond,
+        10 * time.Microsecond,
+        100 * time.Microsecond,
+        time.Millisecond,
+        10 * time.Millisecond,
+        100 * time.Millisecond,
+}
+
+// GetStats returns a snapshot of the logging counters. It keeps the Get
+// prefix of GetLevel and friends, as a Stats function would clash with the
+// Stats type.
+func GetStats() Stats {
+        total := atomic.LoadUint64(&statTotalRecords)
+        s := Stats{
+                TotalRecords:      total,
+                BytesWritten:      atomic.LoadUint64(&statBytesWritten),
+                DroppedRecords:    atomic.LoadUint64(&statDroppedRecords),
+                WebhookDropped:    atomic.LoadUint64(&statWebhookDropped),
+                FileSyncs:         atomic.LoadUint64(&statFileSyncs),
+                SubscriberDropped: atomic.LoadUint64(&statSubscriberDropped),
+                RateLimited:       atomic.LoadUint64(&statRateLimited),
+                NetworkDropped:    atomic.LoadUint64(&statNetworkDropped),
+        }
+        for i := range statLatencyBuckets {
+                s.LatencyBuckets[i] = atomic.LoadUint64(&statLatencyBuckets[i])
+        }
+        if queued := atomic.LoadInt64(&statWebhookQueued); queued > 0 {
+                s.WebhookQueued = uint64(queued)
+        }
+        if total > 0 {
+                s.AverageLatency = time.Duration(atomic.LoadUint64(&statLatencyNanos) / total)
+        }
+        elapsed := time.Since(time.Unix(0, atomic.LoadInt64(&statStartNanos)))
+        if elapsed > 0 {
+                s.RecordsPerSecond = float64(total) / elapsed.Seconds()
+        }
+        return s
+}
+
+// resetStats zeroes all counters
+func resetStats() {
+        atomic.StoreUint64(&statTotalRecords, 0)
+        atomic.StoreUint64(&statBytesWritten, 0)
+        atomic.StoreUint64(&statDroppedRecords, 0)
+        atomic.StoreUint64(&statLatencyNanos, 0)
+        atomic.StoreUint64(&statWebhookDropped, 0)
+        atomic.StoreUint64(&statFileSyncs, 0)
+        atomic.StoreUint64(&statSubscriberDropped, 0)
+        atomic.StoreUint64(&statRateLimited, 0)
+        atomic.StoreUint64(&statNetworkDropped, 0)
+        for i := range statLatencyBuckets {
+                atomic.StoreUint64(&statLatencyBuckets[i], 0)
+        }
+        atomic.StoreInt64(&statStartNanos, time.Now().UnixNano())
+}
+
+// countRecord accounts for one record written, started at start
+func countRecord(start time.Time) {
+        atomic.AddUint64(&statTotalRecords, 1)
+        latency := time.Since(start)
+        atomic.AddUint64(&statLatencyNanos, uint64(latency))
+        bucket := 0
+        for bucket < len(latencyBounds) && latency > latencyBounds[bucket] {
+                bucket++
+        }
+        atomic.AddUint64(&statLatencyBuckets[bucket], 1)
+}
+
+// countBytes accounts for bytes written to an output
+func countBytes(n int) {
+        if n > 0 {
+                atomic.AddUint64(&statBytesWritten, uint64(n))
+        }
+}
+
+// countDropped accounts for a record dropped before reaching the outputs.
+// A record dropped by one output only is counted by that output instead.
+func countDropped() {
+        atomic.AddUint64(&statDroppedRecords, 1)
+}
@@ -0,0 +1,63 @@
+//go:build !logger_minimal
+
+package logger
+
+import "testing"
+
+func TestStats(t *testing.T) {
+        tests := []struct {
+                name        string
+                records     int
+                extra       bool // Also write to an additional output
+                rateLimit   int  // Records allowed, 0 for no limit
+                pauseBuffer int  // Records held while paused, -1 to log unpaused
+                wantWritten uint64
+                wantDropped uint64
+        }{
+                {name: "single record", records: 1, pauseBuffer: -1, wantWritten: 1},
+                {name: "several records", records: 25, pauseBuffer: -1, wantWritten: 25},
+                {name: "two outputs", records: 5, extra: true, pauseBuffer: -1, wantWritten: 5},
+                {name: "rate limited", records: 10, rateLimit: 4, pauseBuffer: -1, wantWritten: 4, wantDropped: 6},
+                {name: "pause buffer overflow", records: 10, pauseBuffer: 3, wantWritten: 3, wantDropped: 7},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        console := captureOutput(t)
+                        useFakeClock(t) // The rate limit doesn't refill
+                        extra := &syncBuffer{}
+                        if tt.extra {
+                                AddOutput(extra, FormatJSON)
+                        }
+                        if tt.rateLimit > 0 {
+                                SetRateLimit(1, tt.rateLimit)
+                        }
+                        if tt.pauseBuffer >= 0 {
+                                SetPauseBuffer(tt.pauseBuffer)
+                                Pause()
+                        }
+                        for i := 0; i < tt.records; i++ {
+                                Infof("record %d", i)
+                        }
+                        Resume()
+
+                        stats := GetStats()
+                        if stats.TotalRecords != tt.wantWritten {
+                                t.Errorf("TotalRecords = %d, want %d", stats.TotalRecords, tt.wantWritten)
+                        }
+                        if stats.DroppedRecords != tt.wantDropped {
+                                t.Errorf("DroppedRecords = %d, want %d", stats.DroppedRecords, tt.wantDropped)
+                        }
+                        var bucketed uint64
+                        for _, n := range stats.LatencyBuckets {
+                                bucketed += n
+                        }
+                        if bucketed != tt.wantWritten {
+                                t.Errorf("LatencyBuckets hold %d records, want %d", bucketed, tt.wantWritten)
+                        }
+                        bytes := uint64(len(console.String()) + len(extra.String()))
+                        if stats.BytesWritten != bytes {
+                                t.Errorf("BytesWritten = %d, want %d", stats.BytesWritten, bytes)
+                        }
+                })
+        }
+}
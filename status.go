@@ -0,0 +1,77 @@
+// File: status.go
+// Description:
+// CLI status line. On a terminal, Status shows a line that is overwritten
+// in place (e.g. progress); it is cleared before any regular log line is
+// written to stdout so the output doesn't get garbled. The terminal can also
+// ring its bell on errors.
+
+package logger
+
+import (
+        "fmt"
+        "io"
+        "os"
+)
+
+// clearLine returns the cursor to column 0 and erases the line
+const clearLine = "\r\x1b[K"
+
+// bell rings the terminal bell
+const bell = "\a"
+
+var (
+        // Whether a status line is currently displayed (guarded by outputsMu)
+        statusActive bool
+
+        // Ring the bell for error and fatal records (guarded by outputsMu)
+        alertOnError bool
+
+        // Reports whether stdout is a terminal (replaceable in tests)
+        stdoutIsTerminal = func() bool { return isTerminal(os.Stdout) }
+)
+
+// isTerminal reports whether f is a character device such as a TTY
+func isTerminal(f *os.File) bool {
+        info, err := f.Stat()
+        if err != nil {
+                return false
+        }
+        return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Status displays a formatted status line on stdout, replacing the previous
+// one. It does nothing when stdout is not a terminal.
+func Status(format string, v ...interface{}) {
+        if !stdoutIsTerminal() {
+                return
+        }
+
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        io.WriteString(consoleWriter, clearLine+fmt.Sprintf(format, v...))
+        statusActive = true
+}
+
+// clearStatus erases the status line, if any; outputsMu must be held
+func clearStatus() {
+        if statusActive {
+                io.WriteString(consoleWriter, clearLine)
+                statusActive = false
+        }
+}
+
+// SetAlertOnError rings the terminal bell after error and fatal records are
+// written, so long running CLI tools get noticed without watching the
+// screen. It only applies when stdout is a terminal.
+func SetAlertOnError(enabled bool) {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        alertOnError = enabled
+}
+
+// alert rings the bell for error records if enabled; outputsMu must be held
+func alert(level int) {
+        if alertOnError && level >= LevelError && stdoutIsTerminal() {
+                io.WriteString(consoleWriter, bell)
+        }
+}
@@ -0,0 +1,94 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "strings"
+        "testing"
+)
+
+// forceTerminal makes the package treat stdout as a terminal, or not, for
+// the duration of the test
+func forceTerminal(t *testing.T, terminal bool) {
+        t.Helper()
+        previous := stdoutIsTerminal
+        stdoutIsTerminal = func() bool { return terminal }
+        t.Cleanup(func() { stdoutIsTerminal = previous })
+}
+
+func TestStatus(t *testing.T) {
+        tests := []struct {
+                name     string
+                terminal bool
+                want     string // Output before the permanent line
+        }{
+                {
+                        name:     "terminal",
+                        terminal: true,
+                        want:     clearLine + "copying 1/3" + clearLine + "copying 2/3" + clearLine,
+                },
+                {name: "not a terminal", want: ""},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        forceTerminal(t, tt.terminal)
+                        Status("copying %d/%d", 1, 3)
+                        Status("copying %d/%d", 2, 3)
+                        Info("copy done")
+
+                        got := out.String()
+                        line := strings.Index(got, "[INFO]")
+                        if line < 0 {
+                                t.Fatalf("output %q lacks the permanent line", got)
+                        }
+                        if got[:line] != tt.want {
+                                t.Errorf("output before the permanent line %q, want %q", got[:line], tt.want)
+                        }
+                        if strings.Contains(got[line:], "\r") {
+                                t.Errorf("permanent line %q holds a carriage return", got[line:])
+                        }
+                })
+        }
+}
+
+func TestAlertOnError(t *testing.T) {
+        tests := []struct {
+                name     string
+                enabled  bool
+                terminal bool
+                log      func()
+                bell     bool
+        }{
+                {name: "error", enabled: true, terminal: true, log: func() { Error("failed") }, bell: true},
+                {name: "fatal", enabled: true, terminal: true, log: func() { Fatal("failed") }, bell: true},
+                {name: "warning", enabled: true, terminal: true, log: func() { Warning("slow") }},
+                {name: "disabled", terminal: true, log: func() { Error("failed") }},
+                {name: "not a terminal", enabled: true, log: func() { Error("failed") }},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        stubExit(t)
+                        forceTerminal(t, tt.terminal)
+                        path := tempLogPath(t, "app.log")
+                        if err := InitLogger(LevelInfo, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        SetAlertOnError(tt.enabled)
+                        tt.log()
+                        got := out.String()
+                        if tt.bell && !strings.HasSuffix(got, "\n"+bell) {
+                                t.Errorf("console %q doesn't end with a bell after the record", got)
+                        }
+                        if n := strings.Count(got, bell); !tt.bell && n != 0 {
+                                t.Errorf("console %q rings the bell", got)
+                        } else if tt.bell && n != 1 {
+                                t.Errorf("bell rung %d times", n)
+                        }
+                        if strings.Contains(readLog(t, path), bell) {
+                                t.Error("bell written to the log file")
+                        }
+                })
+        }
+}
@@ -0,0 +1,88 @@
+// File: stdlog.go
+// Description:
+// Capture of the standard log package. Libraries logging with the standard
+// log package write to stderr by default, escaping the log file and its
+// rotation; once captured, their messages become info records going
+// through the regular outputs.
+
+package logger
+
+import (
+        "io"
+        "log"
+        "runtime"
+        "strings"
+        "sync"
+        "time"
+)
+
+var (
+        // Settings of the standard logger before CaptureStandardLog
+        savedStdOutput io.Writer
+        savedStdFlags  int
+        savedStdPrefix string
+        stdCaptured    bool
+        stdCaptureMu   sync.Mutex
+)
+
+// CaptureStandardLog redirects the standard log package to this package:
+// every message becomes an info record, with the caller of the log
+// function. ReleaseStandardLog restores the previous output, flags and
+// prefix.
+func CaptureStandardLog() {
+        stdCaptureMu.Lock()
+        defer stdCaptureMu.Unlock()
+        if !stdCaptured {
+                savedStdOutput = log.Writer()
+                savedStdFlags = log.Flags()
+                savedStdPrefix = log.Prefix()
+                stdCaptured = true
+        }
+        log.SetOutput(stdLogWriter{})
+        log.SetFlags(0)
+        log.SetPrefix("")
+}
+
+// ReleaseStandardLog undoes CaptureStandardLog
+func ReleaseStandardLog() {
+        stdCaptureMu.Lock()
+        defer stdCaptureMu.Unlock()
+        if !stdCaptured {
+                return
+        }
+        log.SetOutput(savedStdOutput)
+        log.SetFlags(savedStdFlags)
+        log.SetPrefix(savedStdPrefix)
+        stdCaptured = false
+}
+
+// stdLogWriter turns messages of the standard log package into info records
+type stdLogWriter struct{}
+
+// Write implements io.Writer
+func (stdLogWriter) Write(p []byte) (int, error) {
+        if LevelInfo < minEnabledLevel() {
+                return len(p), nil
+        }
+        pc, file, line := stdLogCaller()
+        emitAt(nil, 2, time.Time{}, pc, file, line, LevelInfo, nil, "", strings.TrimSuffix(string(p), "\n"))
+        return len(p), nil
+}
+
+// stdLogCaller returns the call site of the first frame outside the log
+// package and this one
+func stdLogCaller() (uintptr, string, int) {
+        pcs := make([]uintptr, maxStackDepth)
+        n := runtime.Callers(3, pcs) // Skip Callers, stdLogCaller and Write
+        frames := runtime.CallersFrames(pcs[:n])
+        for {
+                frame, more := frames.Next()
+                if !strings.HasPrefix(frame.Function, "log.") &&
+                        !strings.HasPrefix(frame.Function, packagePrefix+".") {
+                        return frame.PC, frame.File, frame.Line
+                }
+                if !more {
+                        return 0, "", 0
+                }
+        }
+}
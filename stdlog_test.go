@@ -0,0 +1,76 @@
+package logger
+
+import (
+        "log"
+        "strings"
+        "testing"
+)
+
+// useStandardLogBuffer sets the output of the standard log package to a
+// buffer for the duration of the test
+func useStandardLogBuffer(t *testing.T) *syncBuffer {
+        t.Helper()
+        writer, flags, prefix := log.Writer(), log.Flags(), log.Prefix()
+        buf := &syncBuffer{}
+        log.SetOutput(buf)
+        log.SetFlags(0)
+        log.SetPrefix("lib: ")
+        t.Cleanup(func() {
+                log.SetOutput(writer)
+                log.SetFlags(flags)
+                log.SetPrefix(prefix)
+        })
+        return buf
+}
+
+func TestCaptureStandardLog(t *testing.T) {
+        tests := []struct {
+                name       string
+                level      int
+                release    bool
+                wantFile   string // Suffix of the log file, "" for an empty file
+                wantStdlog string // What the standard logger wrote itself
+        }{
+                {name: "captured", level: LevelInfo, wantFile: "[INFO] library message"},
+                {name: "below the level", level: LevelWarning},
+                {name: "released", level: LevelInfo, release: true, wantStdlog: "lib: library message\n"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        stdlog := useStandardLogBuffer(t)
+                        path := tempLogPath(t, "app.log")
+                        if err := InitLogger(tt.level, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        CaptureStandardLog()
+                        CaptureStandardLog()
+                        if tt.release {
+                                ReleaseStandardLog()
+                        }
+                        log.Print("library message")
+
+                        file := strings.TrimSuffix(readLog(t, path), "\n")
+                        if tt.wantFile == "" && file != "" {
+                                t.Errorf("log file holds %q, want nothing", file)
+                        }
+                        if tt.wantFile != "" && (!strings.HasPrefix(file, "[INFO] ") || !strings.HasSuffix(file, " library message") || strings.Contains(file, "\n")) {
+                                t.Errorf("log file holds %q, want one info record of the message", file)
+                        }
+                        if got := stdlog.String(); got != tt.wantStdlog {
+                                t.Errorf("standard logger wrote %q, want %q", got, tt.wantStdlog)
+                        }
+                })
+        }
+}
+
+func TestResetReleasesStandardLog(t *testing.T) {
+        captureOutput(t)
+        stdlog := useStandardLogBuffer(t)
+        CaptureStandardLog()
+        Reset()
+        log.Print("after reset")
+        if got := stdlog.String(); got != "lib: after reset\n" {
+                t.Errorf("standard logger wrote %q after Reset", got)
+        }
+}
@@ -0,0 +1,63 @@
+// File: strict.go
+// Description:
+// Strict format checking. fmt silently renders argument mismatches as
+// "%!d(string=foo)"; in strict mode such messages are reported once per
+// format string on stderr so bad log calls are caught during development.
+
+package logger
+
+import (
+        "fmt"
+        "io"
+        "os"
+        "strings"
+        "sync"
+        "sync/atomic"
+)
+
+var (
+        // Report formatting errors (accessed atomically)
+        strictFormat int32
+
+        // Format strings already reported
+        reportedFormats   = map[string]bool{}
+        reportedFormatsMu sync.Mutex
+
+        // Where formatting errors are reported (replaceable in tests)
+        strictOutput io.Writer = os.Stderr
+)
+
+// SetStrictFormat enables reporting of formatting errors (wrong argument
+// count or type) on stderr. Each bad format string is reported once.
+func SetStrictFormat(enabled bool) {
+        var v int32
+        if enabled {
+                v = 1
+        }
+        atomic.StoreInt32(&strictFormat, v)
+}
+
+// checkFormat reports msg if it shows a formatting error for format
+func checkFormat(format, msg, caller string) {
+        if atomic.LoadInt32(&strictFormat) == 0 || format == "" || !strings.Contains(msg, "%!") {
+                return
+        }
+
+        reportedFormatsMu.Lock()
+        seen := reportedFormats[format]
+        reportedFormats[format] = true
+        reportedFormatsMu.Unlock()
+        if seen {
+                return
+        }
+
+        fmt.Fprintf(strictOutput, "logger: bad format string %q at %s: %s\n", format, caller, msg)
+}
+
+// clearStrictFormat disables strict mode and forgets reported formats
+func clearStrictFormat() {
+        SetStrictFormat(false)
+        reportedFormatsMu.Lock()
+        reportedFormats = map[string]bool{}
+        reportedFormatsMu.Unlock()
+}
@@ -0,0 +1,49 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "os"
+        "strings"
+        "testing"
+)
+
+func TestStrictFormat(t *testing.T) {
+        tests := []struct {
+                name   string
+                strict bool
+                format string
+                args   []interface{}
+                calls  int
+                want   int // Warnings expected
+        }{
+                {name: "wrong type", strict: true, format: "count=%d", args: []interface{}{"foo"}, calls: 1, want: 1},
+                {name: "missing argument", strict: true, format: "%s=%s", args: []interface{}{"key"}, calls: 1, want: 1},
+                {name: "extra argument", strict: true, format: "key=%s", args: []interface{}{"a", "b"}, calls: 1, want: 1},
+                {name: "reported once", strict: true, format: "count=%d", args: []interface{}{"foo"}, calls: 3, want: 1},
+                {name: "correct format", strict: true, format: "count=%d", args: []interface{}{42}, calls: 1},
+                {name: "strict mode off", format: "count=%d", args: []interface{}{"foo"}, calls: 1},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        warnings := &syncBuffer{}
+                        strictOutput = warnings
+                        t.Cleanup(func() { strictOutput = os.Stderr })
+                        SetStrictFormat(tt.strict)
+                        for i := 0; i < tt.calls; i++ {
+                                Infof(tt.format, tt.args...)
+                        }
+
+                        lines := warnings.Lines()
+                        if len(lines) != tt.want {
+                                t.Fatalf("got warnings %q, want %d", lines, tt.want)
+                        }
+                        for _, line := range lines {
+                                if !strings.Contains(line, "bad format string") || !strings.Contains(line, "strict_test.go") {
+                                        t.Errorf("warning %q lacks the format problem or call site", line)
+                                }
+                        }
+                })
+        }
+}
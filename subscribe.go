@@ -0,0 +1,100 @@
+// File: subscribe.go
+// Description:
+// In-process subscriptions to the record stream, for custom tooling such as
+// tests asserting on logs or live dashboards. Every written record is sent
+// to each subscriber; subscribers that fall behind miss records instead of
+// blocking logging, and the misses are counted in Stats.
+
+package logger
+
+import (
+        "sync"
+        "sync/atomic"
+)
+
+// subscriberQueueSize is the number of records a subscriber can lag behind
+const subscriberQueueSize = 256
+
+var (
+        // Channels of the current subscribers
+        subscribers   []chan Record
+        subscribersMu sync.RWMutex
+)
+
+// Subscribe returns a channel receiving every record written from now on,
+// i.e. records that passed the level, filters and sampling. Field values
+// are the ones the outputs write, with redacted fields hidden, and the
+// fields of each record belong to the subscriber. The channel
+// holds up to 256 records; when it is full, records are dropped for this
+// subscriber and counted in Stats.SubscriberDropped. The returned function
+// ends the subscription and closes the channel.
+func Subscribe() (<-chan Record, func()) {
+        ch := make(chan Record, subscriberQueueSize)
+        subscribersMu.Lock()
+        subscribers = append(subscribers, ch)
+        subscribersMu.Unlock()
+
+        var once sync.Once
+        return ch, func() {
+                once.Do(func() {
+                        unsubscribe(ch)
+                })
+        }
+}
+
+// unsubscribe removes a subscriber and closes its channel
+func unsubscribe(ch chan Record) {
+        subscribersMu.Lock()
+        defer subscribersMu.Unlock()
+        for i, c := range subscribers {
+                if c == ch {
+                        subscribers = append(subscribers[:i:i], subscribers[i+1:]...)
+                        close(ch)
+                        return
+                }
+        }
+}
+
+// clearSubscribers ends every subscription
+func clearSubscribers() {
+        subscribersMu.Lock()
+        defer subscribersMu.Unlock()
+        for _, ch := range subscribers {
+                close(ch)
+        }
+        subscribers = nil
+}
+
+// publish sends a written record to the subscribers. Field values are
+// rendered as the encoders render them (redacted, binary formatted and
+// marshaled), and every subscriber gets its own copy of the fields.
+func publish(rec *Record) {
+        subscribersMu.RLock()
+        defer subscribersMu.RUnlock()
+        if len(subscribers) == 0 {
+                return
+        }
+
+        var rendered Fields
+        if rec.Fields != nil {
+                rendered = make(Fields, len(rec.Fields))
+                for k, v := range rec.Fields {
+                        rendered[k] = fieldValue(k, v)
+                }
+        }
+        for i, ch := range subscribers {
+                published := *rec
+                published.Fields = rendered
+                if i < len(subscribers)-1 && rendered != nil {
+                        published.Fields = make(Fields, len(rendered))
+                        for k, v := range rendered {
+                                published.Fields[k] = v
+                        }
+                }
+                select {
+                case ch <- published:
+                default:
+                        atomic.AddUint64(&statSubscriberDropped, 1)
+                }
+        }
+}
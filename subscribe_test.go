@@ -0,0 +1,105 @@
+//go:build !logger_minimal
+
+package logger
+
+import "testing"
+
+// drain returns the records queued on ch without blocking
+func drain(ch <-chan Record) []Record {
+        var records []Record
+        for {
+                select {
+                case rec, ok := <-ch:
+                        if !ok {
+                                return records
+                        }
+                        records = append(records, rec)
+                default:
+                        return records
+                }
+        }
+}
+
+func TestSubscribe(t *testing.T) {
+        tests := []struct {
+                name        string
+                records     int
+                wantRecords int
+                wantDropped uint64
+        }{
+                {name: "records delivered", records: 3, wantRecords: 3},
+                {name: "queue full", records: subscriberQueueSize + 44, wantRecords: subscriberQueueSize, wantDropped: 44},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        ch, unsubscribe := Subscribe()
+                        defer unsubscribe()
+                        Debug("below the level")
+                        for i := 0; i < tt.records; i++ {
+                                WithField("i", i).Warning("record")
+                        }
+
+                        records := drain(ch)
+                        if len(records) != tt.wantRecords {
+                                t.Fatalf("got %d records, want %d", len(records), tt.wantRecords)
+                        }
+                        for i, rec := range records {
+                                if rec.Level != LevelWarning || rec.Message != "record" || rec.Fields["i"] != i {
+                                        t.Errorf("record %d = %+v", i, rec)
+                                }
+                        }
+                        if got := GetStats().SubscriberDropped; got != tt.wantDropped {
+                                t.Errorf("%d records dropped, want %d", got, tt.wantDropped)
+                        }
+                })
+        }
+}
+
+func TestSubscribeRedaction(t *testing.T) {
+        captureOutput(t)
+        RedactFields("password")
+        first, unsubscribeFirst := Subscribe()
+        defer unsubscribeFirst()
+        second, unsubscribeSecond := Subscribe()
+        defer unsubscribeSecond()
+        WithFields(Fields{"user": "alice", "password": "hunter2"}).Info("login")
+
+        a, b := drain(first), drain(second)
+        if len(a) != 1 || len(b) != 1 {
+                t.Fatalf("subscribers got %d and %d records, want 1 each", len(a), len(b))
+        }
+        tests := []struct {
+                name string
+                ok   bool
+        }{
+                {"password redacted", a[0].Fields["password"] == redactedValue && b[0].Fields["password"] == redactedValue},
+                {"other fields kept", a[0].Fields["user"] == "alice" && b[0].Fields["user"] == "alice"},
+        }
+        for _, tt := range tests {
+                if !tt.ok {
+                        t.Errorf("%s: %v, %v", tt.name, a[0].Fields, b[0].Fields)
+                }
+        }
+        a[0].Fields["user"] = "mallory"
+        if b[0].Fields["user"] != "alice" {
+                t.Errorf("subscribers share the fields map")
+        }
+}
+
+func TestUnsubscribe(t *testing.T) {
+        captureOutput(t)
+        ch, unsubscribe := Subscribe()
+        Info("before")
+        unsubscribe()
+        unsubscribe()
+        Info("after")
+
+        records := drain(ch)
+        if len(records) != 1 || records[0].Message != "before" {
+                t.Errorf("got %+v, want only the record before unsubscribing", records)
+        }
+        if _, ok := <-ch; ok {
+                t.Errorf("channel still open after unsubscribing")
+        }
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+// File: termsize_other.go
+// Description:
+// Terminal size lookup for platforms without TIOCGWINSZ support here; the
+// COLUMNS environment variable is used instead.
+
+package logger
+
+import "os"
+
+// terminalWidth returns 0: the width is unknown
+func terminalWidth(f *os.File) int {
+        return 0
+}
@@ -0,0 +1,25 @@
+//go:build linux || darwin
+
+// File: termsize_unix.go
+// Description:
+// Terminal size lookup through the TIOCGWINSZ ioctl.
+
+package logger
+
+import (
+        "os"
+        "syscall"
+        "unsafe"
+)
+
+// terminalWidth returns the number of columns of the terminal f, 0 if unknown
+func terminalWidth(f *os.File) int {
+        var ws struct {
+                Row, Col, Xpixel, Ypixel uint16
+        }
+        _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+        if errno != 0 {
+                return 0
+        }
+        return int(ws.Col)
+}
@@ -0,0 +1,24 @@
+// File: timer.go
+// Description:
+// Timing helpers for performance logging.
+
+package logger
+
+import "time"
+
+// DurationKey is the field name of the elapsed time logged by Timer
+const DurationKey = "duration_ms"
+
+// Timer starts timing and returns a function that logs an info record with
+// the given name as message and the elapsed milliseconds in "duration_ms".
+// Typical use:
+//
+//	defer logger.Timer("handler")()
+func Timer(name string) func() {
+        start := now()
+        return func() {
+                elapsed := since(start)
+                fields := Fields{DurationKey: float64(elapsed) / float64(time.Millisecond)}
+                logWithCallerInfo(LevelInfo, fields, "", name)
+        }
+}
@@ -0,0 +1,41 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "testing"
+        "time"
+)
+
+func TestTimer(t *testing.T) {
+        tests := []struct {
+                elapsed time.Duration
+                want    float64
+        }{
+                {0, 0},
+                {250 * time.Millisecond, 250},
+                {1500 * time.Microsecond, 1.5},
+                {2 * time.Minute, 120000},
+        }
+        for _, tt := range tests {
+                t.Run(tt.elapsed.String(), func(t *testing.T) {
+                        out := captureOutput(t)
+                        SetFormat(FormatJSON)
+                        clock := useFakeClock(t)
+                        done := Timer("handler")
+                        clock.Advance(tt.elapsed)
+                        done()
+
+                        records := out.Records(t)
+                        if len(records) != 1 {
+                                t.Fatalf("got %d records, want 1", len(records))
+                        }
+                        if records[0]["message"] != "handler" || records[0]["level"] != "info" {
+                                t.Errorf("record %v, want an info record named handler", records[0])
+                        }
+                        if got := records[0][DurationKey]; got != tt.want {
+                                t.Errorf("%s = %v, want %v", DurationKey, got, tt.want)
+                        }
+                })
+        }
+}
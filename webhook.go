@@ -0,0 +1,158 @@
+// File: webhook.go
+// Description:
+// Webhook output. Records at or above a level (typically errors) are POSTed
+// to an HTTP endpoint. Records arriving within a short window are batched
+// into a single POST carrying a JSON array, which keeps the call volume low
+// during incident storms. Delivery is asynchronous; records dropped because
+// the queue is full or their batch couldn't be delivered are counted in
+// Stats, and failed deliveries are reported to the error hook.
+
+package logger
+
+import (
+        "bytes"
+        "context"
+        "fmt"
+        "net/http"
+        "sync"
+        "sync/atomic"
+        "time"
+)
+
+// webhookQueueSize is the number of records a webhook can hold before dropping
+const webhookQueueSize = 1024
+
+// webhookOutput batches JSON records and POSTs them to a URL
+type webhookOutput struct {
+        url      string
+        maxBatch int
+        interval time.Duration
+        client   *http.Client
+
+        queue     chan []byte
+        stop      chan struct{}
+        done      chan struct{}
+        closeOnce sync.Once
+}
+
+// AddWebhookOutput adds an output POSTing records at or above minLevel to
+// url as a JSON array. Records are delivered in batches of at most maxBatch
+// records, flushed at least every interval.
+func AddWebhookOutput(url string, minLevel, maxBatch int, interval time.Duration) error {
+        if maxBatch < 1 {
+                return fmt.Errorf("invalid webhook batch size: %d", maxBatch)
+        }
+        if interval <= 0 {
+                return fmt.Errorf("invalid webhook flush interval: %v", interval)
+        }
+
+        w := &webhookOutput{
+                url:      url,
+                maxBatch: maxBatch,
+                interval: interval,
+                client:   &http.Client{},
+                queue:    make(chan []byte, webhookQueueSize),
+                stop:     make(chan struct{}),
+                done:     make(chan struct{}),
+        }
+        go w.loop()
+
+        addOutput(&output{w: w, format: FormatJSON, minLevel: minLevel})
+        return nil
+}
+
+// Write queues one encoded record without blocking
+func (w *webhookOutput) Write(p []byte) (int, error) {
+        record := bytes.TrimRight(append([]byte(nil), p...), "\n")
+        select {
+        case w.queue <- record:
+                atomic.AddInt64(&statWebhookQueued, 1)
+        default:
+                atomic.AddUint64(&statWebhookDropped, 1)
+        }
+        return len(p), nil
+}
+
+// loop collects queued records into batches and delivers them
+func (w *webhookOutput) loop() {
+        defer close(w.done)
+
+        ticker := newClockTicker(w.interval)
+        defer ticker.Stop()
+
+        var batch [][]byte
+        flush := func() {
+                if len(batch) > 0 {
+                        if err := w.post(batch); err != nil {
+                                atomic.AddUint64(&statWebhookDropped, uint64(len(batch)))
+                                reportError(err)
+                        }
+                        atomic.AddInt64(&statWebhookQueued, -int64(len(batch)))
+                        batch = nil
+                }
+        }
+
+        for {
+                select {
+                case record := <-w.queue:
+                        batch = append(batch, record)
+                        if len(batch) >= w.maxBatch {
+                                flush()
+                        }
+                case <-ticker.C:
+                        flush()
+                case <-w.stop:
+                        // Deliver whatever is still queued
+                        for {
+                                select {
+                                case record := <-w.queue:
+                                        batch = append(batch, record)
+                                        if len(batch) >= w.maxBatch {
+                                                flush()
+                                        }
+                                default:
+                                        flush()
+                                        return
+                                }
+                        }
+                }
+        }
+}
+
+// post sends one batch as a JSON array. A response status other than 2xx
+// is a failed delivery.
+func (w *webhookOutput) post(batch [][]byte) error {
+        body := append([]byte("["), bytes.Join(batch, []byte(","))...)
+        body = append(body, ']')
+
+        ctx := context.Background()
+        if timeout := NetworkTimeout(); timeout > 0 {
+                var cancel context.CancelFunc
+                ctx, cancel = context.WithTimeout(ctx, timeout)
+                defer cancel()
+        }
+        req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+        if err != nil {
+                return fmt.Errorf("failed to deliver webhook batch: %w", err)
+        }
+        req.Header.Set("Content-Type", "application/json")
+
+        resp, err := w.client.Do(req)
+        if err != nil {
+                return fmt.Errorf("failed to deliver webhook batch: %w", err)
+        }
+        resp.Body.Close()
+        if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+                return fmt.Errorf("failed to deliver webhook batch: %s", resp.Status)
+        }
+        return nil
+}
+
+// Close delivers the queued records and stops the webhook
+func (w *webhookOutput) Close() error {
+        w.closeOnce.Do(func() {
+                close(w.stop)
+                <-w.done
+        })
+        return nil
+}
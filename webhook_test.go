@@ -0,0 +1,172 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "encoding/json"
+        "net/http"
+        "net/http/httptest"
+        "strings"
+        "sync"
+        "testing"
+        "time"
+)
+
+// webhookServer collects the batches POSTed to it
+type webhookServer struct {
+        *httptest.Server
+        mu      sync.Mutex
+        batches [][]map[string]interface{}
+}
+
+func newWebhookServer(t *testing.T) *webhookServer {
+        s := &webhookServer{}
+        s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                var batch []map[string]interface{}
+                if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+                        t.Errorf("decoding webhook body: %v", err)
+                }
+                s.mu.Lock()
+                s.batches = append(s.batches, batch)
+                s.mu.Unlock()
+        }))
+        t.Cleanup(s.Close)
+        return s
+}
+
+// sizes returns the number of records of each batch received
+func (s *webhookServer) sizes() []int {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        var sizes []int
+        for _, batch := range s.batches {
+                sizes = append(sizes, len(batch))
+        }
+        return sizes
+}
+
+// webhookQueueLen returns the number of records waiting in the webhook
+// output's queue
+func webhookQueueLen() int {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        for _, o := range extraOutputs {
+                if w, ok := o.w.(*webhookOutput); ok {
+                        return len(w.queue)
+                }
+        }
+        return 0
+}
+
+func TestWebhookBatching(t *testing.T) {
+        tests := []struct {
+                name     string
+                maxBatch int
+                errors   int
+                tick     bool // Let the flush interval elapse before closing
+                want     []int
+        }{
+                {name: "rapid errors in one POST", maxBatch: 10, errors: 5, want: []int{5}},
+                {name: "flush interval", maxBatch: 10, errors: 3, tick: true, want: []int{3}},
+                {name: "batch size limit", maxBatch: 3, errors: 7, want: []int{3, 3, 1}},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        clock := useFakeClock(t)
+                        server := newWebhookServer(t)
+                        if err := AddWebhookOutput(server.URL, LevelError, tt.maxBatch, time.Second); err != nil {
+                                t.Fatal(err)
+                        }
+                        Info("not delivered")
+                        for i := 0; i < tt.errors; i++ {
+                                Errorf("failure %d", i)
+                        }
+                        if tt.tick {
+                                for webhookQueueLen() > 0 {
+                                        time.Sleep(time.Millisecond)
+                                }
+                                clock.Advance(time.Second)
+                                deadline := time.Now().Add(5 * time.Second)
+                                for len(server.sizes()) == 0 && time.Now().Before(deadline) {
+                                        time.Sleep(time.Millisecond)
+                                }
+                                if len(server.sizes()) == 0 {
+                                        t.Fatal("nothing delivered after the flush interval")
+                                }
+                        }
+                        CloseLogger()
+
+                        got := server.sizes()
+                        if len(got) != len(tt.want) {
+                                t.Fatalf("batches of %v records, want %v", got, tt.want)
+                        }
+                        for i := range got {
+                                if got[i] != tt.want[i] {
+                                        t.Fatalf("batches of %v records, want %v", got, tt.want)
+                                }
+                        }
+                        if stats := GetStats(); stats.WebhookQueued != 0 || stats.WebhookDropped != 0 {
+                                t.Errorf("queued %d, dropped %d after closing", stats.WebhookQueued, stats.WebhookDropped)
+                        }
+                })
+        }
+}
+
+func TestWebhookDeliveryFailure(t *testing.T) {
+        tests := []struct {
+                name   string
+                status int // Response status, 0 to refuse connections
+        }{
+                {name: "server error", status: http.StatusInternalServerError},
+                {name: "not found", status: http.StatusNotFound},
+                {name: "connection refused"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        useFakeClock(t)
+                        server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                                w.WriteHeader(tt.status)
+                        }))
+                        if tt.status == 0 {
+                                server.Close()
+                        } else {
+                                t.Cleanup(server.Close)
+                        }
+                        var reported []error
+                        SetErrorHook(func(err error) { reported = append(reported, err) })
+                        if err := AddWebhookOutput(server.URL, LevelError, 10, time.Second); err != nil {
+                                t.Fatal(err)
+                        }
+                        for i := 0; i < 3; i++ {
+                                Errorf("failure %d", i)
+                        }
+                        CloseLogger()
+
+                        if stats := GetStats(); stats.WebhookQueued != 0 || stats.WebhookDropped != 3 {
+                                t.Errorf("queued %d, dropped %d; want 0, 3", stats.WebhookQueued, stats.WebhookDropped)
+                        }
+                        if len(reported) != 1 || !strings.Contains(reported[0].Error(), "webhook") {
+                                t.Errorf("reported %v, want one webhook delivery error", reported)
+                        }
+                })
+        }
+}
+
+func TestInvalidWebhook(t *testing.T) {
+        captureOutput(t)
+        tests := []struct {
+                name     string
+                maxBatch int
+                interval time.Duration
+        }{
+                {"zero batch", 0, time.Second},
+                {"zero interval", 10, 0},
+        }
+        for _, tt := range tests {
+                if err := AddWebhookOutput("http://localhost", LevelError, tt.maxBatch, tt.interval); err == nil {
+                        t.Errorf("%s: AddWebhookOutput succeeded", tt.name)
+                }
+        }
+}
@@ -0,0 +1,344 @@
+// File: websocket.go
+// Description:
+// WebSocket streaming for live log viewers. WSHandler upgrades HTTP
+// connections (RFC 6455, text frames only) and sends every subsequent
+// record to the connected clients as JSON. Clients that don't keep up miss
+// records rather than slowing logging down. Browsers may only connect from
+// pages of the same host unless other origins are allowed, so a web page
+// can't read the logs through a cross-site WebSocket.
+
+package logger
+
+import (
+        "bufio"
+        "crypto/sha1"
+        "encoding/base64"
+        "errors"
+        "io"
+        "net"
+        "net/http"
+        "net/url"
+        "strings"
+        "sync"
+)
+
+const (
+        // wsGUID is appended to the client key to compute the accept key
+        wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+        // wsQueueSize is the number of frames a client can lag behind before
+        // records are dropped for it
+        wsQueueSize = 256
+
+        // wsMaxClientFrame is the largest frame accepted from a client
+        wsMaxClientFrame = 4096
+)
+
+// WebSocket opcodes
+const (
+        wsText  = 0x1
+        wsClose = 0x8
+        wsPing  = 0x9
+        wsPong  = 0xa
+)
+
+var (
+        // Hub of the WebSocket clients, created with its output by the first
+        // connection and again after CloseLogger closed it
+        wsCurrent *wsHub
+
+        // Origins allowed besides the request host, "*" for any
+        wsAllowedOrigins []string
+
+        // Guards wsCurrent and wsAllowedOrigins
+        wsMu sync.Mutex
+)
+
+// wsHandler is the handler returned by WSHandler
+type wsHandler struct{}
+
+// wsHub is the output broadcasting records to the WebSocket clients
+type wsHub struct {
+        mu      sync.Mutex
+        clients map[*wsClient]bool
+        closed  bool
+}
+
+// wsClient is a connected WebSocket client
+type wsClient struct {
+        conn      net.Conn
+        send      chan []byte
+        closeOnce sync.Once
+        done      chan struct{}
+}
+
+// WSHandler returns a handler upgrading requests to WebSocket connections
+// that receive every record logged from then on, one JSON object per text
+// message. Records are queued per client; a client lagging more than 256
+// records behind misses the newer ones. Messages sent by clients are
+// ignored. Requests with an Origin header (browsers) are refused unless the
+// origin is on the request host or allowed with SetWSAllowedOrigins.
+// Handlers share the clients and a single output; CloseLogger disconnects
+// the clients, and later connections are served again.
+func WSHandler() http.Handler {
+        return wsHandler{}
+}
+
+// SetWSAllowedOrigins allows browser pages of the given origins (e.g.
+// "https://dashboard.example.com", or "*" for any) to connect to
+// WSHandler in addition to pages of the request host. Without arguments
+// only the request host is allowed.
+func SetWSAllowedOrigins(origins ...string) {
+        wsMu.Lock()
+        defer wsMu.Unlock()
+        wsAllowedOrigins = append([]string(nil), origins...)
+}
+
+// wsOriginAllowed reports whether the origin of a request may connect.
+// Requests without an Origin header don't come from browser pages.
+func wsOriginAllowed(r *http.Request) bool {
+        origin := r.Header.Get("Origin")
+        if origin == "" {
+                return true
+        }
+        wsMu.Lock()
+        allowed := wsAllowedOrigins
+        wsMu.Unlock()
+        for _, a := range allowed {
+                if a == "*" || strings.EqualFold(a, origin) {
+                        return true
+                }
+        }
+        u, err := url.Parse(origin)
+        return err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host)
+}
+
+// activeWSHub returns the hub of the WebSocket clients, registering a new
+// one as an output if there is none or it was closed
+func activeWSHub() *wsHub {
+        wsMu.Lock()
+        defer wsMu.Unlock()
+        if wsCurrent == nil || wsCurrent.isClosed() {
+                wsCurrent = &wsHub{clients: map[*wsClient]bool{}}
+                addOutput(&output{w: wsCurrent, format: FormatJSON})
+        }
+        return wsCurrent
+}
+
+// ServeHTTP implements http.Handler
+func (wsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+        key := r.Header.Get("Sec-WebSocket-Key")
+        if r.Method != http.MethodGet || key == "" ||
+                !headerContains(r.Header, "Connection", "upgrade") ||
+                !headerContains(r.Header, "Upgrade", "websocket") {
+                http.Error(w, "expected a WebSocket upgrade", http.StatusBadRequest)
+                return
+        }
+        if r.Header.Get("Sec-WebSocket-Version") != "13" {
+                w.Header().Set("Sec-WebSocket-Version", "13")
+                http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+                return
+        }
+        if !wsOriginAllowed(r) {
+                http.Error(w, "origin not allowed", http.StatusForbidden)
+                return
+        }
+        activeWSHub().serve(w, key)
+}
+
+// serve completes the handshake and streams records to the client until
+// it disconnects
+func (h *wsHub) serve(w http.ResponseWriter, key string) {
+        conn, rw, err := http.NewResponseController(w).Hijack()
+        if err != nil {
+                http.Error(w, "WebSocket upgrade not supported", http.StatusInternalServerError)
+                return
+        }
+        sum := sha1.Sum([]byte(key + wsGUID))
+        rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+                "Upgrade: websocket\r\n" +
+                "Connection: Upgrade\r\n" +
+                "Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+        if err := rw.Flush(); err != nil {
+                conn.Close()
+                return
+        }
+
+        c := &wsClient{
+                conn: conn,
+                send: make(chan []byte, wsQueueSize),
+                done: make(chan struct{}),
+        }
+        h.mu.Lock()
+        if h.closed {
+                h.mu.Unlock()
+                conn.Close()
+                return
+        }
+        h.clients[c] = true
+        h.mu.Unlock()
+
+        go c.writeLoop()
+        c.readLoop(rw.Reader)
+
+        h.mu.Lock()
+        delete(h.clients, c)
+        h.mu.Unlock()
+        c.close()
+}
+
+// isClosed reports whether the hub was closed
+func (h *wsHub) isClosed() bool {
+        h.mu.Lock()
+        defer h.mu.Unlock()
+        return h.closed
+}
+
+// Write implements io.Writer, queueing an encoded record for every client
+func (h *wsHub) Write(p []byte) (int, error) {
+        frame := wsFrame(wsText, []byte(strings.TrimSuffix(string(p), "\n")))
+        h.mu.Lock()
+        defer h.mu.Unlock()
+        for c := range h.clients {
+                c.queue(frame)
+        }
+        return len(p), nil
+}
+
+// Close implements io.Closer, disconnecting every client
+func (h *wsHub) Close() error {
+        h.mu.Lock()
+        clients := h.clients
+        h.clients = map[*wsClient]bool{}
+        h.closed = true
+        h.mu.Unlock()
+
+        for c := range clients {
+                c.close()
+        }
+        return nil
+}
+
+// queue sends a frame to the client unless its queue is full
+func (c *wsClient) queue(frame []byte) {
+        select {
+        case c.send <- frame:
+        default:
+        }
+}
+
+// writeLoop writes the queued frames until the client is closed
+func (c *wsClient) writeLoop() {
+        for {
+                select {
+                case frame := <-c.send:
+                        if _, err := writeWithTimeout(c.conn, frame, NetworkTimeout()); err != nil {
+                                c.close()
+                                return
+                        }
+                case <-c.done:
+                        return
+                }
+        }
+}
+
+// readLoop reads client frames until the connection is closed, answering
+// pings and close frames
+func (c *wsClient) readLoop(r *bufio.Reader) {
+        for {
+                opcode, payload, err := readWSFrame(r)
+                if err != nil {
+                        return
+                }
+                switch opcode {
+                case wsPing:
+                        c.queue(wsFrame(wsPong, payload))
+                case wsClose:
+                        writeWithTimeout(c.conn, wsFrame(wsClose, nil), NetworkTimeout())
+                        return
+                }
+        }
+}
+
+// close closes the connection once
+func (c *wsClient) close() {
+        c.closeOnce.Do(func() {
+                close(c.done)
+                c.conn.Close()
+        })
+}
+
+// wsFrame encodes an unmasked, unfragmented server frame
+func wsFrame(opcode byte, payload []byte) []byte {
+        header := []byte{0x80 | opcode}
+        switch n := len(payload); {
+        case n < 126:
+                header = append(header, byte(n))
+        case n <= 0xffff:
+                header = append(header, 126, byte(n>>8), byte(n))
+        default:
+                header = append(header, 127)
+                for shift := 56; shift >= 0; shift -= 8 {
+                        header = append(header, byte(uint64(n)>>shift))
+                }
+        }
+        return append(header, payload...)
+}
+
+// readWSFrame reads one masked client frame and returns its opcode and
+// unmasked payload
+func readWSFrame(r *bufio.Reader) (byte, []byte, error) {
+        var head [2]byte
+        if _, err := io.ReadFull(r, head[:]); err != nil {
+                return 0, nil, err
+        }
+        opcode := head[0] & 0x0f
+        masked := head[1]&0x80 != 0
+        n := uint64(head[1] & 0x7f)
+        switch n {
+        case 126:
+                var ext [2]byte
+                if _, err := io.ReadFull(r, ext[:]); err != nil {
+                        return 0, nil, err
+                }
+                n = uint64(ext[0])<<8 | uint64(ext[1])
+        case 127:
+                var ext [8]byte
+                if _, err := io.ReadFull(r, ext[:]); err != nil {
+                        return 0, nil, err
+                }
+                n = 0
+                for _, b := range ext {
+                        n = n<<8 | uint64(b)
+                }
+        }
+        if !masked || n > wsMaxClientFrame {
+                return 0, nil, errors.New("invalid WebSocket frame")
+        }
+
+        var mask [4]byte
+        if _, err := io.ReadFull(r, mask[:]); err != nil {
+                return 0, nil, err
+        }
+        payload := make([]byte, n)
+        if _, err := io.ReadFull(r, payload); err != nil {
+                return 0, nil, err
+        }
+        for i := range payload {
+                payload[i] ^= mask[i%4]
+        }
+        return opcode, payload, nil
+}
+
+// headerContains reports whether a comma separated header lists token,
+// ignoring case
+func headerContains(h http.Header, name, token string) bool {
+        for _, value := range h.Values(name) {
+                for _, t := range strings.Split(value, ",") {
+                        if strings.EqualFold(strings.TrimSpace(t), token) {
+                                return true
+                        }
+                }
+        }
+        return false
+}
@@ -0,0 +1,262 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "bufio"
+        "encoding/json"
+        "fmt"
+        "io"
+        "net"
+        "net/http"
+        "net/http/httptest"
+        "strings"
+        "testing"
+        "time"
+)
+
+// wsTestKey and wsTestAccept are the handshake example of RFC 6455
+const (
+        wsTestKey    = "dGhlIHNhbXBsZSBub25jZQ=="
+        wsTestAccept = "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="
+)
+
+// wsDial sends a WebSocket handshake to srv with the given extra headers
+// and returns the connection, its reader and the response
+func wsDial(t *testing.T, srv *httptest.Server, header http.Header) (net.Conn, *bufio.Reader, *http.Response) {
+        t.Helper()
+        conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+        if err != nil {
+                t.Fatal(err)
+        }
+        t.Cleanup(func() { conn.Close() })
+        conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+        req, _ := http.NewRequest("GET", srv.URL+"/logs", nil)
+        req.Header.Set("Connection", "Upgrade")
+        req.Header.Set("Upgrade", "websocket")
+        req.Header.Set("Sec-WebSocket-Key", wsTestKey)
+        req.Header.Set("Sec-WebSocket-Version", "13")
+        for name, values := range header {
+                req.Header[name] = values
+        }
+        if err := req.Write(conn); err != nil {
+                t.Fatal(err)
+        }
+        r := bufio.NewReader(conn)
+        resp, err := http.ReadResponse(r, req)
+        if err != nil {
+                t.Fatal(err)
+        }
+        return conn, r, resp
+}
+
+// readServerFrame reads one unmasked server frame
+func readServerFrame(t *testing.T, r *bufio.Reader) (byte, []byte) {
+        t.Helper()
+        var head [2]byte
+        if _, err := io.ReadFull(r, head[:]); err != nil {
+                t.Fatalf("reading frame: %v", err)
+        }
+        if head[0]&0x80 == 0 || head[1]&0x80 != 0 {
+                t.Fatalf("frame header %x, want a final unmasked frame", head)
+        }
+        n := uint64(head[1])
+        switch n {
+        case 126, 127:
+                ext := make([]byte, 2)
+                if n == 127 {
+                        ext = make([]byte, 8)
+                }
+                if _, err := io.ReadFull(r, ext); err != nil {
+                        t.Fatal(err)
+                }
+                n = 0
+                for _, b := range ext {
+                        n = n<<8 | uint64(b)
+                }
+        }
+        payload := make([]byte, n)
+        if _, err := io.ReadFull(r, payload); err != nil {
+                t.Fatal(err)
+        }
+        return head[0] & 0x0f, payload
+}
+
+// writeClientFrame writes a masked client frame
+func writeClientFrame(t *testing.T, w io.Writer, opcode byte, payload []byte) {
+        t.Helper()
+        mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+        frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+        frame = append(frame, mask[:]...)
+        for i, b := range payload {
+                frame = append(frame, b^mask[i%4])
+        }
+        if _, err := w.Write(frame); err != nil {
+                t.Fatal(err)
+        }
+}
+
+// waitWSClients waits until n clients are registered with the hub
+func waitWSClients(t *testing.T, n int) {
+        t.Helper()
+        for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+                wsMu.Lock()
+                h := wsCurrent
+                wsMu.Unlock()
+                if h != nil {
+                        h.mu.Lock()
+                        got := len(h.clients)
+                        h.mu.Unlock()
+                        if got == n {
+                                return
+                        }
+                }
+        }
+        t.Fatalf("%d WebSocket clients never registered", n)
+}
+
+// readRecordFrame reads a text frame and decodes its record
+func readRecordFrame(t *testing.T, r *bufio.Reader) map[string]interface{} {
+        t.Helper()
+        opcode, payload := readServerFrame(t, r)
+        if opcode != wsText {
+                t.Fatalf("opcode %#x, want a text frame", opcode)
+        }
+        var record map[string]interface{}
+        if err := json.Unmarshal(payload, &record); err != nil {
+                t.Fatalf("decoding %q: %v", payload, err)
+        }
+        return record
+}
+
+func TestWSHandler(t *testing.T) {
+        tests := []struct {
+                name       string
+                header     map[string]string
+                allowed    []string
+                wantStatus int
+        }{
+                {name: "no origin", wantStatus: http.StatusSwitchingProtocols},
+                {name: "same host origin", header: map[string]string{"Origin": "http://HOST"}, wantStatus: http.StatusSwitchingProtocols},
+                {name: "foreign origin", header: map[string]string{"Origin": "https://evil.example"}, wantStatus: http.StatusForbidden},
+                {
+                        name:       "allowed origin",
+                        header:     map[string]string{"Origin": "https://dash.example"},
+                        allowed:    []string{"https://dash.example"},
+                        wantStatus: http.StatusSwitchingProtocols,
+                },
+                {
+                        name:       "any origin",
+                        header:     map[string]string{"Origin": "https://evil.example"},
+                        allowed:    []string{"*"},
+                        wantStatus: http.StatusSwitchingProtocols,
+                },
+                {name: "old version", header: map[string]string{"Sec-WebSocket-Version": "8"}, wantStatus: http.StatusUpgradeRequired},
+                {name: "no upgrade", header: map[string]string{"Upgrade": "h2c"}, wantStatus: http.StatusBadRequest},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        SetWSAllowedOrigins(tt.allowed...)
+                        srv := httptest.NewServer(WSHandler())
+                        t.Cleanup(srv.Close)
+                        header := http.Header{}
+                        for name, value := range tt.header {
+                                header.Set(name, strings.Replace(value, "HOST", srv.Listener.Addr().String(), 1))
+                        }
+                        _, r, resp := wsDial(t, srv, header)
+
+                        if resp.StatusCode != tt.wantStatus {
+                                t.Fatalf("status %d, want %d", resp.StatusCode, tt.wantStatus)
+                        }
+                        if tt.wantStatus != http.StatusSwitchingProtocols {
+                                return
+                        }
+                        if got := resp.Header.Get("Sec-WebSocket-Accept"); got != wsTestAccept {
+                                t.Errorf("accept key %q, want %q", got, wsTestAccept)
+                        }
+                        waitWSClients(t, 1)
+                        WithField("user", "alice").Info("streamed")
+                        record := readRecordFrame(t, r)
+                        if record["message"] != "streamed" || record["user"] != "alice" {
+                                t.Errorf("streamed record %v", record)
+                        }
+                })
+        }
+}
+
+func TestWSFraming(t *testing.T) {
+        tests := []struct {
+                name       string
+                opcode     byte
+                payload    string
+                wantOpcode byte
+                wantEcho   bool // Whether the payload is sent back
+        }{
+                {name: "ping", opcode: wsPing, payload: "are you there", wantOpcode: wsPong, wantEcho: true},
+                {name: "close", opcode: wsClose, wantOpcode: wsClose},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        captureOutput(t)
+                        srv := httptest.NewServer(WSHandler())
+                        t.Cleanup(srv.Close)
+                        conn, r, resp := wsDial(t, srv, nil)
+                        if resp.StatusCode != http.StatusSwitchingProtocols {
+                                t.Fatalf("status %d", resp.StatusCode)
+                        }
+                        waitWSClients(t, 1)
+                        writeClientFrame(t, conn, tt.opcode, []byte(tt.payload))
+
+                        opcode, payload := readServerFrame(t, r)
+                        if opcode != tt.wantOpcode {
+                                t.Errorf("opcode %#x, want %#x", opcode, tt.wantOpcode)
+                        }
+                        if tt.wantEcho && string(payload) != tt.payload {
+                                t.Errorf("payload %q, want %q", payload, tt.payload)
+                        }
+                        if tt.opcode == wsClose {
+                                waitWSClients(t, 0)
+                        }
+                })
+        }
+}
+
+func TestWSFrameLengths(t *testing.T) {
+        for _, n := range []int{0, 125, 126, 200, 0xffff, 0x10000} {
+                t.Run(fmt.Sprint(n), func(t *testing.T) {
+                        payload := strings.Repeat("x", n)
+                        opcode, got := readServerFrame(t, bufio.NewReader(strings.NewReader(string(wsFrame(wsText, []byte(payload))))))
+                        if opcode != wsText || string(got) != payload {
+                                t.Errorf("frame decoded to opcode %#x and %d bytes, want %d bytes", opcode, len(got), n)
+                        }
+                })
+        }
+}
+
+func TestWSReconnectAfterClose(t *testing.T) {
+        captureOutput(t)
+        srv := httptest.NewServer(WSHandler())
+        t.Cleanup(srv.Close)
+
+        _, r, _ := wsDial(t, srv, nil)
+        waitWSClients(t, 1)
+        CloseLogger()
+        if _, err := r.ReadByte(); err != io.EOF {
+                t.Errorf("read after CloseLogger returned %v, want EOF", err)
+        }
+
+        _, r, resp := wsDial(t, srv, nil)
+        if resp.StatusCode != http.StatusSwitchingProtocols {
+                t.Fatalf("status %d after CloseLogger", resp.StatusCode)
+        }
+        waitWSClients(t, 1)
+        Info("first")
+        Info("second")
+        for _, want := range []string{"first", "second"} {
+                if record := readRecordFrame(t, r); record["message"] != want {
+                        t.Errorf("record %v, want message %q once", record, want)
+                }
+        }
+}
@@ -0,0 +1,100 @@
+// File: wrap.go
+// Description:
+// Soft wrapping of console output. Long lines are broken at word boundaries
+// to fit narrow terminals; continuation lines are indented. Only the
+// console is affected, files and other outputs keep one line per record.
+
+package logger
+
+import (
+        "bytes"
+        "io"
+        "os"
+        "strconv"
+)
+
+// wrapIndent prefixes continuation lines of a wrapped record
+const wrapIndent = "    "
+
+// Console wrap width: positive for a fixed width, 0 for the terminal
+// width, negative (the default) for no wrapping (guarded by outputsMu)
+var wrapWidth = -1
+
+// SetWrapWidth soft-wraps console lines longer than n columns at word
+// boundaries. With n == 0 the width of the terminal is used (and nothing is
+// wrapped when stdout is not a terminal); a negative n disables wrapping.
+func SetWrapWidth(n int) {
+        outputsMu.Lock()
+        defer outputsMu.Unlock()
+        wrapWidth = n
+}
+
+// consoleWidth returns the width to wrap console output at, 0 for none;
+// outputsMu must be held
+func consoleWidth() int {
+        if wrapWidth != 0 {
+                if wrapWidth < 0 {
+                        return 0
+                }
+                return wrapWidth
+        }
+        if !stdoutIsTerminal() {
+                return 0
+        }
+        if w := terminalWidth(os.Stdout); w > 0 {
+                return w
+        }
+        if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+                return w
+        }
+        return 0
+}
+
+// wrapText breaks every line of data longer than width at the last space
+// that fits. Words longer than the width are left whole.
+func wrapText(data []byte, width int) []byte {
+        if width <= len(wrapIndent) {
+                return data
+        }
+        var out bytes.Buffer
+        lines := bytes.SplitAfter(data, []byte("\n"))
+        for _, line := range lines {
+                newline := bytes.HasSuffix(line, []byte("\n"))
+                line = bytes.TrimSuffix(line, []byte("\n"))
+                limit := width
+                for len(line) > limit {
+                        cut := bytes.LastIndexByte(line[:limit+1], ' ')
+                        if cut <= 0 {
+                                // No space to break at: keep the word whole
+                                cut = bytes.IndexByte(line[limit:], ' ')
+                                if cut < 0 {
+                                        break
+                                }
+                                cut += limit
+                        }
+                        out.Write(line[:cut])
+                        out.WriteString("\n" + wrapIndent)
+                        line = bytes.TrimLeft(line[cut:], " ")
+                        limit = width - len(wrapIndent)
+                }
+                out.Write(line)
+                if newline {
+                        out.WriteByte('\n')
+                }
+        }
+        return out.Bytes()
+}
+
+// wrapWriter wraps what is written to w at width
+type wrapWriter struct {
+        w     io.Writer
+        width int
+}
+
+// Write implements io.Writer
+func (ww wrapWriter) Write(p []byte) (int, error) {
+        if _, err := ww.w.Write(wrapText(p, ww.width)); err != nil {
+                return 0, err
+        }
+        return len(p), nil
+}
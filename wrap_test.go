@@ -0,0 +1,88 @@
+//go:build !logger_minimal
+
+package logger
+
+import (
+        "strings"
+        "testing"
+)
+
+func TestWrapWidth(t *testing.T) {
+        message := strings.TrimSpace(strings.Repeat("lorem ipsum dolor ", 10))
+        tests := []struct {
+                name     string
+                width    int
+                terminal bool
+                columns  string
+                want     int // Console line width limit, 0 for no wrapping
+        }{
+                {name: "fixed width", width: 40, want: 40},
+                {name: "disabled", width: -1},
+                {name: "terminal width from COLUMNS", terminal: true, columns: "60", want: 60},
+                {name: "terminal width without a terminal", columns: "60"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        out := captureOutput(t)
+                        forceTerminal(t, tt.terminal)
+                        t.Setenv("COLUMNS", tt.columns)
+                        path := tempLogPath(t, "app.log")
+                        if err := InitLogger(LevelInfo, true, path); err != nil {
+                                t.Fatal(err)
+                        }
+                        SetWrapWidth(tt.width)
+                        Info(message)
+
+                        file := readLog(t, path)
+                        if strings.Count(file, "\n") != 1 {
+                                t.Fatalf("log file %q is not a single line", file)
+                        }
+                        lines := out.Lines()
+                        if tt.want == 0 {
+                                if len(lines) != 1 {
+                                        t.Errorf("console wrapped into %d lines", len(lines))
+                                }
+                                return
+                        }
+                        if len(lines) < 2 {
+                                t.Fatalf("console not wrapped: %q", out.String())
+                        }
+                        for i, line := range lines {
+                                if len(line) > tt.want {
+                                        t.Errorf("line %d is %d columns wide: %q", i, len(line), line)
+                                }
+                                if i > 0 && !strings.HasPrefix(line, wrapIndent) {
+                                        t.Errorf("continuation line %q not indented", line)
+                                }
+                        }
+                        // Unwrapping gives the file line back
+                        for i := range lines {
+                                lines[i] = strings.TrimPrefix(lines[i], wrapIndent)
+                        }
+                        if got := strings.Join(lines, " ") + "\n"; got != file {
+                                t.Errorf("unwrapped console %q, want the file line %q", got, file)
+                        }
+                })
+        }
+}
+
+func TestWrapText(t *testing.T) {
+        tests := []struct {
+                name  string
+                in    string
+                width int
+                want  string
+        }{
+                {name: "short line", in: "a b c\n", width: 10, want: "a b c\n"},
+                {name: "word boundary", in: "aaaa bbbb cccc\n", width: 10, want: "aaaa bbbb\n    cccc\n"},
+                {name: "long word kept whole", in: "aaaaaaaaaaaaaa bb\n", width: 10, want: "aaaaaaaaaaaaaa\n    bb\n"},
+                {name: "width too small", in: "aaaa bbbb\n", width: 4, want: "aaaa bbbb\n"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        if got := string(wrapText([]byte(tt.in), tt.width)); got != tt.want {
+                                t.Errorf("wrapText(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+                        }
+                })
+        }
+}